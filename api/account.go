@@ -0,0 +1,51 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// Organization is the subset of organization metadata exposed by the API.
+// It is only available to keys allowed to read it (admin keys); regular keys
+// get an error back.
+type Organization struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+func (c *Client) Organization(ctx context.Context) (*Organization, error) {
+	req, err := c.newRequest("GET", "/v1/organizations/me", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.HTTPClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp)
+	}
+
+	var org Organization
+	if err := json.NewDecoder(resp.Body).Decode(&org); err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// MaskKey hides all but the prefix and the last four characters of an API
+// key so it can be shown on screen.
+func MaskKey(key string) string {
+	if key == "" {
+		return "(not set)"
+	}
+	if len(key) <= 12 {
+		return "****"
+	}
+	return key[:7] + "…" + key[len(key)-4:]
+}
@@ -1,14 +1,251 @@
 package api
 
-// curl https://api.anthropic.com/v1/messages \
-//      --header "x-api-key: $ANTHROPIC_API_KEY" \
-//      --header "anthropic-version: 2023-06-01" \
-//      --header "content-type: application/json" \
-//      --data \
-// '{
-//     "model": "claude-3-opus-20240229",
-//     "max_tokens": 1024,
-//     "messages": [
-//         {"role": "user", "content": "Hello, world"}
-//     ]
-// }'
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultBaseURL = "https://api.anthropic.com"
+	DefaultVersion = "2023-06-01"
+	DefaultModel   = "claude-3-opus-20240229"
+
+	DefaultMaxTokens = 4096
+)
+
+// Client holds everything needed to talk to the Anthropic API. Version may
+// be changed by the client itself when the API rejects it, see
+// callClaudeAPI; read it with CurrentVersion once requests are in flight.
+type Client struct {
+	APIKey     string
+	BaseURL    string
+	Version    string
+	HTTPClient *http.Client
+	// Logger receives debug output. It discards everything by default.
+	Logger *log.Logger
+	// ConnectTimeout limits the wait for a streamed response to start, and
+	// IdleTimeout the gaps within it. Zero means no limit. Neither applies
+	// to CreateMessage, whose response only starts once it is complete.
+	ConnectTimeout time.Duration
+	IdleTimeout    time.Duration
+
+	mu sync.Mutex
+	// goodVersion is the last anthropic-version a request succeeded with.
+	goodVersion string
+}
+
+func NewClient(apiKey, baseURL string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{
+		APIKey:     apiKey,
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		Version:    DefaultVersion,
+		HTTPClient: &http.Client{Transport: NewTransport(nil, nil)},
+		Logger:     log.New(io.Discard, "", 0),
+	}
+}
+
+// CloseIdleConnections closes the connections to the API that no request
+// is using. The next request opens a new one.
+func (c *Client) CloseIdleConnections() {
+	c.HTTPClient.CloseIdleConnections()
+}
+
+// CurrentVersion returns the anthropic-version header requests are sent
+// with.
+func (c *Client) CurrentVersion() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Version
+}
+
+// MessageToSend is a message of the conversation sent with a request. Its
+// Content is sent as a plain string, unless Blocks is set: the content
+// blocks of a reply with thinking or tool calls, which the API wants back
+// as they were, or of a message answering tool calls. Content is then only
+// its text, for estimates.
+type MessageToSend struct {
+	Role    string
+	Content string
+	Blocks  []ContentBlock
+}
+
+func (m MessageToSend) MarshalJSON() ([]byte, error) {
+	var content any = m.Content
+	if m.Blocks != nil {
+		content = m.Blocks
+	}
+	return json.Marshal(struct {
+		Role    string `json:"role"`
+		Content any    `json:"content"`
+	}{m.Role, content})
+}
+
+func ConstructUserMessage(content string) MessageToSend {
+	return MessageToSend{
+		Role:    "user",
+		Content: content,
+	}
+}
+
+// MessageRequest is the body of a Messages API call. Optional sampling
+// parameters are pointers so that unset ones are left to the API default.
+type MessageRequest struct {
+	Model       string          `json:"model"`
+	MaxTokens   int             `json:"max_tokens"`
+	System      string          `json:"system,omitempty"`
+	Temperature *float64        `json:"temperature,omitempty"`
+	TopP        *float64        `json:"top_p,omitempty"`
+	Thinking    *Thinking       `json:"thinking,omitempty"`
+	Tools       []Tool          `json:"tools,omitempty"`
+	ToolChoice  *ToolChoice     `json:"tool_choice,omitempty"`
+	ServiceTier string          `json:"service_tier,omitempty"`
+	Messages    []MessageToSend `json:"messages"`
+	Stream      bool            `json:"stream,omitempty"`
+}
+
+// ServiceTiers are the values of MessageRequest.ServiceTier: auto uses
+// priority capacity when the account has it, standard_only never does.
+var ServiceTiers = []string{"auto", "standard_only"}
+
+// CheckServiceTier reports an error unless tier is one of ServiceTiers.
+func CheckServiceTier(tier string) error {
+	for _, t := range ServiceTiers {
+		if tier == t {
+			return nil
+		}
+	}
+	return fmt.Errorf("service tier must be auto or standard_only, got %q", tier)
+}
+
+// Thinking enables extended thinking with a budget of BudgetTokens, which
+// must be at least 1024 and less than max_tokens.
+type Thinking struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens"`
+}
+
+// NewMessageRequest returns a request for a single user message using the
+// default model and token limit.
+func NewMessageRequest(content string) MessageRequest {
+	return MessageRequest{
+		Model:     DefaultModel,
+		MaxTokens: DefaultMaxTokens,
+		Messages: []MessageToSend{
+			ConstructUserMessage(content),
+		},
+	}
+}
+
+// constructJsonBody builds the Messages API request body, i.e. the --data
+// part of:
+//
+//	curl https://api.anthropic.com/v1/messages \
+//	     --header "x-api-key: $ANTHROPIC_API_KEY" \
+//	     --header "anthropic-version: 2023-06-01" \
+//	     --header "content-type: application/json" \
+//	     --data \
+//	'{
+//	    "model": "claude-3-opus-20240229",
+//	    "max_tokens": 1024,
+//	    "messages": [
+//	        {"role": "user", "content": "Hello, world"}
+//	    ]
+//	}'
+func constructJsonBody(req MessageRequest) ([]byte, error) {
+	return json.Marshal(req)
+}
+
+func (c *Client) newRequest(method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, c.BaseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", c.APIKey)
+	req.Header.Set("anthropic-version", c.CurrentVersion())
+	return req, nil
+}
+
+func (c *Client) postMessages(ctx context.Context, body []byte, version string, timeout time.Duration) (*http.Response, error) {
+	req, err := c.newRequest("POST", "/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("anthropic-version", version)
+	resp, err := c.doWithConnectTimeout(ctx, req, timeout)
+	if err == nil {
+		c.Logger.Printf("POST /v1/messages: %s, request-id %s", resp.Status, requestID(resp))
+	}
+	return resp, err
+}
+
+// callClaudeAPI posts body to the Messages endpoint and returns the response
+// if it succeeded, or the API error otherwise. timeout limits the wait for
+// the response headers, if positive.
+//
+// When the API rejects the configured anthropic-version, the request is
+// retried once with the last version that worked (the default one if none
+// did yet) and the client keeps using it. The returned warning tells the user
+// about the downgrade.
+func (c *Client) callClaudeAPI(ctx context.Context, body []byte, timeout time.Duration) (*http.Response, string, error) {
+	version := c.CurrentVersion()
+	resp, err := c.postMessages(ctx, body, version, timeout)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode == http.StatusOK {
+		c.rememberVersion(version)
+		return resp, "", nil
+	}
+
+	apiErr := newAPIError(resp)
+	resp.Body.Close()
+
+	fallback := c.fallbackVersion()
+	if !isVersionError(apiErr) || fallback == version {
+		return nil, "", apiErr
+	}
+
+	c.Logger.Printf("anthropic-version %q rejected (%v), retrying with %q", version, apiErr, fallback)
+	resp, err = c.postMessages(ctx, body, fallback, timeout)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, "", newAPIError(resp)
+	}
+
+	c.mu.Lock()
+	c.Version = fallback
+	c.mu.Unlock()
+	c.rememberVersion(fallback)
+	return resp, fmt.Sprintf("anthropic-version %q is not supported, switched to %q", version, fallback), nil
+}
+
+func (c *Client) rememberVersion(version string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.goodVersion = version
+}
+
+func (c *Client) fallbackVersion() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.goodVersion != "" {
+		return c.goodVersion
+	}
+	return DefaultVersion
+}
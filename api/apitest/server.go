@@ -0,0 +1,300 @@
+// Package apitest provides a fake Anthropic Messages API, to exercise the
+// client and the UI from request to rendered reply without the network.
+//
+// A Server answers each request with the next Response pushed to it, and
+// with a plain reply once they run out:
+//
+//	srv := apitest.NewServer()
+//	defer srv.Close()
+//	srv.Push(apitest.RateLimited(2*time.Second), apitest.Reply("Hello"))
+//	client := srv.Client()
+package apitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bnema/cclui/api"
+)
+
+// DefaultReply is the text of the reply sent once the pushed responses run
+// out.
+const DefaultReply = "Hello from the fake API."
+
+// Response is how the server answers one request: a streamed reply or an
+// API error, possibly broken on purpose. Build them with Reply, Slow,
+// Error, RateLimited, Malformed and Disconnect.
+type Response struct {
+	// Status is the HTTP status; 200 sends Text as a reply.
+	Status int
+	Header http.Header
+	// Text is the reply, sent in Chunks pieces when streamed.
+	Text       string
+	Chunks     int
+	StopReason string
+	Usage      api.Usage
+	// ErrorType and ErrorMessage fill the error envelope of a failure.
+	ErrorType    string
+	ErrorMessage string
+	// Garbage is sent as an undecodable event in the middle of the stream.
+	Garbage string
+	// CutAfter drops the connection once that many chunks are streamed,
+	// without ending the message.
+	CutAfter int
+	// Interval is the pause between chunks.
+	Interval time.Duration
+}
+
+// Reply is a successful response with text, streamed in a few chunks when
+// the request asks for a stream.
+func Reply(text string) Response {
+	return Response{
+		Status:     http.StatusOK,
+		Text:       text,
+		Chunks:     3,
+		StopReason: "end_turn",
+		Usage:      api.Usage{InputTokens: 10, OutputTokens: len(strings.Fields(text))},
+	}
+}
+
+// Slow is a reply of text streamed in chunks pieces, interval apart, for
+// a client to stop in the middle.
+func Slow(text string, chunks int, interval time.Duration) Response {
+	r := Reply(text)
+	r.Chunks = chunks
+	r.Interval = interval
+	return r
+}
+
+// Error is a failed request with the API's error envelope, such as
+// Error(401, "authentication_error", "invalid x-api-key").
+func Error(status int, errType, message string) Response {
+	return Response{Status: status, ErrorType: errType, ErrorMessage: message}
+}
+
+// RateLimited is a 429 with the retry-after and anthropic-ratelimit
+// headers the API sends when a rate limit is hit.
+func RateLimited(retryAfter time.Duration) Response {
+	r := Error(http.StatusTooManyRequests, "rate_limit_error", "Number of request tokens has exceeded your per-minute rate limit")
+	r.Header = http.Header{}
+	r.Header.Set("retry-after", strconv.Itoa(int(retryAfter.Round(time.Second)/time.Second)))
+	r.Header.Set("anthropic-ratelimit-requests-limit", "50")
+	r.Header.Set("anthropic-ratelimit-requests-remaining", "0")
+	r.Header.Set("anthropic-ratelimit-requests-reset", time.Now().Add(retryAfter).UTC().Format(time.RFC3339))
+	return r
+}
+
+// Malformed is a reply of text with an event that isn't JSON in the middle
+// of the stream.
+func Malformed(text string) Response {
+	r := Reply(text)
+	r.Garbage = `{"type": "content_block_delta", "delta": {`
+	return r
+}
+
+// Disconnect is a reply of text whose connection drops after the first
+// chunk, before the message ends.
+func Disconnect(text string) Response {
+	r := Reply(text)
+	r.CutAfter = 1
+	return r
+}
+
+// Request is a request the server received.
+type Request struct {
+	Header http.Header
+	Body   api.MessageRequest
+}
+
+// Server is a fake Messages API on a local port.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	queue    []Response
+	requests []Request
+	hangUps  chan struct{}
+}
+
+// NewServer starts a Server. Close it when done.
+func NewServer() *Server {
+	s := &Server{hangUps: make(chan struct{}, 16)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Push queues responses for the next requests, in order.
+func (s *Server) Push(responses ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queue = append(s.queue, responses...)
+}
+
+// Requests returns the requests received so far, in order.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// HangUps receives once for every stream the client went away from before
+// it ended.
+func (s *Server) HangUps() <-chan struct{} {
+	return s.hangUps
+}
+
+// Client returns an api.Client talking to the server.
+func (s *Server) Client() *api.Client {
+	c := api.NewClient("sk-ant-test", s.URL)
+	c.HTTPClient = s.Server.Client()
+	return c
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/v1/messages" {
+		writeError(w, Error(http.StatusNotFound, "not_found_error", "Not found: "+r.URL.Path))
+		return
+	}
+	var body api.MessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, Error(http.StatusBadRequest, "invalid_request_error", err.Error()))
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{Header: r.Header.Clone(), Body: body})
+	w.Header().Set("request-id", fmt.Sprintf("req_fake%d", len(s.requests)))
+	resp := Reply(DefaultReply)
+	if len(s.queue) > 0 {
+		resp, s.queue = s.queue[0], s.queue[1:]
+	}
+	s.mu.Unlock()
+
+	for key, values := range resp.Header {
+		w.Header()[key] = values
+	}
+	switch {
+	case resp.Status != http.StatusOK:
+		writeError(w, resp)
+	case body.Stream:
+		if !writeStream(w, r, body, resp) {
+			select {
+			case s.hangUps <- struct{}{}:
+			default:
+			}
+		}
+	default:
+		writeMessage(w, body, resp)
+	}
+}
+
+func writeError(w http.ResponseWriter, resp Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.Status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"type":  "error",
+		"error": map[string]string{"type": resp.ErrorType, "message": resp.ErrorMessage},
+	})
+}
+
+func writeMessage(w http.ResponseWriter, req api.MessageRequest, resp Response) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.Response{
+		ID:         "msg_fake",
+		Type:       "message",
+		Role:       "assistant",
+		Model:      req.Model,
+		Content:    []api.ContentBlock{{Type: "text", Text: resp.Text}},
+		StopReason: resp.StopReason,
+		Usage:      resp.Usage,
+	})
+}
+
+// writeStream sends resp as server-sent events, the way the API streams a
+// reply with a single text block. It reports false when the client went
+// away before the end.
+func writeStream(w http.ResponseWriter, r *http.Request, req api.MessageRequest, resp Response) bool {
+	w.Header().Set("Content-Type", "text/event-stream")
+	flusher, _ := w.(http.Flusher)
+	send := func(event string, data any) {
+		payload, _ := json.Marshal(data)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	send("message_start", map[string]any{
+		"type": "message_start",
+		"message": map[string]any{
+			"id": "msg_fake", "type": "message", "role": "assistant", "model": req.Model,
+			"usage": api.Usage{InputTokens: resp.Usage.InputTokens},
+		},
+	})
+	send("content_block_start", map[string]any{
+		"type": "content_block_start", "index": 0,
+		"content_block": map[string]string{"type": "text", "text": ""},
+	})
+	for i, chunk := range split(resp.Text, resp.Chunks) {
+		if resp.CutAfter > 0 && i == resp.CutAfter {
+			hangUp(w)
+			return true
+		}
+		if i > 0 && resp.Interval > 0 {
+			select {
+			case <-r.Context().Done():
+				return false
+			case <-time.After(resp.Interval):
+			}
+		}
+		send("content_block_delta", map[string]any{
+			"type": "content_block_delta", "index": 0,
+			"delta": map[string]string{"type": "text_delta", "text": chunk},
+		})
+		if i == 0 && resp.Garbage != "" {
+			fmt.Fprintf(w, "event: content_block_delta\ndata: %s\n\n", resp.Garbage)
+		}
+	}
+	send("content_block_stop", map[string]any{"type": "content_block_stop", "index": 0})
+	send("message_delta", map[string]any{
+		"type":  "message_delta",
+		"delta": map[string]any{"stop_reason": resp.StopReason, "stop_sequence": nil},
+		"usage": map[string]int{"output_tokens": resp.Usage.OutputTokens},
+	})
+	send("message_stop", map[string]string{"type": "message_stop"})
+	return r.Context().Err() == nil
+}
+
+// hangUp drops the connection under w without ending the response.
+func hangUp(w http.ResponseWriter) {
+	if hijacker, ok := w.(http.Hijacker); ok {
+		if conn, _, err := hijacker.Hijack(); err == nil {
+			conn.Close()
+			return
+		}
+	}
+	panic(http.ErrAbortHandler)
+}
+
+// split cuts text in n pieces of about the same length, on rune
+// boundaries.
+func split(text string, n int) []string {
+	runes := []rune(text)
+	if len(runes) == 0 {
+		return []string{""}
+	}
+	size := (len(runes) + max(n, 1) - 1) / max(n, 1)
+	var chunks []string
+	for len(runes) > 0 {
+		end := min(size, len(runes))
+		chunks = append(chunks, string(runes[:end]))
+		runes = runes[end:]
+	}
+	return chunks
+}
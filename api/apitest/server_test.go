@@ -0,0 +1,137 @@
+package apitest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bnema/cclui/api"
+	"github.com/bnema/cclui/api/apitest"
+)
+
+// stream streams the reply to prompt from srv, returning its text and the
+// error that ended it, if any.
+func stream(t *testing.T, srv *apitest.Server, prompt string) (string, error) {
+	t.Helper()
+	events, err := srv.Client().Stream(context.Background(), api.NewMessageRequest(prompt))
+	if err != nil {
+		return "", err
+	}
+	var text strings.Builder
+	for event := range events {
+		if event.Err != nil {
+			return text.String(), event.Err
+		}
+		text.WriteString(event.Text)
+	}
+	return text.String(), nil
+}
+
+func TestReply(t *testing.T) {
+	srv := apitest.NewServer()
+	defer srv.Close()
+	srv.Push(apitest.Reply("Hello there, how are you?"))
+
+	text, err := stream(t, srv, "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "Hello there, how are you?" {
+		t.Errorf("streamed %q", text)
+	}
+
+	resp, err := srv.Client().CreateMessage(context.Background(), api.NewMessageRequest("again"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != apitest.DefaultReply {
+		t.Errorf("once the queue is empty, got %q, want the default reply", resp.Text())
+	}
+
+	requests := srv.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("%d requests recorded, want 2", len(requests))
+	}
+	if !requests[0].Body.Stream || requests[1].Body.Stream {
+		t.Error("the stream flag of the requests was not recorded")
+	}
+	if got := requests[0].Header.Get("x-api-key"); got != "sk-ant-test" {
+		t.Errorf("x-api-key = %q", got)
+	}
+}
+
+func TestUnauthorized(t *testing.T) {
+	srv := apitest.NewServer()
+	defer srv.Close()
+	srv.Push(apitest.Error(http.StatusUnauthorized, "authentication_error", "invalid x-api-key"))
+
+	_, err := stream(t, srv, "hi")
+	var apiErr *api.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want an *api.APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusUnauthorized || apiErr.Type != "authentication_error" || apiErr.Message != "invalid x-api-key" {
+		t.Errorf("err = %+v", apiErr)
+	}
+	if apiErr.Temporary() {
+		t.Error("a 401 is reported as temporary")
+	}
+	if apiErr.RequestID == "" {
+		t.Error("the request-id of the error is missing")
+	}
+}
+
+func TestRateLimited(t *testing.T) {
+	srv := apitest.NewServer()
+	defer srv.Close()
+	srv.Push(apitest.RateLimited(3*time.Second), apitest.Reply("Made it"))
+
+	_, err := stream(t, srv, "hi")
+	var apiErr *api.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want an *api.APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusTooManyRequests || !apiErr.Temporary() {
+		t.Errorf("err = %+v, want a temporary 429", apiErr)
+	}
+	if apiErr.RetryAfter != 3*time.Second {
+		t.Errorf("RetryAfter = %s, want 3s", apiErr.RetryAfter)
+	}
+
+	// The next request goes through, as after waiting.
+	text, err := stream(t, srv, "hi")
+	if err != nil || text != "Made it" {
+		t.Errorf("retry got %q, %v", text, err)
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	srv := apitest.NewServer()
+	defer srv.Close()
+	srv.Push(apitest.Malformed("Still all of it"))
+
+	text, err := stream(t, srv, "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "Still all of it" {
+		t.Errorf("streamed %q, want the event that isn't JSON skipped and the rest kept", text)
+	}
+}
+
+func TestDisconnect(t *testing.T) {
+	srv := apitest.NewServer()
+	defer srv.Close()
+	srv.Push(apitest.Disconnect("Cut off in the middle"))
+
+	text, err := stream(t, srv, "hi")
+	if err == nil {
+		t.Fatal("the stream ended without an error")
+	}
+	if text == "" || text == "Cut off in the middle" {
+		t.Errorf("streamed %q, want the part before the disconnect", text)
+	}
+}
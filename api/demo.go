@@ -0,0 +1,185 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// demoReplies are the canned answers of Demo, given in turn. They exercise
+// the rendering of Markdown and code blocks.
+var demoReplies = []string{
+	"Hello! This is **demo mode**: nothing leaves your machine and no API key is needed.\n\n" +
+		"Things to try:\n\n" +
+		"- `/info` and `/cost` to see the session state\n" +
+		"- `/retry` and then `/diff` to compare two replies\n" +
+		"- `/savecode 1 hello` to save the code block of the next reply",
+	"Here is a small Go program:\n\n" +
+		"```go\n" +
+		"package main\n\n" +
+		"import \"fmt\"\n\n" +
+		"func main() {\n" +
+		"\tfmt.Println(\"Hello from cclui\")\n" +
+		"}\n" +
+		"```\n\n" +
+		"And the same in Python, which `/runcode 2` can run:\n\n" +
+		"```python\n" +
+		"print(\"Hello from cclui\")\n" +
+		"```",
+	"## A longer answer\n\n" +
+		"Demo replies stream in small pieces, like real ones, so the progress bar " +
+		"and Esc to stop can be tried too.\n\n" +
+		"1. Markdown headings, lists and *emphasis* are passed through as typed.\n" +
+		"2. Code blocks are numbered for `/savecode` and `/runcode`.\n" +
+		"3. `/page` opens this reply in your pager.\n\n" +
+		"> This is the last canned reply; the next one starts over.",
+}
+
+const demoThinking = "The user is trying out demo mode. I'll pick the next canned reply."
+
+// Demo is a Provider that replies with canned text, streamed at a readable
+// pace. It goes through the replies in turn, so a retry gets a different
+// one, and never fails.
+type Demo struct {
+	// Delay is the pause between streamed chunks.
+	Delay time.Duration
+
+	mu    sync.Mutex
+	calls int
+}
+
+func NewDemo() *Demo {
+	return &Demo{Delay: 30 * time.Millisecond}
+}
+
+func (d *Demo) reply() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	reply := demoReplies[d.calls%len(demoReplies)]
+	d.calls++
+	return reply
+}
+
+// demoUsage estimates usage the way the UI does, with EstimateTokens.
+func demoUsage(req MessageRequest, reply string) Usage {
+	return Usage{InputTokens: estimateInput(req), OutputTokens: EstimateTokens(reply)}
+}
+
+func estimateInput(req MessageRequest) int {
+	in := EstimateTokens(req.System)
+	for _, m := range req.Messages {
+		in += EstimateTokens(m.Content)
+	}
+	return in
+}
+
+func (d *Demo) CreateMessage(ctx context.Context, req MessageRequest) (*Response, error) {
+	reply := d.reply()
+	resp := &Response{
+		ID:         "msg_demo",
+		Type:       "message",
+		Role:       "assistant",
+		Model:      req.Model,
+		Content:    []ContentBlock{{Type: "text", Text: reply}},
+		StopReason: "end_turn",
+		Usage:      demoUsage(req, reply),
+	}
+	if req.Thinking != nil {
+		resp.Content = append([]ContentBlock{{Type: "thinking", Thinking: demoThinking}}, resp.Content...)
+	}
+
+	raw, err := json.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	resp.Raw = raw
+	return resp, nil
+}
+
+func (d *Demo) Stream(ctx context.Context, req MessageRequest) (<-chan StreamEvent, error) {
+	reply := d.reply()
+	usage := demoUsage(req, reply)
+
+	events := make(chan StreamEvent)
+	go func() {
+		defer close(events)
+		send := func(e StreamEvent) bool {
+			select {
+			case events <- e:
+			case <-ctx.Done():
+				return false
+			}
+			select {
+			case <-time.After(d.Delay):
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		if !send(StreamEvent{Type: "message_start", Usage: Usage{InputTokens: usage.InputTokens}}) {
+			return
+		}
+		index := 0
+		if req.Thinking != nil {
+			if !send(StreamEvent{Type: "content_block_start", Block: "thinking"}) {
+				return
+			}
+			for _, chunk := range demoChunks(demoThinking) {
+				if !send(StreamEvent{Type: "content_block_delta", Block: "thinking", Thinking: chunk}) {
+					return
+				}
+			}
+			index++
+		}
+		if !send(StreamEvent{Type: "content_block_start", Index: index, Block: "text"}) {
+			return
+		}
+		for _, chunk := range demoChunks(reply) {
+			if !send(StreamEvent{Type: "content_block_delta", Index: index, Block: "text", Text: chunk}) {
+				return
+			}
+		}
+		if !send(StreamEvent{Type: "message_delta", Usage: Usage{OutputTokens: usage.OutputTokens}, StopReason: "end_turn"}) {
+			return
+		}
+		send(StreamEvent{Type: "message_stop"})
+	}()
+	return events, nil
+}
+
+// demoChunks splits text after every few words, keeping the spacing.
+func demoChunks(text string) []string {
+	var chunks []string
+	for len(text) > 0 {
+		n, words := 0, 0
+		for n < len(text) && words < 3 {
+			i := strings.IndexAny(text[n:], " \n")
+			if i < 0 {
+				n = len(text)
+				break
+			}
+			n += i + 1
+			words++
+		}
+		chunks = append(chunks, text[:n])
+		text = text[n:]
+	}
+	return chunks
+}
+
+func (d *Demo) Models(ctx context.Context) ([]ModelInfo, error) {
+	return KnownModels, nil
+}
+
+// CountTokens estimates the count, as there is no tokenizer offline.
+func (d *Demo) CountTokens(ctx context.Context, req MessageRequest) (int, error) {
+	return estimateInput(req), nil
+}
+
+func (d *Demo) Organization(ctx context.Context) (*Organization, error) {
+	return nil, errors.New("not available in demo mode")
+}
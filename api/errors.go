@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIError is the error envelope returned by the Anthropic API. RequestID
+// is the request-id header of the response, which Anthropic support asks
+// for, and RetryAfter its retry-after header, when the API says how long to
+// wait before trying again.
+type APIError struct {
+	StatusCode int
+	Type       string        `json:"type"`
+	Message    string        `json:"message"`
+	RequestID  string        `json:"-"`
+	RetryAfter time.Duration `json:"-"`
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("api error (%d %s): %s", e.StatusCode, e.Type, e.Message)
+	if e.Message == "" {
+		msg = fmt.Sprintf("api error: %s", http.StatusText(e.StatusCode))
+	}
+	if e.RequestID != "" {
+		msg += fmt.Sprintf(" (request-id %s)", e.RequestID)
+	}
+	return msg
+}
+
+func newAPIError(resp *http.Response) *APIError {
+	apiErr := &APIError{StatusCode: resp.StatusCode, RequestID: requestID(resp)}
+	if s, err := strconv.Atoi(resp.Header.Get("retry-after")); err == nil && s > 0 {
+		apiErr.RetryAfter = time.Duration(s) * time.Second
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return apiErr
+	}
+
+	var envelope struct {
+		Error *APIError `json:"error"`
+	}
+	if json.Unmarshal(body, &envelope) == nil && envelope.Error != nil {
+		apiErr.Type = envelope.Error.Type
+		apiErr.Message = envelope.Error.Message
+	} else {
+		apiErr.Message = string(body)
+	}
+	return apiErr
+}
+
+// Temporary reports whether the request may succeed if sent again later:
+// the rate limit was hit or the API is overloaded.
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode == 529
+}
+
+// requestID is the ID the API gave the request of resp.
+func requestID(resp *http.Response) string {
+	return resp.Header.Get("request-id")
+}
+
+// isVersionError reports whether err is the API refusing the
+// anthropic-version header.
+func isVersionError(err *APIError) bool {
+	return err.StatusCode == http.StatusBadRequest &&
+		strings.Contains(strings.ToLower(err.Message), "anthropic-version")
+}
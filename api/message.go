@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// ContentBlock is a block of the content of a message. Thinking blocks
+// carry the Signature the API checks when they are sent back, and
+// redacted_thinking ones their encrypted Data. A tool_use block has the ID,
+// Name and Input of the call, and the tool_result block answering it the
+// ToolUseID and, as Content, the result.
+type ContentBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	Thinking  string          `json:"thinking,omitempty"`
+	Signature string          `json:"signature,omitempty"`
+	Data      string          `json:"data,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+}
+
+// Usage is the token count of a response. ServiceTier is the tier it was
+// served with, standard, priority or batch, when the API says.
+type Usage struct {
+	InputTokens  int    `json:"input_tokens"`
+	OutputTokens int    `json:"output_tokens"`
+	ServiceTier  string `json:"service_tier,omitempty"`
+}
+
+// Response is a complete, non-streamed Messages API response. Raw keeps the
+// body exactly as the API returned it.
+type Response struct {
+	ID           string          `json:"id"`
+	Type         string          `json:"type"`
+	Role         string          `json:"role"`
+	Model        string          `json:"model"`
+	Content      []ContentBlock  `json:"content"`
+	StopReason   string          `json:"stop_reason"`
+	StopSequence string          `json:"stop_sequence"`
+	Usage        Usage           `json:"usage"`
+	Raw          json.RawMessage `json:"-"`
+	// RequestID is the ID the API gave the request, for support.
+	RequestID string `json:"-"`
+	// Warning is set when the request needed a workaround the user should
+	// hear about, such as an anthropic-version downgrade.
+	Warning string `json:"-"`
+}
+
+// Text concatenates the text blocks of the response, in order and separated
+// by a blank line, the way the UI shows a streamed reply.
+func (r *Response) Text() string {
+	var texts []string
+	for _, block := range r.Content {
+		if block.Type == "text" {
+			texts = append(texts, block.Text)
+		}
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// CreateMessage sends req without streaming and waits for the full response.
+func (c *Client) CreateMessage(ctx context.Context, req MessageRequest) (*Response, error) {
+	req.Stream = false
+	body, err := constructJsonBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, warning, err := c.callClaudeAPI(ctx, body, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var out Response
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, err
+	}
+	out.Raw = raw
+	out.RequestID = requestID(resp)
+	out.Warning = warning
+	return &out, nil
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// ModelInfo describes a model that can be used for requests. ContextWindow
+// and the prices, in dollars per million tokens, are only known for the
+// models in KnownModels and are zero otherwise.
+type ModelInfo struct {
+	ID            string  `json:"id"`
+	DisplayName   string  `json:"display_name"`
+	ContextWindow int     `json:"-"`
+	InputPrice    float64 `json:"-"`
+	OutputPrice   float64 `json:"-"`
+}
+
+// KnownModels is used when the models endpoint can't be reached, and to add
+// context window and pricing hints to the models it lists.
+var KnownModels = []ModelInfo{
+	{ID: "claude-3-opus-20240229", DisplayName: "Claude 3 Opus", ContextWindow: 200000, InputPrice: 15, OutputPrice: 75},
+	{ID: "claude-3-sonnet-20240229", DisplayName: "Claude 3 Sonnet", ContextWindow: 200000, InputPrice: 3, OutputPrice: 15},
+	{ID: "claude-3-haiku-20240307", DisplayName: "Claude 3 Haiku", ContextWindow: 200000, InputPrice: 0.25, OutputPrice: 1.25},
+	{ID: "claude-2.1", DisplayName: "Claude 2.1", ContextWindow: 200000, InputPrice: 8, OutputPrice: 24},
+	{ID: "claude-2.0", DisplayName: "Claude 2.0", ContextWindow: 100000, InputPrice: 8, OutputPrice: 24},
+	{ID: "claude-instant-1.2", DisplayName: "Claude Instant 1.2", ContextWindow: 100000, InputPrice: 0.8, OutputPrice: 2.4},
+}
+
+// LookupModel returns the entry of KnownModels for id, if there is one.
+func LookupModel(id string) (ModelInfo, bool) {
+	for _, m := range KnownModels {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return ModelInfo{}, false
+}
+
+// Models lists the models available to the API key, with the hints of
+// KnownModels filled in where available.
+func (c *Client) Models(ctx context.Context) ([]ModelInfo, error) {
+	req, err := c.newRequest("GET", "/v1/models?limit=1000", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.HTTPClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp)
+	}
+
+	var page struct {
+		Data []ModelInfo `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, err
+	}
+	for i, m := range page.Data {
+		if known, ok := LookupModel(m.ID); ok {
+			known.DisplayName = m.DisplayName
+			page.Data[i] = known
+		}
+	}
+	return page.Data, nil
+}
@@ -0,0 +1,18 @@
+package api
+
+import "context"
+
+// Provider answers Messages API requests. Client is the real one, talking
+// to the API; Demo makes up replies without any network access.
+type Provider interface {
+	CreateMessage(ctx context.Context, req MessageRequest) (*Response, error)
+	Stream(ctx context.Context, req MessageRequest) (<-chan StreamEvent, error)
+	Models(ctx context.Context) ([]ModelInfo, error)
+	CountTokens(ctx context.Context, req MessageRequest) (int, error)
+	Organization(ctx context.Context) (*Organization, error)
+}
+
+var (
+	_ Provider = (*Client)(nil)
+	_ Provider = (*Demo)(nil)
+)
@@ -0,0 +1,268 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strings"
+)
+
+// StreamEvent is one parsed server-sent event of a streamed response. Text
+// is set for text deltas, Thinking for thinking deltas and Usage for
+// message_start and message_delta; Block is the type of the content block a
+// delta belongs to. StopReason is set on message_delta: end_turn,
+// max_tokens, stop_sequence or tool_use, with the matched sequence in
+// StopSequence for stop_sequence. Err is set when the stream failed and no
+// further events will follow. The client adds events of type "warning",
+// whose Text is meant for the user.
+//
+// RequestID is set on message_start: the ID the API gave the request, for
+// support.
+//
+// ToolUse is set on the content_block_stop event of a tool_use block, once
+// its input, streamed as partial JSON, is complete.
+//
+// Signature is set for the signature deltas ending a thinking block, and
+// Data on the content_block_start of a redacted_thinking block, to send
+// them back with the conversation.
+type StreamEvent struct {
+	Type         string
+	Index        int
+	Block        string
+	Text         string
+	Thinking     string
+	StopReason   string
+	StopSequence string
+	RequestID    string
+	Signature    string
+	Data         string
+	Usage        Usage
+	ToolUse      *ToolUse
+	Err          error
+}
+
+// ToolUse is a call of a tool by the model.
+type ToolUse struct {
+	ID    string
+	Name  string
+	Input json.RawMessage
+}
+
+type streamPayload struct {
+	Type  string `json:"type"`
+	Index int    `json:"index"`
+	Delta struct {
+		Type         string `json:"type"`
+		Text         string `json:"text"`
+		Thinking     string `json:"thinking"`
+		Signature    string `json:"signature"`
+		PartialJSON  string `json:"partial_json"`
+		StopReason   string `json:"stop_reason"`
+		StopSequence string `json:"stop_sequence"`
+	} `json:"delta"`
+	ContentBlock struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+		Name string `json:"name"`
+		Data string `json:"data"`
+	} `json:"content_block"`
+	Message struct {
+		Usage Usage `json:"usage"`
+	} `json:"message"`
+	Usage Usage     `json:"usage"`
+	Error *APIError `json:"error"`
+}
+
+// Stream sends req with streaming enabled. Events are delivered on the
+// returned channel, which is closed once the response ends. The stream
+// fails with ErrConnectTimeout or ErrIdleTimeout when the client's timeouts
+// run out. Cancelling ctx ends it and closes the connection, whether the
+// channel is still read or not.
+func (c *Client) Stream(ctx context.Context, req MessageRequest) (<-chan StreamEvent, error) {
+	req.Stream = true
+	body, err := constructJsonBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	reqCtx, cancel := context.WithCancelCause(ctx)
+	resp, warning, err := c.callClaudeAPI(reqCtx, body, c.ConnectTimeout)
+	if err != nil {
+		cancel(nil)
+		return nil, err
+	}
+	if c.IdleTimeout > 0 {
+		resp.Body = newIdleBody(reqCtx, cancel, resp.Body, c.IdleTimeout)
+	}
+
+	events := make(chan StreamEvent)
+	go func() {
+		defer cancel(nil)
+		// Only the caller's ctx means nobody is listening any more: the
+		// idle timeout cancels reqCtx alone, and its error is delivered.
+		processAPIResponse(ctx, resp, warning, events, c.Logger)
+	}()
+	return events, nil
+}
+
+// processAPIResponse turns the server-sent events of resp into events,
+// after a warning event if warning is set. A payload that can't be decoded
+// is written to logger and skipped, so one malformed chunk costs at most a
+// piece of the reply instead of all of it.
+//
+// Once ctx, the context of the request, is cancelled it stops at once, even
+// with nobody reading events any more, and closes the body unread: the
+// connection is then closed rather than reused, which is what tells the API
+// to stop generating.
+func processAPIResponse(ctx context.Context, resp *http.Response, warning string, events chan<- StreamEvent, logger *log.Logger) {
+	defer resp.Body.Close()
+	defer close(events)
+	send := func(e StreamEvent) bool {
+		// Checked first, as select picks at random among ready cases.
+		if ctx.Err() != nil {
+			return false
+		}
+		select {
+		case events <- e:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	// A payload that trips the parsing up ends the stream with an error
+	// rather than the program, which can't catch panics in this goroutine.
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Printf("panic while reading the stream: %v\n%s", r, debug.Stack())
+			send(StreamEvent{Type: "error", Err: fmt.Errorf("internal error while reading the response: %v", r)})
+		}
+	}()
+
+	if warning != "" && !send(StreamEvent{Type: "warning", Text: warning}) {
+		return
+	}
+
+	// blocks maps content block indices to their type, from
+	// content_block_start, so deltas can be told apart.
+	blocks := map[int]string{}
+	// tools holds the tool_use blocks being streamed, by index, with their
+	// input so far.
+	tools := map[int]*toolBuffer{}
+
+	scanner := bufio.NewReader(resp.Body)
+	for {
+		line, err := scanner.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				break // End of the stream
+			}
+			send(StreamEvent{Type: "error", Err: err})
+			return
+		}
+
+		// Only data lines carry a payload; the event name is repeated
+		// inside it.
+		data, ok := strings.CutPrefix(strings.TrimSpace(line), "data:")
+		if !ok {
+			continue
+		}
+
+		data = strings.TrimSpace(data)
+		var payload streamPayload
+		if err := json.Unmarshal([]byte(data), &payload); err != nil {
+			logger.Printf("skipping malformed stream event (%v): %s", err, data)
+			continue
+		}
+
+		switch payload.Type {
+		case "message_start":
+			if !send(StreamEvent{Type: payload.Type, Usage: payload.Message.Usage, RequestID: requestID(resp)}) {
+				return
+			}
+		case "message_delta":
+			if !send(StreamEvent{Type: payload.Type, Usage: payload.Usage, StopReason: payload.Delta.StopReason, StopSequence: payload.Delta.StopSequence}) {
+				return
+			}
+		case "content_block_start":
+			blocks[payload.Index] = payload.ContentBlock.Type
+			if payload.ContentBlock.Type == "tool_use" {
+				tools[payload.Index] = &toolBuffer{id: payload.ContentBlock.ID, name: payload.ContentBlock.Name}
+			}
+			if !send(StreamEvent{Type: payload.Type, Index: payload.Index, Block: payload.ContentBlock.Type, Data: payload.ContentBlock.Data}) {
+				return
+			}
+		case "content_block_delta":
+			event := StreamEvent{Type: payload.Type, Index: payload.Index, Block: blocks[payload.Index]}
+			switch payload.Delta.Type {
+			case "text_delta":
+				event.Text = payload.Delta.Text
+			case "thinking_delta":
+				event.Thinking = payload.Delta.Thinking
+			case "signature_delta":
+				event.Signature = payload.Delta.Signature
+			case "input_json_delta":
+				// Only whole inputs are useful, see content_block_stop.
+				if tool := tools[payload.Index]; tool != nil {
+					tool.input.WriteString(payload.Delta.PartialJSON)
+				}
+				continue
+			default:
+				continue
+			}
+			if !send(event) {
+				return
+			}
+		case "content_block_stop":
+			event := StreamEvent{Type: payload.Type, Index: payload.Index, Block: blocks[payload.Index]}
+			if tool := tools[payload.Index]; tool != nil {
+				delete(tools, payload.Index)
+				use, err := tool.finish()
+				if err != nil {
+					logger.Printf("dropping tool_use %s with malformed input (%v): %s", tool.name, err, tool.input.String())
+				}
+				event.ToolUse = use
+			}
+			if !send(event) {
+				return
+			}
+		case "error":
+			// An error event without an error object still ends the
+			// stream with an error, never a nil *APIError.
+			var err error = fmt.Errorf("stream error event: %s", data)
+			if payload.Error != nil {
+				payload.Error.RequestID = requestID(resp)
+				err = payload.Error
+			}
+			send(StreamEvent{Type: payload.Type, Err: err})
+			return
+		case "message_stop":
+			send(StreamEvent{Type: payload.Type})
+			return
+		}
+	}
+}
+
+// toolBuffer collects the input of a streamed tool_use block.
+type toolBuffer struct {
+	id    string
+	name  string
+	input strings.Builder
+}
+
+// finish parses the complete input. A tool that takes no input streams none,
+// which stands for an empty object.
+func (t *toolBuffer) finish() (*ToolUse, error) {
+	input := json.RawMessage(strings.TrimSpace(t.input.String()))
+	if len(input) == 0 {
+		input = json.RawMessage("{}")
+	}
+	if !json.Valid(input) {
+		return nil, fmt.Errorf("invalid JSON")
+	}
+	return &ToolUse{ID: t.id, Name: t.name, Input: input}, nil
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// replay runs processAPIResponse over the server-sent events sse and
+// returns the events it delivers, failing if it doesn't end.
+func replay(t *testing.T, sse string, logger *log.Logger) []StreamEvent {
+	t.Helper()
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(sse))}
+	events := make(chan StreamEvent)
+	go processAPIResponse(context.Background(), resp, "", events, logger)
+
+	var got []StreamEvent
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return got
+			}
+			got = append(got, event)
+		case <-timeout:
+			t.Fatal("the stream never ended")
+		}
+	}
+}
+
+// sse formats payloads as the data lines of server-sent events.
+func sse(payloads ...string) string {
+	var b strings.Builder
+	for _, p := range payloads {
+		b.WriteString("data: " + p + "\n\n")
+	}
+	return b.String()
+}
+
+// streamedText is the text carried by events.
+func streamedText(events []StreamEvent) string {
+	var b strings.Builder
+	for _, e := range events {
+		b.WriteString(e.Text)
+	}
+	return b.String()
+}
+
+func TestCorruptDataLineIsSkipped(t *testing.T) {
+	var logged bytes.Buffer
+	events := replay(t, sse(
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_del`,
+		`[1, 2`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" world"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"message_stop"}`,
+	), log.New(&logged, "", 0))
+
+	if got := streamedText(events); got != "Hello world" {
+		t.Errorf("text = %q, want the valid chunks around the corrupt ones", got)
+	}
+	for _, e := range events {
+		if e.Err != nil {
+			t.Errorf("the stream failed: %v", e.Err)
+		}
+	}
+	if last := events[len(events)-1]; last.Type != "message_stop" {
+		t.Errorf("last event %q, want the stream to run to its end", last.Type)
+	}
+	if n := strings.Count(logged.String(), "skipping malformed stream event"); n != 2 {
+		t.Errorf("%d corrupt chunks logged, want 2:\n%s", n, logged.String())
+	}
+}
+
+func TestMultiBlockStream(t *testing.T) {
+	events := replay(t, sse(
+		`{"type":"message_start","message":{"usage":{"input_tokens":12}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Let me look."}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"search"}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"query\":"}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":" \"owls\"}"}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"content_block_start","index":2,"content_block":{"type":"text"}}`,
+		`{"type":"content_block_delta","index":2,"delta":{"type":"text_delta","text":"Found it."}}`,
+		`{"type":"content_block_stop","index":2}`,
+		`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":30}}`,
+		`{"type":"message_stop"}`,
+	), log.New(io.Discard, "", 0))
+
+	var blocks []string
+	var use *ToolUse
+	texts := map[int]string{}
+	for _, e := range events {
+		switch e.Type {
+		case "content_block_start":
+			blocks = append(blocks, e.Block)
+		case "content_block_delta":
+			if e.Block != "text" {
+				t.Errorf("delta of block %d is %q, want text", e.Index, e.Block)
+			}
+			texts[e.Index] += e.Text
+		case "content_block_stop":
+			if e.ToolUse != nil {
+				use = e.ToolUse
+			}
+		}
+	}
+	if strings.Join(blocks, ",") != "text,tool_use,text" {
+		t.Errorf("blocks = %v", blocks)
+	}
+	if texts[0] != "Let me look." || texts[2] != "Found it." || len(texts) != 2 {
+		t.Errorf("texts by block = %q", texts)
+	}
+	if use == nil || use.ID != "toolu_1" || use.Name != "search" || string(use.Input) != `{"query": "owls"}` {
+		t.Errorf("tool_use = %+v", use)
+	}
+}
+
+func TestErrorEvent(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    string
+		apiErr  bool
+	}{
+		{"with an error", `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`, "Overloaded", true},
+		{"without one", `{"type":"error"}`, `stream error event: {"type":"error"}`, false},
+		{"with a null one", `{"type":"error","error":null}`, `stream error event: {"type":"error","error":null}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := replay(t, sse(tt.payload), log.New(io.Discard, "", 0))
+			if len(events) != 1 || events[0].Type != "error" {
+				t.Fatalf("events = %+v, want one error", events)
+			}
+			err := events[0].Err
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want %q", err, tt.want)
+			}
+			var apiErr *APIError
+			if errors.As(err, &apiErr) != tt.apiErr {
+				t.Errorf("error is an *APIError: %v, want %v", !tt.apiErr, tt.apiErr)
+			}
+		})
+	}
+}
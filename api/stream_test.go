@@ -0,0 +1,44 @@
+package api_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bnema/cclui/api"
+	"github.com/bnema/cclui/api/apitest"
+)
+
+func TestStreamCancelClosesTheConnection(t *testing.T) {
+	srv := apitest.NewServer()
+	defer srv.Close()
+	srv.Push(apitest.Slow("one two three four five six seven eight", 8, 50*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := srv.Client().Stream(ctx, api.NewMessageRequest("hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for event := range events {
+		if event.Text != "" {
+			break
+		}
+	}
+
+	// Stop reading, as the UI does once the stream is stopped.
+	cancel()
+	select {
+	case <-srv.HangUps():
+	case <-time.After(2 * time.Second):
+		t.Fatal("the server never saw the connection close")
+	}
+	select {
+	case event, ok := <-events:
+		if ok {
+			t.Errorf("event delivered after cancel: %+v", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the stream was not closed after cancel")
+	}
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+var (
+	// ErrConnectTimeout is returned when the API didn't start answering
+	// within the client's ConnectTimeout.
+	ErrConnectTimeout = errors.New("timed out waiting for the API to respond")
+	// ErrIdleTimeout ends a stream that went quiet for longer than the
+	// client's IdleTimeout.
+	ErrIdleTimeout = errors.New("stream stalled")
+)
+
+// doWithConnectTimeout sends req, giving up with ErrConnectTimeout when the
+// response headers take longer than timeout to arrive. Unlike a plain
+// context deadline this leaves the body alone, so a long stream is not cut
+// short; closing the body releases the request.
+func (c *Client) doWithConnectTimeout(ctx context.Context, req *http.Request, timeout time.Duration) (*http.Response, error) {
+	if timeout <= 0 {
+		return c.HTTPClient.Do(req.WithContext(ctx))
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	timer := time.AfterFunc(timeout, func() { cancel(ErrConnectTimeout) })
+	resp, err := c.HTTPClient.Do(req.WithContext(ctx))
+	if !timer.Stop() || err != nil {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		cause := context.Cause(ctx)
+		cancel(nil)
+		if errors.Is(cause, ErrConnectTimeout) {
+			return nil, fmt.Errorf("%w after %s", ErrConnectTimeout, timeout)
+		}
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelBody releases the context of its request once closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelCauseFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel(nil)
+	return err
+}
+
+// idleBody cancels its request with ErrIdleTimeout when no data arrived for
+// timeout, and reports that as the read error.
+type idleBody struct {
+	io.ReadCloser
+	ctx     context.Context
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+func newIdleBody(ctx context.Context, cancel context.CancelCauseFunc, body io.ReadCloser, timeout time.Duration) *idleBody {
+	return &idleBody{
+		ReadCloser: body,
+		ctx:        ctx,
+		timer:      time.AfterFunc(timeout, func() { cancel(ErrIdleTimeout) }),
+		timeout:    timeout,
+	}
+}
+
+func (b *idleBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.timer.Reset(b.timeout)
+	}
+	if err != nil && errors.Is(context.Cause(b.ctx), ErrIdleTimeout) {
+		err = fmt.Errorf("%w: nothing received for %s", ErrIdleTimeout, b.timeout)
+	}
+	return n, err
+}
+
+func (b *idleBody) Close() error {
+	b.timer.Stop()
+	return b.ReadCloser.Close()
+}
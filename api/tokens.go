@@ -0,0 +1,153 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"unicode"
+	"unicode/utf8"
+)
+
+// EstimateTokens approximates how many tokens Claude's tokenizer splits
+// text into, without a vocabulary. Like a BPE pre-tokenizer it cuts text
+// into words, numbers, punctuation and whitespace, then prices each piece:
+// a word is a token per six letters or so, with its leading space merged
+// in; a number is a token per three digits; runs of punctuation or spaces
+// merge in twos and fours; and letters outside Latin scripts, such as CJK
+// or emoji, cost a token each. It does not allocate, so it is cheap enough
+// to run on every keystroke; CountTokens gives the exact count.
+func EstimateTokens(text string) int {
+	tokens := 0
+	for i := 0; i < len(text); {
+		kind, size := kindAt(text, i)
+		if kind == kindOther || kind == kindNewline {
+			tokens++
+			i += size
+			continue
+		}
+
+		n := 0
+		for i < len(text) {
+			k, size := kindAt(text, i)
+			if k != kind {
+				break
+			}
+			i += size
+			n++
+		}
+		switch kind {
+		case kindLetter:
+			tokens += (n + 5) / 6
+		case kindDigit:
+			tokens += (n + 2) / 3
+		case kindPunct:
+			tokens += (n + 1) / 2
+		case kindSpace:
+			// A single space goes with the word after it.
+			if n > 1 || i == len(text) || kindOf(text, i) != kindLetter {
+				tokens += (n + 3) / 4
+			}
+		}
+	}
+	return tokens
+}
+
+type tokenKind uint8
+
+const (
+	kindOther tokenKind = iota
+	kindLetter
+	kindDigit
+	kindPunct
+	kindSpace
+	kindNewline
+	// kindMultibyte marks the first byte of a multibyte rune, classified by
+	// runeKindAt.
+	kindMultibyte
+)
+
+// byteKinds classifies ASCII bytes, which make up most text, without
+// decoding them.
+var byteKinds = func() (kinds [256]tokenKind) {
+	for c := range kinds {
+		switch {
+		case c >= utf8.RuneSelf:
+			kinds[c] = kindMultibyte
+		case c == '\n':
+			kinds[c] = kindNewline
+		case c == ' ' || c == '\t':
+			kinds[c] = kindSpace
+		case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			kinds[c] = kindLetter
+		case c >= '0' && c <= '9':
+			kinds[c] = kindDigit
+		default:
+			kinds[c] = kindPunct
+		}
+	}
+	return kinds
+}()
+
+// kindAt classifies the rune at text[i] and returns its size.
+func kindAt(text string, i int) (tokenKind, int) {
+	if k := byteKinds[text[i]]; k != kindMultibyte {
+		return k, 1
+	}
+	return runeKindAt(text, i)
+}
+
+func kindOf(text string, i int) tokenKind {
+	k, _ := kindAt(text, i)
+	return k
+}
+
+func runeKindAt(text string, i int) (tokenKind, int) {
+	r, size := utf8.DecodeRuneInString(text[i:])
+	switch {
+	case r <= 0x024F && unicode.IsLetter(r):
+		// Latin-1 and Latin Extended letters, as in most European languages.
+		return kindLetter, size
+	case unicode.IsSpace(r):
+		return kindSpace, size
+	}
+	return kindOther, size
+}
+
+// CountTokens asks the API how many input tokens req amounts to, through
+// the count_tokens endpoint. Unlike EstimateTokens it is exact, but it is a
+// round trip.
+func (c *Client) CountTokens(ctx context.Context, req MessageRequest) (int, error) {
+	body, err := json.Marshal(struct {
+		Model      string          `json:"model"`
+		System     string          `json:"system,omitempty"`
+		Thinking   *Thinking       `json:"thinking,omitempty"`
+		Tools      []Tool          `json:"tools,omitempty"`
+		ToolChoice *ToolChoice     `json:"tool_choice,omitempty"`
+		Messages   []MessageToSend `json:"messages"`
+	}{req.Model, req.System, req.Thinking, req.Tools, req.ToolChoice, req.Messages})
+	if err != nil {
+		return 0, err
+	}
+
+	httpReq, err := c.newRequest("POST", "/v1/messages/count_tokens", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.HTTPClient.Do(httpReq.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, newAPIError(resp)
+	}
+	var count struct {
+		InputTokens int `json:"input_tokens"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&count); err != nil {
+		return 0, err
+	}
+	return count.InputTokens, nil
+}
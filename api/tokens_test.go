@@ -0,0 +1,55 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+// sampleText is a mix of what a context holds: prose, code, numbers and
+// text in other scripts.
+var sampleText = strings.Repeat("The quick brown fox jumps over the lazy dog, 1234567 times.\n"+
+	"func main() { fmt.Println(\"hello, world\") }\n"+
+	"日本語のテキストと絵文字 🦊🐶\n", 200)
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want int
+	}{
+		{"nothing", "", 0},
+		{"a word", "hello", 1},
+		{"a long word, a token per six letters", "internationalization", 4},
+		{"prose, spaces merged into the words", "The quick brown fox jumps over the lazy dog.", 10},
+		{"accented Latin letters", "café déjà vu", 3},
+		{"digits, a token per three", "1234567", 3},
+		{"a space before a number is a token of its own", "in 2026", 4},
+		{"a call", `fmt.Println("hi")`, 7},
+		{"a block of code", "if x {\n\treturn 1\n}", 10},
+		{"punctuation in twos", "...!!!", 3},
+		{"CJK, a token a character", "日本語", 3},
+		{"emoji", "🦊🐶", 2},
+		{"a run of spaces, in fours", "a        b", 4},
+		{"trailing spaces", "a   ", 2},
+		{"newlines", "\n\n\n", 3},
+	}
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.text); got != tt.want {
+			t.Errorf("%s: EstimateTokens(%q) = %d, want %d", tt.name, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestEstimateTokensDoesNotAllocate(t *testing.T) {
+	if allocs := testing.AllocsPerRun(10, func() { EstimateTokens(sampleText) }); allocs != 0 {
+		t.Errorf("EstimateTokens allocates %v times per call", allocs)
+	}
+}
+
+func BenchmarkEstimateTokens(b *testing.B) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(sampleText)))
+	for i := 0; i < b.N; i++ {
+		EstimateTokens(sampleText)
+	}
+}
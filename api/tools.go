@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Tool declares a tool Claude may call, as sent in the tools field of a
+// request. InputSchema is the JSON schema of its input.
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+// ToolChoice tells Claude whether to use the declared tools: "auto" lets it
+// decide, "any" forces it to use one of them, "tool" forces the one in
+// Name and "none" forbids them. DisableParallelToolUse keeps Claude to one
+// tool call per reply; "none" does not take it.
+type ToolChoice struct {
+	Type                   string `json:"type"`
+	Name                   string `json:"name,omitempty"`
+	DisableParallelToolUse bool   `json:"disable_parallel_tool_use,omitempty"`
+}
+
+// String is the inverse of ParseToolChoice.
+func (c *ToolChoice) String() string {
+	switch {
+	case c == nil:
+		return "auto"
+	case c.Type == "tool":
+		return c.Name
+	}
+	return c.Type
+}
+
+// ParseToolChoice reads a tool choice: auto, any, none or the name of one of
+// tools. Choices that need a tool fail when none is declared.
+func ParseToolChoice(v string, tools []Tool) (*ToolChoice, error) {
+	switch v {
+	case "auto", "none":
+		return &ToolChoice{Type: v}, nil
+	case "any":
+		if len(tools) == 0 {
+			return nil, fmt.Errorf("tool_choice any needs declared tools")
+		}
+		return &ToolChoice{Type: v}, nil
+	}
+	for _, t := range tools {
+		if t.Name == v {
+			return &ToolChoice{Type: "tool", Name: v}, nil
+		}
+	}
+	return nil, fmt.Errorf("tool_choice must be auto, any, none or a declared tool, got %q", v)
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// defaultTransport shares the connections of http.DefaultTransport, which
+// takes the proxy from the environment.
+var defaultTransport = &clientTransport{http.DefaultTransport.(*http.Transport)}
+
+// NewTransport returns the transport of the API client. Requests go through
+// proxy, or when it is nil through the proxy named by the HTTPS_PROXY,
+// HTTP_PROXY and NO_PROXY variables, as with http.DefaultTransport. A nil
+// tlsConfig verifies servers against the system's certificates.
+func NewTransport(proxy *url.URL, tlsConfig *tls.Config) http.RoundTripper {
+	if proxy == nil && tlsConfig == nil {
+		return defaultTransport
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy != nil {
+		t.Proxy = http.ProxyURL(proxy)
+	}
+	if tlsConfig != nil {
+		t.TLSClientConfig = tlsConfig
+	}
+	return &clientTransport{t}
+}
+
+// clientTransport explains the connection failures net/http reports
+// tersely: a proxy that can't be reached, which is only a failed
+// "proxyconnect" dial, and a certificate of an unknown authority.
+type clientTransport struct {
+	*http.Transport
+}
+
+func (t *clientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.Transport.RoundTrip(req)
+	if err == nil {
+		return resp, nil
+	}
+	var op *net.OpError
+	if errors.As(err, &op) && op.Op == "proxyconnect" {
+		if proxy, _ := t.Proxy(req); proxy != nil {
+			return nil, fmt.Errorf("can't connect to the proxy %s: %w", proxy.Redacted(), err)
+		}
+	}
+	var authority x509.UnknownAuthorityError
+	if errors.As(err, &authority) {
+		return nil, fmt.Errorf("%w (if %s uses a private CA, point ca_file at its certificate)", err, req.URL.Host)
+	}
+	return resp, err
+}
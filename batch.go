@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bnema/cclui/api"
+	"github.com/bnema/cclui/config"
+)
+
+// batchAttempts is how many times a prompt of a batch is sent while the
+// API answers that it is rate limited or overloaded.
+const batchAttempts = 5
+
+// readBatch reads the prompts of a batch file: a JSON array of strings, or
+// else one prompt per line, blank lines skipped.
+func readBatch(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var prompts []string
+	if text := bytes.TrimSpace(data); bytes.HasPrefix(text, []byte("[")) {
+		if err := json.Unmarshal(text, &prompts); err != nil {
+			return nil, fmt.Errorf("%s: expected a JSON array of strings: %w", path, err)
+		}
+	} else {
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				prompts = append(prompts, line)
+			}
+		}
+	}
+	if len(prompts) == 0 {
+		return nil, fmt.Errorf("%s: no prompts", path)
+	}
+	return prompts, nil
+}
+
+// batchDir is where the answers to the batch in path go by default: next
+// to it, named after it with a .out extension.
+func batchDir(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".out"
+}
+
+// runBatch sends the prompts of a batch file one after the other and writes
+// each answer to its own file in dir, numbered after the prompt, or the
+// full API response with jsonOutput. Files and normalize apply to every
+// prompt as in runOneShot. Progress goes to stderr. A prompt that fails is
+// reported and skipped; the error returned then lists them all.
+func runBatch(client api.Provider, params api.MessageRequest, path, dir string, files []string, normalize, jsonOutput bool) error {
+	prompts, err := readBatch(path)
+	if err != nil {
+		return err
+	}
+	if dir == "" {
+		dir = batchDir(path)
+	}
+	if err := config.EnsureDir(dir); err != nil {
+		return err
+	}
+
+	ext := ".txt"
+	if jsonOutput {
+		ext = ".json"
+	}
+	width := len(fmt.Sprint(len(prompts)))
+	var failed []string
+	for i, prompt := range prompts {
+		n := fmt.Sprintf("%0*d", width, i+1)
+		progress := fmt.Sprintf("[%d/%d]", i+1, len(prompts))
+		start := time.Now()
+		out, err := answerBatchPrompt(client, params, prompt, files, normalize, jsonOutput, progress)
+		if err == nil {
+			err = os.WriteFile(filepath.Join(dir, n+ext), out, 0o600)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s failed: %v\n", progress, err)
+			failed = append(failed, n)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "%s %s (%.1fs)\n", progress, filepath.Join(dir, n+ext), time.Since(start).Seconds())
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d prompts failed: %s", len(failed), len(prompts), strings.Join(failed, ", "))
+	}
+	fmt.Fprintf(os.Stderr, "%d answers in %s\n", len(prompts), dir)
+	return nil
+}
+
+// answerBatchPrompt sends one prompt of a batch and returns what to write
+// for it. While the API is rate limited or overloaded it waits, as long as
+// the API asks or with a growing backoff, and tries again.
+func answerBatchPrompt(client api.Provider, params api.MessageRequest, prompt string, files []string, normalize, jsonOutput bool, progress string) ([]byte, error) {
+	prompt, err := withFiles(prompt, files, normalize)
+	if err != nil {
+		return nil, err
+	}
+	params.Messages = []api.MessageToSend{api.ConstructUserMessage(prompt)}
+
+	var resp *api.Response
+	backoff := 2 * time.Second
+	for attempt := 1; ; attempt++ {
+		resp, err = client.CreateMessage(context.Background(), params)
+		var apiErr *api.APIError
+		if err == nil || attempt == batchAttempts || !errors.As(err, &apiErr) || !apiErr.Temporary() {
+			break
+		}
+		wait := apiErr.RetryAfter
+		if wait <= 0 {
+			wait = backoff
+			backoff *= 2
+		}
+		fmt.Fprintf(os.Stderr, "%s %s, trying again in %s\n", progress, apiErr.Type, wait)
+		time.Sleep(wait)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.Warning != "" {
+		fmt.Fprintln(os.Stderr, progress, "warning:", resp.Warning)
+	}
+	if resp.StopReason == "max_tokens" {
+		fmt.Fprintf(os.Stderr, "%s warning: the response was cut off at max_tokens (%d)\n", progress, params.MaxTokens)
+	}
+
+	if !jsonOutput {
+		return []byte(resp.Text() + "\n"), nil
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, resp.Raw, "", "  "); err != nil {
+		return nil, err
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
@@ -0,0 +1,219 @@
+// Package config locates cclui's files on disk.
+//
+// Configuration lives in $XDG_CONFIG_HOME/cclui, saved conversations in
+// $XDG_DATA_HOME/cclui, logs and input history in $XDG_STATE_HOME/cclui and
+// disposable files in $XDG_CACHE_HOME/cclui, with the usual per-platform
+// fallbacks when those variables are unset. Setting CCLUI_CONFIG_DIR moves
+// everything under that one directory instead.
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const appName = "cclui"
+
+// Paths lists every location cclui reads or writes.
+type Paths struct {
+	Config        string
+	Templates     string
+	Conversations string
+	Archive       string
+	Logs          string
+	History       string
+	LastSession   string
+	Cache         string
+}
+
+// Dir returns the directory holding cclui's configuration files.
+func Dir() (string, error) {
+	if dir := os.Getenv("CCLUI_CONFIG_DIR"); dir != "" {
+		return dir, nil
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, appName), nil
+	}
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, appName), nil
+}
+
+// dataDir returns the base directory for files worth keeping, such as saved
+// conversations.
+func dataDir() (string, error) {
+	return baseDir("XDG_DATA_HOME", "data", filepath.Join(".local", "share"))
+}
+
+// stateDir returns the base directory for logs and history.
+func stateDir() (string, error) {
+	return baseDir("XDG_STATE_HOME", "state", filepath.Join(".local", "state"))
+}
+
+func cacheDir() (string, error) {
+	if dir := os.Getenv("CCLUI_CONFIG_DIR"); dir != "" {
+		return filepath.Join(dir, "cache"), nil
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, appName), nil
+}
+
+// baseDir resolves an XDG base directory. Unix systems fall back to
+// homeRel under the home directory; macOS and Windows keep everything next
+// to the configuration, in a sub directory named sub.
+func baseDir(env, sub, homeRel string) (string, error) {
+	if dir := os.Getenv("CCLUI_CONFIG_DIR"); dir != "" {
+		return filepath.Join(dir, sub), nil
+	}
+	if dir := os.Getenv(env); dir != "" {
+		return filepath.Join(dir, appName), nil
+	}
+	switch runtime.GOOS {
+	case "windows", "darwin":
+		dir, err := Dir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, sub), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, homeRel, appName), nil
+}
+
+// ConversationsDir returns the directory saved conversations are kept in.
+func ConversationsDir() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "conversations"), nil
+}
+
+// ArchiveDir returns the directory the messages archived out of long
+// transcripts are written to, see max_transcript.
+func ArchiveDir() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "archive"), nil
+}
+
+// TemplatesDir returns the directory prompt templates are read from.
+func TemplatesDir() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "templates"), nil
+}
+
+// LogsDir returns the directory log files are written to.
+func LogsDir() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "logs"), nil
+}
+
+// HistoryFile returns the file the input history is kept in.
+func HistoryFile() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "history"), nil
+}
+
+// LastSessionFile returns the file naming the conversation saved last,
+// which -continue resumes.
+func LastSessionFile() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "last-session"), nil
+}
+
+// ResolvePaths returns every location at once, without creating anything.
+func ResolvePaths() (Paths, error) {
+	var (
+		p   Paths
+		err error
+	)
+	for _, f := range []struct {
+		dst     *string
+		resolve func() (string, error)
+	}{
+		{&p.Config, Dir},
+		{&p.Templates, TemplatesDir},
+		{&p.Conversations, ConversationsDir},
+		{&p.Archive, ArchiveDir},
+		{&p.Logs, LogsDir},
+		{&p.History, HistoryFile},
+		{&p.LastSession, LastSessionFile},
+		{&p.Cache, cacheDir},
+	} {
+		if *f.dst, err = f.resolve(); err != nil {
+			return Paths{}, err
+		}
+	}
+	return p, nil
+}
+
+// EnsureDir creates dir, and its parents, readable only by the user. It is
+// called right before writing so nothing is created until needed.
+func EnsureDir(dir string) error {
+	return os.MkdirAll(dir, 0o700)
+}
+
+// windowsReserved are device names Windows refuses as file names, with or
+// without an extension.
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// ValidName reports whether name can be used as the base name of a file
+// cclui manages, such as a template or a saved conversation. The rules are
+// the same on every platform so that files can be moved between them.
+func ValidName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("name is empty")
+	case strings.HasPrefix(name, "."):
+		return fmt.Errorf("invalid name %q: must not start with a dot", name)
+	case strings.ContainsAny(name, `/\:*?"<>|`):
+		return fmt.Errorf(`invalid name %q: must not contain any of / \ : * ? " < > |`, name)
+	case strings.HasSuffix(name, " "):
+		return fmt.Errorf("invalid name %q: must not end with a space", name)
+	case strings.HasSuffix(name, "."):
+		// Windows drops it, so the file would lose its .json.
+		return fmt.Errorf("invalid name %q: must not end with a dot", name)
+	}
+	for _, r := range name {
+		if r < 0x20 {
+			return fmt.Errorf("invalid name %q: contains control characters", name)
+		}
+	}
+	base, _, _ := strings.Cut(name, ".")
+	if windowsReserved[strings.ToUpper(base)] {
+		return fmt.Errorf("invalid name %q: reserved on Windows", name)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidName(t *testing.T) {
+	tests := []struct {
+		name string
+		err  string
+	}{
+		{"notes", ""},
+		{"notes.v2", ""},
+		{"my notes", ""},
+		{"", "name is empty"},
+		{".hidden", "must not start with a dot"},
+		{"a/b", "must not contain"},
+		{"notes ", "must not end with a space"},
+		{"notes.", "must not end with a dot"},
+		{"notes..", "must not end with a dot"},
+		{"a\tb", "contains control characters"},
+		{"con", "reserved on Windows"},
+		{"LPT1.txt", "reserved on Windows"},
+	}
+	for _, tt := range tests {
+		err := ValidName(tt.name)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("ValidName(%q) = %v, want it valid", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("ValidName(%q) = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/bnema/cclui/api"
+	"gopkg.in/yaml.v3"
+)
+
+// Persona is a named system prompt along with the request parameters it
+// goes with, declared under personas in the config file. Parameters left
+// out keep those of the configuration.
+type Persona struct {
+	System         string
+	Model          string
+	MaxTokens      int
+	Temperature    *float64
+	TopP           *float64
+	ThinkingBudget int
+	ServiceTier    string
+}
+
+// personaFields are the keys of a persona in the config file. They parse
+// like the settings of the same name.
+type personaFields struct {
+	System         string `yaml:"system"`
+	Model          string `yaml:"model"`
+	MaxTokens      string `yaml:"max_tokens"`
+	Temperature    string `yaml:"temperature"`
+	TopP           string `yaml:"top_p"`
+	ThinkingBudget string `yaml:"thinking_budget"`
+	ServiceTier    string `yaml:"service_tier"`
+}
+
+// parsePersonas reads the personas mapping of the config file, by name.
+func parsePersonas(node yaml.Node) (map[string]Persona, error) {
+	var fields map[string]personaFields
+	if err := node.Decode(&fields); err != nil {
+		return nil, fmt.Errorf("personas: %w", err)
+	}
+	personas := make(map[string]Persona, len(fields))
+	for name, f := range fields {
+		if err := ValidName(name); err != nil {
+			return nil, fmt.Errorf("personas: %w", err)
+		}
+		p := Persona{System: f.System, Model: f.Model, ServiceTier: f.ServiceTier}
+		var err error
+		if f.MaxTokens != "" {
+			p.MaxTokens, err = ParseMaxTokens(f.MaxTokens)
+		}
+		if err == nil && f.Temperature != "" {
+			p.Temperature, err = ParseUnit("temperature", f.Temperature)
+		}
+		if err == nil && f.TopP != "" {
+			p.TopP, err = ParseUnit("top_p", f.TopP)
+		}
+		if err == nil && f.ThinkingBudget != "" {
+			p.ThinkingBudget, err = ParseThinkingBudget(f.ThinkingBudget)
+		}
+		if err == nil && f.ServiceTier != "" {
+			err = api.CheckServiceTier(f.ServiceTier)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("personas: %s: %w", name, err)
+		}
+		personas[name] = p
+	}
+	return personas, nil
+}
+
+// PersonaNames returns the names of the personas, sorted.
+func (c *Config) PersonaNames() []string {
+	names := make([]string, 0, len(c.Personas))
+	for name := range c.Personas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PersonaRequest returns the request settings of persona name: those of
+// Request, with the system prompt and the parameters the persona sets.
+func (c *Config) PersonaRequest(name string) (api.MessageRequest, error) {
+	p, ok := c.Personas[name]
+	if !ok {
+		return api.MessageRequest{}, fmt.Errorf("no persona %q in %s", name, c.File)
+	}
+	req := c.Request()
+	req.System = p.System
+	if p.Model != "" {
+		req.Model = p.Model
+	}
+	if p.MaxTokens > 0 {
+		req.MaxTokens = p.MaxTokens
+	}
+	if p.Temperature != nil {
+		req.Temperature = p.Temperature
+	}
+	if p.TopP != nil {
+		req.TopP = p.TopP
+	}
+	if p.ThinkingBudget > 0 {
+		req.Thinking = &api.Thinking{Type: "enabled", BudgetTokens: p.ThinkingBudget}
+	}
+	if p.ServiceTier != "" {
+		req.ServiceTier = p.ServiceTier
+	}
+	budget := 0
+	if req.Thinking != nil {
+		budget = req.Thinking.BudgetTokens
+	}
+	if err := CheckThinking(budget, req.MaxTokens, req.Temperature); err != nil {
+		return api.MessageRequest{}, fmt.Errorf("persona %s: %w", name, err)
+	}
+	return req, nil
+}
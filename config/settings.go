@@ -0,0 +1,1227 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bnema/cclui/api"
+	"gopkg.in/yaml.v3"
+)
+
+// Source says where the value of a setting came from.
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+)
+
+// Settings are the values resolved from every source.
+type Settings struct {
+	APIKey  string
+	BaseURL string
+	// Proxy is the proxy the API is reached through, if not the one of the
+	// environment.
+	Proxy *url.URL
+	// CAFile adds the PEM certificates it holds to the system's when
+	// verifying the API server, relative to the config directory unless
+	// absolute. InsecureSkipVerify doesn't verify the server at all.
+	CAFile             string
+	InsecureSkipVerify bool
+	Version            string
+	Model              string
+	MaxTokens          int
+	System             string
+	Temperature        *float64
+	TopP               *float64
+	// ThinkingBudget enables extended thinking when positive.
+	ThinkingBudget int
+	// ServiceTier is sent as service_tier, auto or standard_only, unless
+	// empty.
+	ServiceTier string
+	// AutosaveTurns and AutosaveMinutes save the conversation every so many
+	// turns or minutes; 0 turns either off.
+	AutosaveTurns   int
+	AutosaveMinutes int
+	// AutoTitle is how /save without a name names the conversation: "time"
+	// by the time, "first-line" by its first message or "claude" by a
+	// title Claude gives it.
+	AutoTitle string
+	// Prices overrides the built-in prices, in dollars per million tokens,
+	// by model.
+	Prices map[string]Price
+	// ConnectTimeout and IdleTimeout limit the wait for a reply to start
+	// streaming and the gaps within it; 0 means no limit.
+	ConnectTimeout time.Duration
+	IdleTimeout    time.Duration
+	// Reconnects is how many times a reply whose stream drops is picked up
+	// where it stopped; 0 turns it off.
+	Reconnects int
+	// IdleDisconnect closes the connections to the API once the session
+	// has been idle that long; 0 keeps them open.
+	IdleDisconnect time.Duration
+	// WrapWidth wraps the transcript at that many columns, when narrower
+	// than the terminal, in a column aligned per WrapAlign: "left" or
+	// "center".
+	WrapWidth int
+	WrapAlign string
+	// WideTables is what happens to a Markdown table wider than the
+	// transcript: "wrap" its cells or "clip" its lines.
+	WideTables string
+	// Continue resumes the conversation saved last on startup.
+	Continue bool
+	// Banner shows the startup banner at the top of the transcript.
+	Banner bool
+	// Opener is shown as the assistant's first message on startup. It is
+	// only sent to the API, in the system prompt, with OpenerContext.
+	Opener        string
+	OpenerContext bool
+	// AllowShell enables the ! and !! shell commands.
+	AllowShell bool
+	// ShowRequestID shows the request-id of each reply under it.
+	ShowRequestID bool
+	// ShowTokensPerSecond shows the output throughput of each reply under
+	// it.
+	ShowTokensPerSecond bool
+	// ShowClock shows how long the session has lasted and when it was last
+	// active in the status line.
+	ShowClock bool
+	// ExitSummary prints a line summing up the session to stderr on exit.
+	ExitSummary bool
+	// NormalizeInput normalizes line endings and trims trailing whitespace
+	// of messages before they are sent.
+	NormalizeInput bool
+	// SendGuard is how long after a message is sent Enter doesn't send
+	// another; 0 turns it off.
+	SendGuard time.Duration
+	// ConfirmTokens is the estimated size of a request, in input tokens,
+	// past which sending it asks for confirmation first; 0 never asks.
+	ConfirmTokens int
+	// Editor is the input's key scheme: "default" or "vim".
+	Editor string
+	// CopyFormat is how /copy renders text: "markdown" or "text".
+	CopyFormat string
+	// ToolsFile is a JSON file declaring the tools sent with requests,
+	// relative to the config directory unless absolute. ToolChoice is
+	// auto, any, none or the name of one of them.
+	ToolsFile  string
+	ToolChoice string
+	// DisableParallelToolUse asks for at most one tool call per reply. It
+	// needs declared tools and a tool choice other than none.
+	DisableParallelToolUse bool
+	// WebhookURL is POSTed each finished reply as JSON, and with
+	// WebhookChunks each piece of it as it streams. A post may take
+	// WebhookTimeout.
+	WebhookURL     string
+	WebhookChunks  bool
+	WebhookTimeout time.Duration
+	// StreamReveal is how many words per second of a streamed reply are
+	// revealed; 0 shows text as soon as it arrives.
+	StreamReveal int
+	// MaxTranscript caps the number of messages the transcript shows; older
+	// ones are archived to disk until /older brings them back. 0 shows them
+	// all.
+	MaxTranscript int
+	// CollapseLines is the height, in lines, past which a reply is
+	// collapsed in the transcript until expanded. 0 never collapses.
+	CollapseLines int
+	// JSONRetries is how many times a reply that doesn't match the schema
+	// of /json is sent back for correction.
+	JSONRetries int
+	// PostProcess is a shell command each finished reply is piped through;
+	// its output replaces the reply on screen, or with PostProcessMode
+	// "append" is shown under it. PostProcessTimeout bounds its run.
+	PostProcess        string
+	PostProcessMode    string
+	PostProcessTimeout time.Duration
+	// Roles holds the label and color of each transcript role, see Roles.
+	Roles map[string]Role
+}
+
+// Price is what a model costs in dollars per million input and output
+// tokens.
+type Price struct {
+	Input  float64
+	Output float64
+}
+
+// Roles lists the transcript roles whose look can be configured; system is
+// cclui's own notes.
+var Roles = []string{"user", "assistant", "system", "error"}
+
+// Role is how the messages of a role are shown. Color is an ANSI color
+// number or a #rrggbb hex color; empty leaves the terminal's default.
+type Role struct {
+	Label string
+	Color string
+}
+
+// Config is the resolved configuration along with where each setting came
+// from, keyed by its name in the config file.
+type Config struct {
+	Settings
+	Sources map[string]Source
+	// File is the config file that was looked for; it may not exist.
+	File string
+	// Tools are the tools declared in ToolsFile.
+	Tools []api.Tool
+	// Personas are the personas of the config file, by name.
+	Personas map[string]Persona
+	// SystemVars are the variables of the system prompt declared under
+	// system_vars in the config file, by name.
+	SystemVars map[string]string
+	// Verbosity are the instructions of the /verbosity levels declared
+	// under verbosity in the config file, by level.
+	Verbosity map[string]string
+	// RootCAs are the system's certificates and those of CAFile, nil
+	// without a CAFile.
+	RootCAs *x509.CertPool
+	// flags are the flags that were set, by name, for Reload.
+	flags map[string]string
+}
+
+// InsecureWarning is shown whenever insecure_skip_verify is on.
+const InsecureWarning = "TLS certificate verification is off (insecure_skip_verify): anyone between you and the API can read and change the traffic, API key included."
+
+// setting describes one configurable value and the names it goes by in
+// each source. An empty env or flag name means the source can't set it.
+type setting struct {
+	key    string
+	env    string
+	flag   string
+	usage  string
+	secret bool
+	// boolean settings take a flag without a value.
+	boolean bool
+	set     func(*Settings, string) error
+	get     func(Settings) string
+}
+
+var settings = append([]setting{
+	{
+		key: "api_key", env: "ANTHROPIC_API_KEY", secret: true,
+		set: func(s *Settings, v string) error { s.APIKey = v; return nil },
+		get: func(s Settings) string { return s.APIKey },
+	},
+	{
+		key: "base_url", env: "ANTHROPIC_BASE_URL", flag: "base-url", usage: "API base URL",
+		set: func(s *Settings, v string) error { s.BaseURL = v; return nil },
+		get: func(s Settings) string { return s.BaseURL },
+	},
+	{
+		key: "proxy", env: "CCLUI_PROXY", flag: "proxy", usage: "proxy URL for the API, e.g. http://proxy:3128; defaults to $HTTPS_PROXY",
+		set: func(s *Settings, v string) error {
+			u, err := ParseProxy(v)
+			s.Proxy = u
+			return err
+		},
+		get: func(s Settings) string {
+			if s.Proxy == nil {
+				return ""
+			}
+			return s.Proxy.Redacted()
+		},
+	},
+	{
+		key: "ca_file", env: "CCLUI_CA_FILE", flag: "ca-file", usage: "PEM file of extra CA certificates to trust for the API",
+		set: func(s *Settings, v string) error { s.CAFile = v; return nil },
+		get: func(s Settings) string { return s.CAFile },
+	},
+	{
+		key: "insecure_skip_verify", env: "CCLUI_INSECURE_SKIP_VERIFY", flag: "insecure-skip-verify", usage: "don't verify the TLS certificate of the API (unsafe)", boolean: true,
+		set: func(s *Settings, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("insecure_skip_verify must be true or false, got %q", v)
+			}
+			s.InsecureSkipVerify = b
+			return nil
+		},
+		get: func(s Settings) string { return strconv.FormatBool(s.InsecureSkipVerify) },
+	},
+	{
+		key: "anthropic_version", env: "ANTHROPIC_VERSION", flag: "api-version", usage: "anthropic-version header",
+		set: func(s *Settings, v string) error { s.Version = v; return nil },
+		get: func(s Settings) string { return s.Version },
+	},
+	{
+		key: "model", env: "CCLUI_MODEL", flag: "model", usage: "model to use",
+		set: func(s *Settings, v string) error { s.Model = v; return nil },
+		get: func(s Settings) string { return s.Model },
+	},
+	{
+		key: "max_tokens", env: "CCLUI_MAX_TOKENS", flag: "max-tokens", usage: "maximum tokens per response",
+		set: func(s *Settings, v string) error {
+			n, err := ParseMaxTokens(v)
+			s.MaxTokens = n
+			return err
+		},
+		get: func(s Settings) string { return strconv.Itoa(s.MaxTokens) },
+	},
+	{
+		key: "system", env: "CCLUI_SYSTEM", flag: "system", usage: "system prompt",
+		set: func(s *Settings, v string) error { s.System = v; return nil },
+		get: func(s Settings) string { return s.System },
+	},
+	{
+		key: "temperature", env: "CCLUI_TEMPERATURE", flag: "temperature", usage: "sampling temperature, 0 to 1",
+		set: func(s *Settings, v string) error {
+			f, err := ParseUnit("temperature", v)
+			s.Temperature = f
+			return err
+		},
+		get: func(s Settings) string { return formatOptional(s.Temperature) },
+	},
+	{
+		key: "top_p", env: "CCLUI_TOP_P", flag: "top-p", usage: "nucleus sampling, 0 to 1",
+		set: func(s *Settings, v string) error {
+			f, err := ParseUnit("top_p", v)
+			s.TopP = f
+			return err
+		},
+		get: func(s Settings) string { return formatOptional(s.TopP) },
+	},
+	{
+		key: "thinking_budget", env: "CCLUI_THINKING_BUDGET", flag: "thinking-budget", usage: "tokens for extended thinking, 0 for off",
+		set: func(s *Settings, v string) error {
+			n, err := ParseThinkingBudget(v)
+			s.ThinkingBudget = n
+			return err
+		},
+		get: func(s Settings) string { return strconv.Itoa(s.ThinkingBudget) },
+	},
+	{
+		key: "service_tier", env: "CCLUI_SERVICE_TIER", flag: "service-tier", usage: "service tier: auto or standard_only",
+		set: func(s *Settings, v string) error {
+			if err := api.CheckServiceTier(v); err != nil {
+				return fmt.Errorf("service_tier must be auto or standard_only, got %q", v)
+			}
+			s.ServiceTier = v
+			return nil
+		},
+		get: func(s Settings) string { return s.ServiceTier },
+	},
+	{
+		key: "autosave_turns", env: "CCLUI_AUTOSAVE_TURNS", flag: "autosave-turns", usage: "autosave every N turns, 0 for off",
+		set: func(s *Settings, v string) error {
+			n, err := ParseCount("autosave_turns", v)
+			s.AutosaveTurns = n
+			return err
+		},
+		get: func(s Settings) string { return strconv.Itoa(s.AutosaveTurns) },
+	},
+	{
+		key: "autosave_minutes", env: "CCLUI_AUTOSAVE_MINUTES", flag: "autosave-minutes", usage: "autosave every N minutes, 0 for off",
+		set: func(s *Settings, v string) error {
+			n, err := ParseCount("autosave_minutes", v)
+			s.AutosaveMinutes = n
+			return err
+		},
+		get: func(s Settings) string { return strconv.Itoa(s.AutosaveMinutes) },
+	},
+	{
+		key: "auto_title", env: "CCLUI_AUTO_TITLE", flag: "auto-title", usage: "how /save without a name names the conversation: time, first-line or claude",
+		set: func(s *Settings, v string) error {
+			if v != "time" && v != "first-line" && v != "claude" {
+				return fmt.Errorf("auto_title must be time, first-line or claude, got %q", v)
+			}
+			s.AutoTitle = v
+			return nil
+		},
+		get: func(s Settings) string { return s.AutoTitle },
+	},
+	{
+		key: "prices", env: "CCLUI_PRICES", flag: "prices", usage: "model prices per million tokens, as model=input/output,...",
+		set: func(s *Settings, v string) error {
+			p, err := ParsePrices(v)
+			s.Prices = p
+			return err
+		},
+		get: func(s Settings) string { return formatPrices(s.Prices) },
+	},
+	{
+		key: "continue", env: "CCLUI_CONTINUE", flag: "continue", usage: "resume the conversation saved last", boolean: true,
+		set: func(s *Settings, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("continue must be true or false, got %q", v)
+			}
+			s.Continue = b
+			return nil
+		},
+		get: func(s Settings) string { return strconv.FormatBool(s.Continue) },
+	},
+	{
+		key: "webhook_url", env: "CCLUI_WEBHOOK_URL", flag: "webhook-url", usage: "URL each finished reply is POSTed to as JSON",
+		set: func(s *Settings, v string) error {
+			u, err := ParseWebhook(v)
+			if err != nil {
+				return err
+			}
+			s.WebhookURL = u
+			return nil
+		},
+		get: func(s Settings) string { return redactURL(s.WebhookURL) },
+	},
+	{
+		key: "webhook_chunks", env: "CCLUI_WEBHOOK_CHUNKS", flag: "webhook-chunks", usage: "also POST each piece of a reply to webhook_url as it streams", boolean: true,
+		set: func(s *Settings, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("webhook_chunks must be true or false, got %q", v)
+			}
+			s.WebhookChunks = b
+			return nil
+		},
+		get: func(s Settings) string { return strconv.FormatBool(s.WebhookChunks) },
+	},
+	{
+		key: "webhook_timeout", env: "CCLUI_WEBHOOK_TIMEOUT", flag: "webhook-timeout", usage: "time a POST to webhook_url may take, e.g. 5s; 0 for no limit",
+		set: func(s *Settings, v string) error {
+			d, err := ParseTimeout("webhook_timeout", v)
+			s.WebhookTimeout = d
+			return err
+		},
+		get: func(s Settings) string { return s.WebhookTimeout.String() },
+	},
+	{
+		key: "stream_reveal", env: "CCLUI_STREAM_REVEAL", flag: "stream-reveal", usage: "words per second streamed replies are revealed at, or instant",
+		set: func(s *Settings, v string) error {
+			if v == "instant" {
+				s.StreamReveal = 0
+				return nil
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return fmt.Errorf("stream_reveal must be instant or a number of words per second, got %q", v)
+			}
+			s.StreamReveal = n
+			return nil
+		},
+		get: func(s Settings) string {
+			if s.StreamReveal == 0 {
+				return "instant"
+			}
+			return strconv.Itoa(s.StreamReveal)
+		},
+	},
+	{
+		key: "max_transcript", env: "CCLUI_MAX_TRANSCRIPT", flag: "max-transcript", usage: "messages the transcript shows before archiving older ones; 0 for no limit",
+		set: func(s *Settings, v string) error {
+			n, err := ParseCount("max_transcript", v)
+			s.MaxTranscript = n
+			return err
+		},
+		get: func(s Settings) string { return strconv.Itoa(s.MaxTranscript) },
+	},
+	{
+		key: "collapse_lines", env: "CCLUI_COLLAPSE_LINES", flag: "collapse-lines", usage: "lines past which a response is collapsed in the transcript; 0 never collapses (default 200)",
+		set: func(s *Settings, v string) error {
+			n, err := ParseCount("collapse_lines", v)
+			s.CollapseLines = n
+			return err
+		},
+		get: func(s Settings) string { return strconv.Itoa(s.CollapseLines) },
+	},
+	{
+		key: "json_retries", env: "CCLUI_JSON_RETRIES", flag: "json-retries", usage: "times a reply that doesn't match the /json schema is sent back",
+		set: func(s *Settings, v string) error {
+			n, err := ParseCount("json_retries", v)
+			s.JSONRetries = n
+			return err
+		},
+		get: func(s Settings) string { return strconv.Itoa(s.JSONRetries) },
+	},
+	{
+		key: "post_process", env: "CCLUI_POST_PROCESS", flag: "post-process", usage: "shell command each reply is piped through for display",
+		set: func(s *Settings, v string) error {
+			s.PostProcess = v
+			return nil
+		},
+		get: func(s Settings) string { return s.PostProcess },
+	},
+	{
+		key: "post_process_mode", env: "CCLUI_POST_PROCESS_MODE", flag: "post-process-mode", usage: "what post_process output does: replace or append",
+		set: func(s *Settings, v string) error {
+			if v != "replace" && v != "append" {
+				return fmt.Errorf("post_process_mode must be replace or append, got %q", v)
+			}
+			s.PostProcessMode = v
+			return nil
+		},
+		get: func(s Settings) string { return s.PostProcessMode },
+	},
+	{
+		key: "post_process_timeout", env: "CCLUI_POST_PROCESS_TIMEOUT", flag: "post-process-timeout", usage: "time post_process may run, e.g. 10s; 0 for no limit",
+		set: func(s *Settings, v string) error {
+			d, err := ParseTimeout("post_process_timeout", v)
+			s.PostProcessTimeout = d
+			return err
+		},
+		get: func(s Settings) string { return s.PostProcessTimeout.String() },
+	},
+	{
+		key: "banner", env: "CCLUI_BANNER", flag: "banner", usage: "show the startup banner (default true)", boolean: true,
+		set: func(s *Settings, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("banner must be true or false, got %q", v)
+			}
+			s.Banner = b
+			return nil
+		},
+		get: func(s Settings) string { return strconv.FormatBool(s.Banner) },
+	},
+	{
+		key: "opener", env: "CCLUI_OPENER", flag: "opener", usage: "a message shown from the assistant on startup, e.g. a greeting",
+		set: func(s *Settings, v string) error { s.Opener = v; return nil },
+		get: func(s Settings) string { return s.Opener },
+	},
+	{
+		key: "opener_context", env: "CCLUI_OPENER_CONTEXT", flag: "opener-context", usage: "tell Claude about the opener, in the system prompt, instead of only showing it", boolean: true,
+		set: func(s *Settings, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("opener_context must be true or false, got %q", v)
+			}
+			s.OpenerContext = b
+			return nil
+		},
+		get: func(s Settings) string { return strconv.FormatBool(s.OpenerContext) },
+	},
+	{
+		key: "allow_shell", env: "CCLUI_ALLOW_SHELL", flag: "allow-shell", usage: "allow running shell commands with ! and !!", boolean: true,
+		set: func(s *Settings, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("allow_shell must be true or false, got %q", v)
+			}
+			s.AllowShell = b
+			return nil
+		},
+		get: func(s Settings) string { return strconv.FormatBool(s.AllowShell) },
+	},
+	{
+		key: "show_request_id", env: "CCLUI_SHOW_REQUEST_ID", flag: "show-request-id", usage: "show the request-id of each reply under it", boolean: true,
+		set: func(s *Settings, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("show_request_id must be true or false, got %q", v)
+			}
+			s.ShowRequestID = b
+			return nil
+		},
+		get: func(s Settings) string { return strconv.FormatBool(s.ShowRequestID) },
+	},
+	{
+		key: "show_tokens_per_second", env: "CCLUI_SHOW_TOKENS_PER_SECOND", flag: "show-tokens-per-second", usage: "show the output tokens per second of each reply under it", boolean: true,
+		set: func(s *Settings, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("show_tokens_per_second must be true or false, got %q", v)
+			}
+			s.ShowTokensPerSecond = b
+			return nil
+		},
+		get: func(s Settings) string { return strconv.FormatBool(s.ShowTokensPerSecond) },
+	},
+	{
+		key: "show_clock", env: "CCLUI_SHOW_CLOCK", flag: "show-clock", usage: "show the session's elapsed time and last activity in the status line", boolean: true,
+		set: func(s *Settings, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("show_clock must be true or false, got %q", v)
+			}
+			s.ShowClock = b
+			return nil
+		},
+		get: func(s Settings) string { return strconv.FormatBool(s.ShowClock) },
+	},
+	{
+		key: "exit_summary", env: "CCLUI_EXIT_SUMMARY", flag: "exit-summary", usage: "print the turns, tokens, cost and duration of the session to stderr on exit", boolean: true,
+		set: func(s *Settings, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("exit_summary must be true or false, got %q", v)
+			}
+			s.ExitSummary = b
+			return nil
+		},
+		get: func(s Settings) string { return strconv.FormatBool(s.ExitSummary) },
+	},
+	{
+		key: "normalize_input", env: "CCLUI_NORMALIZE_INPUT", flag: "normalize-input", usage: "normalize line endings and trim trailing whitespace of messages (default true)", boolean: true,
+		set: func(s *Settings, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("normalize_input must be true or false, got %q", v)
+			}
+			s.NormalizeInput = b
+			return nil
+		},
+		get: func(s Settings) string { return strconv.FormatBool(s.NormalizeInput) },
+	},
+	{
+		key: "send_guard", env: "CCLUI_SEND_GUARD", flag: "send-guard", usage: "time after sending a message during which Enter doesn't send another, e.g. 1s; 0 for none",
+		set: func(s *Settings, v string) error {
+			d, err := ParseTimeout("send_guard", v)
+			s.SendGuard = d
+			return err
+		},
+		get: func(s Settings) string { return s.SendGuard.String() },
+	},
+	{
+		key: "confirm_tokens", env: "CCLUI_CONFIRM_TOKENS", flag: "confirm-tokens", usage: "estimated input tokens of a request past which sending it asks first; 0 never asks",
+		set: func(s *Settings, v string) error {
+			n, err := ParseCount("confirm_tokens", v)
+			s.ConfirmTokens = n
+			return err
+		},
+		get: func(s Settings) string { return strconv.Itoa(s.ConfirmTokens) },
+	},
+	{
+		key: "connect_timeout", env: "CCLUI_CONNECT_TIMEOUT", flag: "connect-timeout", usage: "wait for a reply to start, e.g. 60s; 0 for no limit",
+		set: func(s *Settings, v string) error {
+			d, err := ParseTimeout("connect_timeout", v)
+			s.ConnectTimeout = d
+			return err
+		},
+		get: func(s Settings) string { return s.ConnectTimeout.String() },
+	},
+	{
+		key: "idle_timeout", env: "CCLUI_IDLE_TIMEOUT", flag: "idle-timeout", usage: "longest gap within a streamed reply, e.g. 60s; 0 for no limit",
+		set: func(s *Settings, v string) error {
+			d, err := ParseTimeout("idle_timeout", v)
+			s.IdleTimeout = d
+			return err
+		},
+		get: func(s Settings) string { return s.IdleTimeout.String() },
+	},
+	{
+		key: "reconnects", env: "CCLUI_RECONNECTS", flag: "reconnects", usage: "times a reply whose stream drops is continued where it stopped; 0 fails the turn",
+		set: func(s *Settings, v string) error {
+			n, err := ParseCount("reconnects", v)
+			s.Reconnects = n
+			return err
+		},
+		get: func(s Settings) string { return strconv.Itoa(s.Reconnects) },
+	},
+	{
+		key: "idle_disconnect", env: "CCLUI_IDLE_DISCONNECT", flag: "idle-disconnect", usage: "close the API connections after this long without requests, e.g. 10m; 0 to keep them",
+		set: func(s *Settings, v string) error {
+			d, err := ParseTimeout("idle_disconnect", v)
+			s.IdleDisconnect = d
+			return err
+		},
+		get: func(s Settings) string { return s.IdleDisconnect.String() },
+	},
+	{
+		key: "wrap_width", env: "CCLUI_WRAP_WIDTH", flag: "wrap-width", usage: "wrap the transcript at N columns, 0 for the terminal width",
+		set: func(s *Settings, v string) error {
+			n, err := ParseCount("wrap_width", v)
+			s.WrapWidth = n
+			return err
+		},
+		get: func(s Settings) string { return strconv.Itoa(s.WrapWidth) },
+	},
+	{
+		key: "wrap_align", env: "CCLUI_WRAP_ALIGN", flag: "wrap-align", usage: "align a wrapped transcript: left or center",
+		set: func(s *Settings, v string) error {
+			if v != "left" && v != "center" {
+				return fmt.Errorf("wrap_align must be left or center, got %q", v)
+			}
+			s.WrapAlign = v
+			return nil
+		},
+		get: func(s Settings) string { return s.WrapAlign },
+	},
+	{
+		key: "wide_tables", env: "CCLUI_WIDE_TABLES", flag: "wide-tables", usage: "Markdown tables wider than the transcript: wrap their cells or clip them",
+		set: func(s *Settings, v string) error {
+			if v != "wrap" && v != "clip" {
+				return fmt.Errorf("wide_tables must be wrap or clip, got %q", v)
+			}
+			s.WideTables = v
+			return nil
+		},
+		get: func(s Settings) string { return s.WideTables },
+	},
+	{
+		key: "editor", env: "CCLUI_EDITOR", flag: "editor", usage: "input key scheme: default or vim",
+		set: func(s *Settings, v string) error {
+			if v != "default" && v != "vim" {
+				return fmt.Errorf("editor must be default or vim, got %q", v)
+			}
+			s.Editor = v
+			return nil
+		},
+		get: func(s Settings) string { return s.Editor },
+	},
+	{
+		key: "tools", env: "CCLUI_TOOLS", flag: "tools", usage: "JSON file declaring the tools Claude may call",
+		set: func(s *Settings, v string) error {
+			s.ToolsFile = v
+			return nil
+		},
+		get: func(s Settings) string { return s.ToolsFile },
+	},
+	{
+		key: "tool_choice", env: "CCLUI_TOOL_CHOICE", flag: "tool-choice", usage: "auto, any, none or the name of a declared tool",
+		set: func(s *Settings, v string) error {
+			s.ToolChoice = v
+			return nil
+		},
+		get: func(s Settings) string { return s.ToolChoice },
+	},
+	{
+		key: "disable_parallel_tool_use", env: "CCLUI_DISABLE_PARALLEL_TOOL_USE", flag: "disable-parallel-tool-use", usage: "let Claude call at most one tool per reply", boolean: true,
+		set: func(s *Settings, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("disable_parallel_tool_use must be true or false, got %q", v)
+			}
+			s.DisableParallelToolUse = b
+			return nil
+		},
+		get: func(s Settings) string { return strconv.FormatBool(s.DisableParallelToolUse) },
+	},
+	{
+		key: "copy_format", env: "CCLUI_COPY_FORMAT", flag: "copy-format", usage: "format of /copy: markdown or text",
+		set: func(s *Settings, v string) error {
+			if v != "markdown" && v != "text" {
+				return fmt.Errorf("copy_format must be markdown or text, got %q", v)
+			}
+			s.CopyFormat = v
+			return nil
+		},
+		get: func(s Settings) string { return s.CopyFormat },
+	},
+}, roleSettings()...)
+
+// roleSettings returns the <role>_label and <role>_color settings of every
+// role.
+func roleSettings() []setting {
+	var out []setting
+	for _, role := range Roles {
+		out = append(out,
+			setting{
+				key: role + "_label", env: "CCLUI_" + strings.ToUpper(role) + "_LABEL",
+				set: func(s *Settings, v string) error {
+					r := s.Roles[role]
+					r.Label = v
+					s.Roles[role] = r
+					return nil
+				},
+				get: func(s Settings) string { return s.Roles[role].Label },
+			},
+			setting{
+				key: role + "_color", env: "CCLUI_" + strings.ToUpper(role) + "_COLOR",
+				set: func(s *Settings, v string) error {
+					if err := ParseColor(role+"_color", v); err != nil {
+						return err
+					}
+					r := s.Roles[role]
+					r.Color = v
+					s.Roles[role] = r
+					return nil
+				},
+				get: func(s Settings) string { return s.Roles[role].Color },
+			},
+		)
+	}
+	return out
+}
+
+func defaults() Settings {
+	return Settings{
+		BaseURL:            api.DefaultBaseURL,
+		Version:            api.DefaultVersion,
+		Model:              api.DefaultModel,
+		MaxTokens:          api.DefaultMaxTokens,
+		Editor:             "default",
+		CopyFormat:         "markdown",
+		AutoTitle:          "time",
+		ToolChoice:         "auto",
+		JSONRetries:        2,
+		CollapseLines:      200,
+		Banner:             true,
+		NormalizeInput:     true,
+		PostProcessMode:    "replace",
+		PostProcessTimeout: 10 * time.Second,
+		WebhookTimeout:     5 * time.Second,
+		// The API sends pings while it is busy, so a minute of silence
+		// means the stream is stuck.
+		ConnectTimeout: time.Minute,
+		IdleTimeout:    time.Minute,
+		WrapAlign:      "left",
+		WideTables:     "wrap",
+		Roles: map[string]Role{
+			"user":      {Label: "You", Color: "5"},
+			"assistant": {Label: "Claude", Color: "5"},
+			"system":    {},
+			"error":     {Label: "Error", Color: "5"},
+		},
+	}
+}
+
+// ParseMaxTokens validates a max_tokens value.
+func ParseMaxTokens(v string) (int, error) {
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("max_tokens must be a positive integer, got %q", v)
+	}
+	return n, nil
+}
+
+// CheckThinking validates a thinking budget against the other parameters of
+// a request: extended thinking needs max_tokens above its budget and no
+// temperature but 1.
+func CheckThinking(budget, maxTokens int, temperature *float64) error {
+	if budget <= 0 {
+		return nil
+	}
+	if budget >= maxTokens {
+		return fmt.Errorf("thinking_budget (%d) must be less than max_tokens (%d)", budget, maxTokens)
+	}
+	if temperature != nil && *temperature != 1 {
+		return fmt.Errorf("temperature can't be set with thinking_budget")
+	}
+	return nil
+}
+
+// ParseProxy validates a proxy URL: http, https or socks5 with a host.
+// Empty means the proxy of the environment.
+func ParseProxy(v string) (*url.URL, error) {
+	if v == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(v)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("proxy must be a URL such as http://proxy:3128, got %q", v)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+		return u, nil
+	}
+	return nil, fmt.Errorf("proxy must be an http, https or socks5 URL, got %q", u.Redacted())
+}
+
+// ParseWebhook validates a webhook URL: http or https with a host. Empty
+// means no webhook.
+func ParseWebhook(v string) (string, error) {
+	if v == "" {
+		return "", nil
+	}
+	u, err := url.Parse(v)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", fmt.Errorf("webhook_url must be an http or https URL, got %q", redactURL(v))
+	}
+	return v, nil
+}
+
+// redactURL hides the password of a URL, which may well hold a token.
+func redactURL(v string) string {
+	if u, err := url.Parse(v); err == nil {
+		return u.Redacted()
+	}
+	return v
+}
+
+// ParseCount validates a setting that takes a count, 0 meaning off.
+func ParseCount(name, v string) (int, error) {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be 0 or a positive integer, got %q", name, v)
+	}
+	return n, nil
+}
+
+// ParseThinkingBudget validates a thinking budget: 0 turns thinking off,
+// otherwise the API wants at least 1024 tokens.
+func ParseThinkingBudget(v string) (int, error) {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 || (n > 0 && n < 1024) {
+		return 0, fmt.Errorf("thinking_budget must be 0 or at least 1024, got %q", v)
+	}
+	return n, nil
+}
+
+// ParseUnit validates a sampling parameter between 0 and 1.
+func ParseUnit(name, v string) (*float64, error) {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 || f > 1 {
+		return nil, fmt.Errorf("%s must be a number between 0 and 1, got %q", name, v)
+	}
+	return &f, nil
+}
+
+// ParseTimeout validates a duration such as "30s" or "2m"; "0" turns the
+// timeout off.
+func ParseTimeout(name, v string) (time.Duration, error) {
+	if v == "0" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%s must be a duration such as 30s, or 0, got %q", name, v)
+	}
+	return d, nil
+}
+
+// ParseColor validates a color: an ANSI color number from 0 to 255 or a
+// #rrggbb hex color. Empty is the terminal's default color.
+func ParseColor(name, v string) error {
+	if v == "" {
+		return nil
+	}
+	if n, err := strconv.Atoi(v); err == nil && n >= 0 && n <= 255 {
+		return nil
+	}
+	if len(v) == 7 && v[0] == '#' {
+		if _, err := strconv.ParseUint(v[1:], 16, 32); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s must be an ANSI color number or #rrggbb, got %q", name, v)
+}
+
+// ParsePrices parses a price list such as
+// "claude-3-opus-20240229=15/75, claude-3-haiku-20240307=0.25/1.25".
+func ParsePrices(v string) (map[string]Price, error) {
+	prices := map[string]Price{}
+	for _, entry := range strings.Split(v, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		model, pair, ok := strings.Cut(entry, "=")
+		in, out, ok2 := strings.Cut(pair, "/")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("prices: want model=input/output, got %q", entry)
+		}
+		var p Price
+		var err1, err2 error
+		p.Input, err1 = strconv.ParseFloat(strings.TrimSpace(in), 64)
+		p.Output, err2 = strconv.ParseFloat(strings.TrimSpace(out), 64)
+		if err1 != nil || err2 != nil || p.Input < 0 || p.Output < 0 {
+			return nil, fmt.Errorf("prices: %q has an invalid price", entry)
+		}
+		prices[strings.TrimSpace(model)] = p
+	}
+	return prices, nil
+}
+
+func formatPrices(prices map[string]Price) string {
+	models := make([]string, 0, len(prices))
+	for model := range prices {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	for i, model := range models {
+		p := prices[model]
+		models[i] = fmt.Sprintf("%s=%g/%g", model, p.Input, p.Output)
+	}
+	return strings.Join(models, ",")
+}
+
+func formatOptional(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+// RegisterFlags adds a command line flag for every setting that has one.
+func RegisterFlags(fs *flag.FlagSet) {
+	for _, s := range settings {
+		switch {
+		case s.flag == "":
+		case s.boolean:
+			fs.Bool(s.flag, false, s.usage)
+		default:
+			fs.String(s.flag, "", s.usage)
+		}
+	}
+}
+
+// Load resolves the configuration. Later sources win: defaults, then the
+// config file, then the environment, then flags registered with
+// RegisterFlags that were set on fs.
+func Load(fs *flag.FlagSet) (*Config, error) {
+	flags := map[string]string{}
+	if fs != nil {
+		fs.Visit(func(f *flag.Flag) { flags[f.Name] = f.Value.String() })
+	}
+	return load(flags)
+}
+
+// Reload resolves the configuration again, from the config file and the
+// environment as they are now and the flags c was loaded with.
+func (c *Config) Reload() (*Config, error) {
+	return load(c.flags)
+}
+
+func load(flags map[string]string) (*Config, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{
+		Settings: defaults(),
+		Sources:  map[string]Source{},
+		File:     filepath.Join(dir, "config.yaml"),
+		flags:    flags,
+	}
+	for _, s := range settings {
+		cfg.Sources[s.key] = SourceDefault
+	}
+
+	nodes, err := readFile(cfg.File)
+	if err != nil {
+		return nil, err
+	}
+	file := map[string]string{}
+	for key, node := range nodes {
+		if node.Kind == yaml.ScalarNode {
+			file[key] = node.Value
+		}
+	}
+	if node, ok := nodes["personas"]; ok {
+		if cfg.Personas, err = parsePersonas(node); err != nil {
+			return nil, fmt.Errorf("%s: %w", cfg.File, err)
+		}
+	}
+	if node, ok := nodes["system_vars"]; ok {
+		if cfg.SystemVars, err = parseSystemVars(node); err != nil {
+			return nil, fmt.Errorf("%s: %w", cfg.File, err)
+		}
+	}
+	if node, ok := nodes["verbosity"]; ok {
+		if cfg.Verbosity, err = parseVerbosity(node); err != nil {
+			return nil, fmt.Errorf("%s: %w", cfg.File, err)
+		}
+	}
+
+	for _, s := range settings {
+		if v, ok := file[s.key]; ok {
+			if err := cfg.apply(s, v, SourceFile); err != nil {
+				return nil, fmt.Errorf("%s: %w", cfg.File, err)
+			}
+		}
+		if v := os.Getenv(s.env); s.env != "" && v != "" {
+			if err := cfg.apply(s, v, SourceEnv); err != nil {
+				return nil, fmt.Errorf("$%s: %w", s.env, err)
+			}
+		}
+		if v, ok := flags[s.flag]; s.flag != "" && ok {
+			if err := cfg.apply(s, v, SourceFlag); err != nil {
+				return nil, fmt.Errorf("-%s: %w", s.flag, err)
+			}
+		}
+	}
+	if err := CheckThinking(cfg.ThinkingBudget, cfg.MaxTokens, cfg.Temperature); err != nil {
+		return nil, err
+	}
+	if cfg.ToolsFile != "" {
+		path := cfg.ToolsFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		if cfg.Tools, err = ReadTools(path); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := api.ParseToolChoice(cfg.ToolChoice, cfg.Tools); err != nil {
+		return nil, err
+	}
+	if cfg.DisableParallelToolUse {
+		if len(cfg.Tools) == 0 {
+			return nil, fmt.Errorf("disable_parallel_tool_use needs declared tools")
+		}
+		if cfg.ToolChoice == "none" {
+			return nil, fmt.Errorf("disable_parallel_tool_use does not go with tool_choice none")
+		}
+	}
+	if cfg.CAFile != "" {
+		path := cfg.CAFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		if cfg.RootCAs, err = ReadCAFile(path); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// ReadCAFile returns the system's certificate pool with the PEM
+// certificates of path added.
+func ReadCAFile(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ca_file: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("ca_file: no PEM certificates in %s", path)
+	}
+	return pool, nil
+}
+
+// ReadTools reads tool declarations in the format of the API's tools field:
+// a JSON array of objects with a name, a description and an input_schema.
+func ReadTools(path string) ([]api.Tool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("tools: %w", err)
+	}
+	var tools []api.Tool
+	if err := json.Unmarshal(data, &tools); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	seen := map[string]bool{}
+	for i, t := range tools {
+		switch {
+		case t.Name == "":
+			return nil, fmt.Errorf("%s: tool %d has no name", path, i+1)
+		case seen[t.Name]:
+			return nil, fmt.Errorf("%s: tool %q is declared twice", path, t.Name)
+		case len(t.InputSchema) == 0:
+			return nil, fmt.Errorf("%s: tool %q has no input_schema", path, t.Name)
+		}
+		seen[t.Name] = true
+	}
+	return tools, nil
+}
+
+func (c *Config) apply(s setting, v string, src Source) error {
+	if err := s.set(&c.Settings, v); err != nil {
+		return err
+	}
+	c.Sources[s.key] = src
+	return nil
+}
+
+// readFile returns the top-level nodes of the config file, by key. A
+// missing file is the same as an empty one.
+func readFile(path string) (map[string]yaml.Node, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &nodes); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return nodes, nil
+}
+
+// Entry is one row of Describe.
+type Entry struct {
+	Key    string
+	Value  string
+	Source Source
+	// Origin names the variable or flag that set the value.
+	Origin string
+}
+
+// Describe lists every setting with its value and the source it came from.
+// Secrets are never included; only whether they are set.
+func (c *Config) Describe() []Entry {
+	entries := make([]Entry, 0, len(settings))
+	for _, s := range settings {
+		e := Entry{Key: s.key, Value: s.get(c.Settings), Source: c.Sources[s.key]}
+		if s.secret {
+			e.Value = api.MaskKey(e.Value)
+		}
+		switch e.Source {
+		case SourceEnv:
+			e.Origin = "$" + s.env
+		case SourceFlag:
+			e.Origin = "-" + s.flag
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// Changed lists the keys of the settings whose value differs between c and
+// other, in the order Describe lists them. The tools count as changed when
+// their declarations do, even from the same file.
+func (c *Config) Changed(other *Config) []string {
+	var keys []string
+	for _, s := range settings {
+		changed := s.get(c.Settings) != s.get(other.Settings)
+		if s.key == "tools" && !reflect.DeepEqual(c.Tools, other.Tools) {
+			changed = true
+		}
+		if changed {
+			keys = append(keys, s.key)
+		}
+	}
+	return keys
+}
+
+// Request returns the request settings, without messages.
+func (c *Config) Request() api.MessageRequest {
+	req := api.MessageRequest{
+		Model:       c.Model,
+		MaxTokens:   c.MaxTokens,
+		System:      c.System,
+		Temperature: c.Temperature,
+		TopP:        c.TopP,
+		ServiceTier: c.ServiceTier,
+	}
+	if c.ThinkingBudget > 0 {
+		req.Thinking = &api.Thinking{Type: "enabled", BudgetTokens: c.ThinkingBudget}
+	}
+	// The API only takes tool_choice along with tools. Load validated it.
+	if len(c.Tools) > 0 {
+		req.Tools = c.Tools
+		req.ToolChoice, _ = api.ParseToolChoice(c.ToolChoice, c.Tools)
+		req.ToolChoice.DisableParallelToolUse = c.DisableParallelToolUse
+	}
+	return req
+}
+
+// Client returns an API client for the configuration.
+func (c *Config) Client() *api.Client {
+	client := api.NewClient(c.APIKey, c.BaseURL)
+	client.Version = c.Version
+	client.ConnectTimeout = c.ConnectTimeout
+	client.IdleTimeout = c.IdleTimeout
+	if tlsConfig := c.tlsConfig(); c.Proxy != nil || tlsConfig != nil {
+		client.HTTPClient.Transport = api.NewTransport(c.Proxy, tlsConfig)
+	}
+	return client
+}
+
+// tlsConfig returns the TLS settings of the API client, nil for the
+// defaults.
+func (c *Config) tlsConfig() *tls.Config {
+	if c.RootCAs == nil && !c.InsecureSkipVerify {
+		return nil
+	}
+	return &tls.Config{RootCAs: c.RootCAs, InsecureSkipVerify: c.InsecureSkipVerify}
+}
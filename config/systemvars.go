@@ -0,0 +1,27 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// varName is what a variable of the system prompt may be called, as a
+// {{variable}} of a prompt template.
+var varName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseSystemVars reads the system_vars mapping of the config file: the
+// variables of the system prompt the user defines, by name.
+func parseSystemVars(node yaml.Node) (map[string]string, error) {
+	var vars map[string]string
+	if err := node.Decode(&vars); err != nil {
+		return nil, fmt.Errorf("system_vars: %w", err)
+	}
+	for name := range vars {
+		if !varName.MatchString(name) {
+			return nil, fmt.Errorf("system_vars: invalid name %q: use letters, digits and _, not starting with a digit", name)
+		}
+	}
+	return vars, nil
+}
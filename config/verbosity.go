@@ -0,0 +1,29 @@
+package config
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// parseVerbosity reads the verbosity mapping of the config file: the
+// instructions of the /verbosity levels, by level, replacing the built-in
+// ones of the same name.
+func parseVerbosity(node yaml.Node) (map[string]string, error) {
+	var levels map[string]string
+	if err := node.Decode(&levels); err != nil {
+		return nil, fmt.Errorf("verbosity: %w", err)
+	}
+	for level, text := range levels {
+		if err := ValidName(level); err != nil {
+			return nil, fmt.Errorf("verbosity: %w", err)
+		}
+		if level == "normal" {
+			return nil, fmt.Errorf("verbosity: normal is the level without instructions and can't have any")
+		}
+		if text == "" {
+			return nil, fmt.Errorf("verbosity: %s has no instructions", level)
+		}
+	}
+	return levels, nil
+}
@@ -1,235 +1,226 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"io"
+	"io/fs"
 	"log"
-	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 
-	"github.com/charmbracelet/bubbles/textarea"
-	"github.com/charmbracelet/bubbles/viewport"
+	"github.com/bnema/cclui/api"
+	"github.com/bnema/cclui/config"
+	"github.com/bnema/cclui/ui"
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/lipgloss"
 	"github.com/joho/godotenv"
+	"github.com/mattn/go-isatty"
 )
 
-type model struct {
-	viewport    viewport.Model
-	messages    []string
-	textarea    textarea.Model
-	senderStyle lipgloss.Style
-	err         error
-}
+// fileList collects the values of a repeated flag.
+type fileList []string
 
-type (
-	errMsg error
-)
+func (f *fileList) String() string     { return strings.Join(*f, ", ") }
+func (f *fileList) Set(v string) error { *f = append(*f, v); return nil }
 
-func checkAPIConnection() string {
-	apiKey := os.Getenv("ANTHROPIC_API_KEY")
-	if apiKey == "" {
-		log.Fatal("ANTHROPIC_API_KEY is not set")
-	}
+// isTerminal reports whether f is a terminal, the same way the color
+// detection of lipgloss does.
+func isTerminal(f *os.File) bool {
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
 
-	_, err := http.NewRequest("GET", "https://api.anthropic.com/v1/ping", nil)
+// openDebugLog opens debug.log in the logs directory for appending.
+func openDebugLog() (*os.File, error) {
+	dir, err := config.LogsDir()
 	if err != nil {
-		log.Fatalf("Error creating request: %v", err)
+		return nil, err
 	}
-
-	return "API is up and running"
-}
-
-func initialModel() model {
-	ta := textarea.New()
-	ta.Placeholder = "Send a message..."
-	ta.Focus()
-
-	ta.Prompt = "┃ "
-	ta.CharLimit = 280
-
-	ta.SetWidth(30)
-	ta.SetHeight(3)
-
-	// Remove cursor line styling
-	ta.FocusedStyle.CursorLine = lipgloss.NewStyle()
-
-	ta.ShowLineNumbers = false
-
-	vp := viewport.New(30, 5)
-	vp.SetContent(checkAPIConnection())
-
-	ta.KeyMap.InsertNewline.SetEnabled(false)
-
-	return model{
-		textarea:    ta,
-		messages:    []string{},
-		viewport:    vp,
-		senderStyle: lipgloss.NewStyle().Foreground(lipgloss.Color("5")),
-		err:         nil,
+	if err := config.EnsureDir(dir); err != nil {
+		return nil, err
 	}
+	return os.OpenFile(filepath.Join(dir, "debug.log"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
 }
 
-func (m model) Init() tea.Cmd {
-	return textarea.Blink
+// envTrue reports whether the environment variable name is set to a true
+// value, as strconv.ParseBool reads it.
+func envTrue(name string) bool {
+	v, _ := strconv.ParseBool(os.Getenv(name))
+	return v
 }
 
-type MessageToSend struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-}
-
-func ConstructUserMessage(content string) MessageToSend {
-	return MessageToSend{
-		Role:    "user",
-		Content: content,
+func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
 	}
 }
 
-func (m model) constructJsonBody(content string) ([]byte, error) {
-	messages := []MessageToSend{
-		ConstructUserMessage(content),
+// run is cclui but for exiting: every way out returns here, so the deferred
+// teardown always runs before main reports the error, if any.
+func run() error {
+	var (
+		prompt     string
+		stdinCtx   string
+		jsonOutput bool
+		debug      bool
+		demo       bool
+		noDotenv   bool
+		importPath string
+		viewPath   string
+		batchPath  string
+		batchOut   string
+		files      fileList
+	)
+	flag.StringVar(&prompt, "prompt", "", "send a single prompt and print the answer (\"-\" reads stdin)")
+	flag.StringVar(&prompt, "p", "", "shorthand for -prompt")
+	flag.StringVar(&stdinCtx, "stdin-context", "", "ask this question about the input piped to stdin, in the interface or printing the answer when stdout is not a terminal")
+	flag.BoolVar(&jsonOutput, "json", false, "with -prompt or -batch, print or write the full API response as JSON")
+	flag.StringVar(&batchPath, "batch", "", "answer each prompt of a file, one per line or a JSON array, and write the answers to files")
+	flag.StringVar(&batchOut, "batch-out", "", "with -batch, the directory to write the answers to (default: the file's name with .out)")
+	flag.BoolVar(&debug, "debug", false, "write a debug log to the logs directory")
+	flag.StringVar(&importPath, "import", "", "start from a conversation in an OpenAI or ChatGPT export")
+	flag.StringVar(&viewPath, "view", "", "browse a saved conversation, by name or path, read-only; nothing is sent")
+	flag.BoolVar(&demo, "demo", false, "reply with canned text instead of calling the API")
+	flag.BoolVar(&noDotenv, "no-dotenv", false, "don't load a .env file, only use the environment (or set CCLUI_NO_DOTENV)")
+	flag.Var(&files, "file", "attach a file, or path:start-end for some of its lines, to the first message; repeatable")
+	config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	// A missing .env is fine, the key may well be in the environment; one
+	// that can't be read or parsed is not.
+	if !noDotenv && !envTrue("CCLUI_NO_DOTENV") {
+		if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("Error loading .env file: %w", err)
+		}
 	}
 
-	body, err := json.Marshal(map[string]interface{}{
-		"model":      "claude-3-opus-20240229",
-		"max_tokens": 4096,
-		"messages":   messages,
-	})
+	cfg, err := config.Load(flag.CommandLine)
 	if err != nil {
-		log.Fatalf("Error marshaling JSON: %v", err)
-		return nil, err
+		return err
 	}
 
-	return body, nil
-}
+	if viewPath != "" {
+		if prompt != "" || stdinCtx != "" || batchPath != "" || importPath != "" || len(files) > 0 {
+			return errors.New("-view can't be used with -prompt, -stdin-context, -batch, -import or -file")
+		}
+		return runViewer(cfg, viewPath)
+	}
 
-func (m model) callClaudeAPI(apiKey string, body []byte) (*http.Response, error) {
-	req, err := http.NewRequest("POST", "https://api.anthropic.com/v1/messages", bytes.NewBuffer(body))
-	if err != nil {
-		return nil, err
+	if cfg.InsecureSkipVerify && !demo {
+		fmt.Fprintln(os.Stderr, "warning:", config.InsecureWarning)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-api-key", apiKey)
-	req.Header.Set("anthropic-version", "2023-06-01")
+	client := cfg.Client()
+	defer client.CloseIdleConnections()
+	if debug {
+		logFile, err := openDebugLog()
+		if err != nil {
+			return fmt.Errorf("Error opening debug log: %w", err)
+		}
+		defer func() {
+			logFile.Sync()
+			logFile.Close()
+		}()
+		client.Logger = log.New(logFile, "", log.LstdFlags)
+		defer client.Logger.Print("exiting")
+	}
 
-	client := &http.Client{}
-	return client.Do(req)
-}
+	var provider api.Provider = client
+	if demo {
+		provider = api.NewDemo()
+	}
 
-func (m model) processAPIResponse(resp *http.Response, resultChan chan string) {
-	defer resp.Body.Close()
+	// The interface fills in the variables of the system prompt as each
+	// message is sent; a one-off request has them filled in once.
+	params := cfg.Request()
+	params.System = ui.ExpandSystem(cfg, params.System)
 
-	if resp.StatusCode >= 400 {
-		bodyBytes, err := io.ReadAll(resp.Body)
-		if err != nil {
-			log.Printf("Error reading response body: %v", err)
-			return
+	if batchPath != "" {
+		if prompt != "" || stdinCtx != "" {
+			return errors.New("-batch can't be used with -prompt or -stdin-context")
 		}
-		log.Printf("API error: %s", string(bodyBytes))
-		return
+		return runBatch(provider, params, batchPath, batchOut, files, cfg.NormalizeInput, jsonOutput)
 	}
 
-	scanner := bufio.NewReader(resp.Body)
-	for {
-		line, err := scanner.ReadString('\n')
+	// With -stdin-context, stdin is what the question is about rather than
+	// the prompt. The interface then reads keys from the terminal instead.
+	var stdinContext string
+	if stdinCtx != "" {
+		if prompt != "" {
+			return errors.New("-stdin-context and -prompt can't be used together")
+		}
+		if isTerminal(os.Stdin) {
+			return errors.New("-stdin-context needs input piped to stdin, e.g. cat main.go | cclui -stdin-context \"explain this\"")
+		}
+		context, cut, err := ui.StdinContext(os.Stdin)
 		if err != nil {
-			if err == io.EOF {
-				break // End of the stream
-			}
-			log.Printf("Error reading response: %v", err)
-			return
+			return err
+		}
+		if cut {
+			fmt.Fprintf(os.Stderr, "warning: stdin is over %d KiB; only the first %d KiB are attached\n", ui.StdinCap>>10, ui.StdinCap>>10)
 		}
+		if !isTerminal(os.Stdout) {
+			return runOneShot(provider, params, context+"\n\n"+stdinCtx, files, cfg.NormalizeInput, jsonOutput)
+		}
+		stdinContext = context
+	}
 
-		line = strings.TrimSpace(line)
-		if line != "" {
-			resultChan <- line
+	// The TUI would fill a redirected stdout with escape codes. When the
+	// prompt is piped in as well, answer it like -p - would; otherwise
+	// explain how to script cclui.
+	if prompt == "" && !isTerminal(os.Stdout) {
+		if isTerminal(os.Stdin) {
+			return errors.New("stdout is not a terminal; to script cclui, pass the prompt with -p, e.g. cclui -p \"hello\" > answer.txt")
 		}
+		prompt = "-"
 	}
-}
 
-func (m model) CallClaude(content string, resultChan chan string) tea.Cmd {
-	apiKey := os.Getenv("ANTHROPIC_API_KEY")
+	if prompt != "" {
+		return runOneShot(provider, params, prompt, files, cfg.NormalizeInput, jsonOutput)
+	}
 
-	return func() tea.Msg {
-		body, err := m.constructJsonBody(content)
-		if err != nil {
-			return errMsg(err)
+	model, err := ui.New(provider, cfg)
+	if err != nil {
+		return err
+	}
+	if importPath != "" {
+		if model, err = model.Import(importPath, ""); err != nil {
+			return err
 		}
-
-		resp, err := m.callClaudeAPI(apiKey, body)
-		if err != nil {
-			return errMsg(err)
+	}
+	for _, file := range files {
+		if model, err = model.AttachFile(file); err != nil {
+			return err
 		}
-
-		go m.processAPIResponse(resp, resultChan)
-		return nil
 	}
-}
-
-func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	var (
-		tiCmd tea.Cmd
-		vpCmd tea.Cmd
-	)
+	var options []tea.ProgramOption
+	if stdinContext != "" {
+		model = model.AttachStdin(stdinContext, stdinCtx)
+		options = append(options, tea.WithInputTTY())
+	}
 
-	m.textarea, tiCmd = m.textarea.Update(msg)
-	m.viewport, vpCmd = m.viewport.Update(msg)
-
-	switch msg := msg.(type) {
-	case tea.KeyMsg:
-		switch msg.Type {
-		case tea.KeyCtrlC, tea.KeyEsc:
-			fmt.Println(m.textarea.Value())
-			return m, tea.Quit
-		case tea.KeyEnter:
-			m.messages = append(m.messages, m.senderStyle.Render("You: ")+m.textarea.Value())
-			m.viewport.SetContent(strings.Join(m.messages, "\n"))
-
-			resultChan := make(chan string)
-			go func() {
-				for result := range resultChan {
-					m.messages = append(m.messages, m.senderStyle.Render("Claude: ")+result)
-					m.viewport.SetContent(strings.Join(m.messages, "\n"))
-					m.viewport.GotoBottom()
-				}
-			}()
-
-			m.textarea.Reset()
-			m.viewport.GotoBottom()
-			return m, m.CallClaude(m.textarea.Value(), resultChan)
+	// Run restores the terminal however the program ends, including a
+	// signal; Close then tears down what the session left running.
+	final, err := tea.NewProgram(model, options...).Run()
+	if m, ok := final.(ui.Model); ok {
+		err = errors.Join(err, m.Close())
+		if summary := m.ExitSummary(); summary != "" {
+			fmt.Fprintln(os.Stderr, summary)
 		}
-
-	// We handle errors just like any other message
-	case errMsg:
-		m.err = msg
-		return m, nil
 	}
-
-	return m, tea.Batch(tiCmd, vpCmd)
+	return err
 }
 
-func (m model) View() string {
-	return fmt.Sprintf(
-		"%s\n\n%s",
-		m.viewport.View(),
-		m.textarea.View(),
-	) + "\n\n"
-}
-
-func main() {
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatal("Error loading .env file")
+// runViewer browses the saved conversation spec read-only, see ui.NewViewer.
+func runViewer(cfg *config.Config, spec string) error {
+	if !isTerminal(os.Stdout) {
+		return errors.New("-view needs a terminal")
 	}
-	p := tea.NewProgram(initialModel())
-
-	if _, err := p.Run(); err != nil {
-		log.Fatal(err)
+	model, err := ui.NewViewer(cfg, spec)
+	if err != nil {
+		return err
 	}
+	_, err = tea.NewProgram(model).Run()
+	return err
 }
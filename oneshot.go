@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/bnema/cclui/api"
+	"github.com/bnema/cclui/ui"
+)
+
+// runOneShot sends a single prompt and prints the answer to stdout. The
+// files are attached ahead of it, see ui.FileContext, and the prompt is
+// cleaned up with ui.NormalizeInput if normalize is set. With jsonOutput the
+// full API response is printed instead, for piping into jq.
+func runOneShot(client api.Provider, params api.MessageRequest, prompt string, files []string, normalize, jsonOutput bool) error {
+	if prompt == "-" {
+		in, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		prompt = string(in)
+	}
+	prompt, err := withFiles(prompt, files, normalize)
+	if err != nil {
+		return err
+	}
+
+	params.Messages = []api.MessageToSend{api.ConstructUserMessage(prompt)}
+	resp, err := client.CreateMessage(context.Background(), params)
+	if err != nil {
+		return err
+	}
+	if resp.Warning != "" {
+		fmt.Fprintln(os.Stderr, "warning:", resp.Warning)
+	}
+	if resp.StopReason == "max_tokens" {
+		fmt.Fprintf(os.Stderr, "warning: the response was cut off at max_tokens (%d)\n", params.MaxTokens)
+	}
+
+	if !jsonOutput {
+		fmt.Println(resp.Text())
+		return nil
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, resp.Raw, "", "  "); err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+	_, err = out.WriteTo(os.Stdout)
+	return err
+}
+
+// withFiles puts the files ahead of prompt, see ui.FileContext, after
+// cleaning prompt up with ui.NormalizeInput if normalize is set.
+func withFiles(prompt string, files []string, normalize bool) (string, error) {
+	if normalize {
+		prompt = ui.NormalizeInput(prompt)
+	}
+	parts := make([]string, 0, len(files)+1)
+	for _, file := range files {
+		context, err := ui.FileContext(file)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, context)
+	}
+	return strings.Join(append(parts, prompt), "\n\n"), nil
+}
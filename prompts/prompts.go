@@ -0,0 +1,117 @@
+// Package prompts loads reusable prompt templates from disk. A template is a
+// plain text file whose {{variables}} are filled in when it is used.
+package prompts
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bnema/cclui/config"
+)
+
+// Ext is the file extension of template files.
+const Ext = ".txt"
+
+var varPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+type Template struct {
+	Name string
+	Body string
+}
+
+// Load reads the template called name from dir.
+func Load(dir, name string) (*Template, error) {
+	if err := config.ValidName(name); err != nil {
+		return nil, fmt.Errorf("template: %w", err)
+	}
+
+	body, err := os.ReadFile(filepath.Join(dir, name+Ext))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no template named %q in %s", name, dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Template{Name: name, Body: string(body)}, nil
+}
+
+// List returns the names of the templates in dir, sorted. A missing
+// directory simply has no templates.
+func List(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != Ext {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(e.Name(), Ext))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Vars returns the distinct variable names used by the template in order of
+// first appearance.
+func (t *Template) Vars() []string {
+	var vars []string
+	seen := map[string]bool{}
+	for _, match := range varPattern.FindAllStringSubmatch(t.Body, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			vars = append(vars, match[1])
+		}
+	}
+	return vars
+}
+
+// Expand substitutes values into the template. Every variable must be
+// supplied; unknown keys are rejected so typos don't go unnoticed.
+func (t *Template) Expand(values map[string]string) (string, error) {
+	var missing []string
+	for _, v := range t.Vars() {
+		if _, ok := values[v]; !ok {
+			missing = append(missing, v)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("template %q needs %s", t.Name, strings.Join(missing, ", "))
+	}
+
+	known := map[string]bool{}
+	for _, v := range t.Vars() {
+		known[v] = true
+	}
+	for k := range values {
+		if !known[k] {
+			return "", fmt.Errorf("template %q has no variable %q", t.Name, k)
+		}
+	}
+
+	return varPattern.ReplaceAllStringFunc(t.Body, func(m string) string {
+		return values[varPattern.FindStringSubmatch(m)[1]]
+	}), nil
+}
+
+// Fill substitutes values into the {{variables}} of text it has values for.
+// Unlike Expand it leaves the others as they are, since text may well
+// mean them literally.
+func Fill(text string, values map[string]string) string {
+	return varPattern.ReplaceAllStringFunc(text, func(m string) string {
+		if v, ok := values[varPattern.FindStringSubmatch(m)[1]]; ok {
+			return v
+		}
+		return m
+	})
+}
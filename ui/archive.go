@@ -0,0 +1,102 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bnema/cclui/config"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// hiddenMessages returns how many of the oldest messages the transcript
+// leaves out: all but the last max_transcript, and the ones /older brought
+// back. They stay in the context all the same; trimming it is up to
+// contextMessages.
+func (m Model) hiddenMessages() int {
+	limit := m.config.MaxTranscript
+	if limit <= 0 {
+		return 0
+	}
+	return max(len(m.messages)-limit-m.revealed, 0)
+}
+
+// archive appends the messages that left the transcript since the last
+// call to this session's archive file, one JSON object per line in the
+// format of saved conversations.
+func (m *Model) archive() {
+	hidden := m.hiddenMessages()
+	if hidden <= m.archived {
+		return
+	}
+	if m.archivePath == "" {
+		dir, err := config.ArchiveDir()
+		if err != nil {
+			m.archiveErr = err
+			return
+		}
+		m.archivePath = filepath.Join(dir, strings.Replace(m.autosaveName, "autosave", "transcript", 1)+".jsonl")
+	}
+	if m.archiveErr = appendArchive(m.archivePath, m.messages[m.archived:hidden]); m.archiveErr == nil {
+		m.archived = hidden
+	}
+}
+
+// resetArchive starts over the archiving of a transcript that replaced the
+// one before, as /switch, /branch, /load and the like do: none of its
+// messages are archived or brought back by /older yet.
+func (m *Model) resetArchive() {
+	m.revealed, m.archived, m.archivePath = 0, 0, ""
+}
+
+// archiveDeleted keeps the archive cursor on the same message once the
+// messages from from to to are deleted from the transcript.
+func (m *Model) archiveDeleted(from, to int) {
+	if from < m.archived {
+		m.archived -= min(to, m.archived) - from
+	}
+}
+
+func appendArchive(path string, msgs []message) error {
+	if err := config.EnsureDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, msg := range msgs {
+		if err := enc.Encode(savedMessageOf(msg)); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// archiveNote heads a transcript whose oldest messages are hidden.
+func (m Model) archiveNote(hidden int) string {
+	where := "archived to " + m.archivePath
+	if m.archiveErr != nil {
+		where = fmt.Sprintf("hidden (archiving failed: %v)", m.archiveErr)
+	}
+	return m.codeLabelStyle.Render(fmt.Sprintf("⋯ %d earlier messages %s; /older shows them", hidden, where))
+}
+
+// showOlder handles /older, bringing max_transcript more of the hidden
+// messages back into the transcript until the next message is sent.
+func (m Model) showOlder() (Model, tea.Cmd, error) {
+	hidden := m.hiddenMessages()
+	if hidden == 0 {
+		return m, nil, fmt.Errorf("no older messages are hidden")
+	}
+	shown := min(hidden, m.config.MaxTranscript)
+	m.revealed += shown
+	m.refresh()
+	m.viewport.GotoTop()
+	m, cmd := m.flash(fmt.Sprintf("Showing %d older messages", shown))
+	return m, cmd, nil
+}
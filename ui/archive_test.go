@@ -0,0 +1,60 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// archivedTurns returns a session keeping two messages in the transcript,
+// archiving under a temporary directory, with n turns in it.
+func archivedTurns(t *testing.T, n int) Model {
+	t.Helper()
+	t.Setenv("CCLUI_CONFIG_DIR", t.TempDir())
+	m := newTestModel(t)
+	m.config.MaxTranscript = 2
+	for i := 1; i <= n; i++ {
+		m.appendMessage(roleUser, fmt.Sprintf("prompt %d", i))
+		m.appendMessage(roleAssistant, fmt.Sprintf("reply %d", i))
+	}
+	return m
+}
+
+func TestArchiveStartsOverOnANewTranscript(t *testing.T) {
+	m := archivedTurns(t, 3)
+	if m.archived != 4 {
+		t.Fatalf("archived = %d, want the first two turns", m.archived)
+	}
+
+	m.fork(1)
+	if m.archived != 0 {
+		t.Fatalf("archived = %d after a fork, want the count started over", m.archived)
+	}
+	m.appendMessage(roleUser, "prompt b")
+	m.appendMessage(roleAssistant, "reply b")
+	if m.archived != 2 {
+		t.Fatalf("archived = %d, want the first turn of the branch", m.archived)
+	}
+	data, err := os.ReadFile(m.archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 6 || !strings.Contains(lines[4], "prompt 1") || !strings.Contains(lines[5], "reply 1") {
+		t.Errorf("archive:\n%s\nwant the first turn of the branch after the two of main", data)
+	}
+}
+
+func TestArchiveFollowsDeletions(t *testing.T) {
+	m := archivedTurns(t, 3)
+	m.messages = append(m.messages[:0:0], m.messages[2:]...)
+	m.archiveDeleted(0, 2)
+	if m.archived != 2 {
+		t.Errorf("archived = %d, want the turn left of the two archived", m.archived)
+	}
+	m.archiveDeleted(3, 4)
+	if m.archived != 2 {
+		t.Errorf("archived = %d, want a deletion past the archive to leave it", m.archived)
+	}
+}
@@ -0,0 +1,141 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// fileCap is the largest file -file attaches. Larger ones are refused
+// rather than cut, since half a file is easy to misread.
+const fileCap = 256 << 10
+
+// fileLanguages maps file extensions and names to the language hint of the
+// code fence around an attached file.
+var fileLanguages = map[string]string{
+	".go": "go", ".py": "python", ".js": "javascript", ".mjs": "javascript",
+	".ts": "typescript", ".tsx": "tsx", ".jsx": "jsx",
+	".sh": "bash", ".bash": "bash", ".zsh": "zsh",
+	".rs": "rust", ".c": "c", ".h": "c", ".cpp": "cpp", ".cc": "cpp", ".hpp": "cpp",
+	".java": "java", ".kt": "kotlin", ".swift": "swift", ".cs": "csharp",
+	".rb": "ruby", ".pl": "perl", ".php": "php", ".lua": "lua",
+	".json": "json", ".yaml": "yaml", ".yml": "yaml", ".toml": "toml",
+	".html": "html", ".css": "css", ".sql": "sql", ".md": "markdown",
+	"makefile": "makefile", "dockerfile": "dockerfile",
+}
+
+// lineRange matches the :start-end suffix that selects lines of a file.
+var lineRange = regexp.MustCompile(`:(\d+)-(\d+)$`)
+
+// FileContext reads the file at spec as context for Claude: its content in
+// a code fence with a language hint from its name, under a line naming it.
+// spec is a path, optionally followed by :start-end to only take those
+// lines, as an editor would pass its selection.
+func FileContext(spec string) (string, error) {
+	path, from, to := spec, 0, 0
+	if m := lineRange.FindStringSubmatch(spec); m != nil {
+		path = strings.TrimSuffix(spec, m[0])
+		from, _ = strconv.Atoi(m[1])
+		to, _ = strconv.Atoi(m[2])
+		if from < 1 || to < from {
+			return "", fmt.Errorf("%s: bad line range %d-%d", path, from, to)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.Size() > fileCap {
+		return "", fmt.Errorf("%s is %d KiB, over the %d KiB limit", path, info.Size()>>10, fileCap>>10)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data) {
+		return "", fmt.Errorf("%s is not a text file", path)
+	}
+
+	text := strings.TrimRight(string(data), "\n")
+	name := path
+	if from > 0 {
+		lines := strings.Split(text, "\n")
+		if from > len(lines) {
+			return "", fmt.Errorf("%s has %d lines, no line %d", path, len(lines), from)
+		}
+		text = strings.Join(lines[from-1:min(to, len(lines))], "\n")
+		name = fmt.Sprintf("%s, lines %d to %d", path, from, min(to, len(lines)))
+	}
+
+	lang := fileLanguages[strings.ToLower(filepath.Ext(path))]
+	if lang == "" {
+		lang = fileLanguages[strings.ToLower(filepath.Base(path))]
+	}
+	return fmt.Sprintf("Here is the file `%s` for reference:\n%s", name, fenced(lang, text)), nil
+}
+
+// StdinCap is the most of the input piped to -stdin-context that is
+// attached. Unlike a file, stdin can't be looked at first, so the rest is
+// cut off rather than refused, and Claude is told.
+const StdinCap = fileCap
+
+// StdinContext reads r, the input piped to cclui, as context for Claude,
+// in a code fence like FileContext. Past StdinCap it is cut at the last
+// whole line, and cut reports it.
+func StdinContext(r io.Reader) (context string, cut bool, err error) {
+	data, err := io.ReadAll(io.LimitReader(r, StdinCap+1))
+	if err != nil {
+		return "", false, err
+	}
+	if len(data) > StdinCap {
+		data, cut = data[:StdinCap], true
+		if i := bytes.LastIndexByte(data, '\n'); i >= 0 {
+			data = data[:i]
+		}
+	}
+	if bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data) {
+		return "", false, fmt.Errorf("the input piped to cclui is not text")
+	}
+	context = "Here is the input piped to cclui, for reference:\n" + fenced("", strings.TrimRight(string(data), "\n"))
+	if cut {
+		context += fmt.Sprintf("\n(It was cut off after %d KiB; the rest is missing.)", StdinCap>>10)
+	}
+	return context, cut, nil
+}
+
+// fenced puts text in a code fence tagged with lang. The fence is longer
+// than any run of backticks in text, which may well be Markdown itself.
+func fenced(lang, text string) string {
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + text + "\n" + fence
+}
+
+// AttachFile reads the file at spec, as FileContext does, and attaches it
+// to the next message, like the output of !!.
+func (m Model) AttachFile(spec string) (Model, error) {
+	context, err := FileContext(spec)
+	if err != nil {
+		return m, err
+	}
+	m.attached = append(m.attached, context)
+	m.appendMessage(roleInfo, fmt.Sprintf("Attached %s; it is sent with the next message (Esc with the input empty drops it).", spec))
+	return m, nil
+}
+
+// AttachStdin attaches context, from StdinContext, to the next message,
+// and asks question with it once the interface starts.
+func (m Model) AttachStdin(context, question string) Model {
+	m.attached = append(m.attached, context)
+	m.question = question
+	return m
+}
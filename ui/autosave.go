@@ -0,0 +1,64 @@
+package ui
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const flashDuration = 3 * time.Second
+
+type (
+	autosaveTickMsg struct{}
+	// clearFlashMsg clears the status flash it was scheduled for, unless a
+	// newer one replaced it.
+	clearFlashMsg struct{ id int }
+)
+
+// newAutosaveName names the autosave file of a conversation started now.
+func newAutosaveName() string {
+	return "autosave-" + time.Now().Format("2006-01-02-150405")
+}
+
+func (m Model) autosaveTick() tea.Cmd {
+	if m.config.AutosaveMinutes <= 0 {
+		return nil
+	}
+	return tea.Tick(time.Duration(m.config.AutosaveMinutes)*time.Minute, func(time.Time) tea.Msg {
+		return autosaveTickMsg{}
+	})
+}
+
+// autosave writes the conversation to this session's autosave file when it
+// changed since the last autosave.
+func (m Model) autosave() (Model, tea.Cmd) {
+	n := turns(m.messages)
+	if n == 0 || n == m.autosavedTurns {
+		return m, nil
+	}
+	if _, err := m.saveConversation(m.autosaveName); err != nil {
+		return m.flash("Autosave failed: " + err.Error())
+	}
+	m.autosavedTurns = n
+	return m.flash("Autosaved")
+}
+
+// autosaveAfterTurn autosaves when the configured number of turns has
+// passed.
+func (m Model) autosaveAfterTurn() (Model, tea.Cmd) {
+	every := m.config.AutosaveTurns
+	if every <= 0 || turns(m.messages)-m.autosavedTurns < every {
+		return m, nil
+	}
+	return m.autosave()
+}
+
+// flash shows text in the status line for a few seconds.
+func (m Model) flash(text string) (Model, tea.Cmd) {
+	m.flashID++
+	m.flashText = text
+	id := m.flashID
+	return m, tea.Tick(flashDuration, func(time.Time) tea.Msg {
+		return clearFlashMsg{id: id}
+	})
+}
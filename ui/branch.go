@@ -0,0 +1,155 @@
+package ui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// branch is one line of the conversation tree. It shares its first forkAt
+// turns with its parent branch and continues on its own after that.
+type branch struct {
+	name     string
+	parent   int
+	forkAt   int
+	messages []message
+}
+
+// turns returns how many user turns messages contains.
+func turns(messages []message) int {
+	n := 0
+	for _, msg := range messages {
+		if msg.role == roleUser {
+			n++
+		}
+	}
+	return n
+}
+
+// prefix returns the messages of the first n turns.
+func prefix(messages []message, n int) []message {
+	seen := 0
+	for i, msg := range messages {
+		if msg.role == roleUser {
+			if seen == n {
+				return messages[:i]
+			}
+			seen++
+		}
+	}
+	return messages
+}
+
+// fork starts a new branch that keeps the first n turns of the current one
+// and switches to it.
+func (m *Model) fork(n int) {
+	m.branches[m.branch].messages = m.messages
+
+	kept := prefix(m.messages, n)
+	b := branch{
+		name:     fmt.Sprintf("branch-%d", len(m.branches)),
+		parent:   m.branch,
+		forkAt:   n,
+		messages: append([]message(nil), kept...),
+	}
+	m.branches = append(m.branches, b)
+	m.branch = len(m.branches) - 1
+	m.messages = b.messages
+	m.resetArchive()
+}
+
+// switchTo makes branch i the live transcript.
+func (m *Model) switchTo(i int) {
+	m.branches[m.branch].messages = m.messages
+	m.branch = i
+	m.messages = m.branches[i].messages
+	m.resetArchive()
+}
+
+// branchCommand handles /branch [turns]. Without an argument the last turn is
+// dropped, so the new branch is ready for an alternative to the last prompt.
+func (m Model) branchCommand(args []string) (Model, error) {
+	if m.streaming {
+		return m, fmt.Errorf("wait for the response to finish before branching")
+	}
+
+	total := turns(m.messages)
+	n := total - 1
+	if len(args) > 0 {
+		v, err := strconv.Atoi(args[0])
+		if err != nil || v < 0 || v > total {
+			return m, fmt.Errorf("usage: /branch [turns], with turns between 0 and %d", total)
+		}
+		n = v
+	}
+	if n < 0 {
+		n = 0
+	}
+
+	m.fork(n)
+	m.appendMessage(roleInfo, fmt.Sprintf("Switched to %s, keeping %d of %d turns", m.branches[m.branch].name, n, total))
+	return m, nil
+}
+
+func (m Model) switchCommand(args []string) (Model, error) {
+	if m.streaming {
+		return m, fmt.Errorf("wait for the response to finish before switching")
+	}
+	if len(args) != 1 {
+		return m, fmt.Errorf("usage: /switch <branch>")
+	}
+
+	i := m.findBranch(args[0])
+	if i < 0 {
+		return m, fmt.Errorf("no branch %q, see /branches", args[0])
+	}
+	m.switchTo(i)
+	m.refresh()
+	return m, nil
+}
+
+// findBranch resolves a branch by name or by its number in /branches.
+func (m Model) findBranch(ref string) int {
+	for i, b := range m.branches {
+		if b.name == ref {
+			return i
+		}
+	}
+	if i, err := strconv.Atoi(ref); err == nil && i >= 0 && i < len(m.branches) {
+		return i
+	}
+	return -1
+}
+
+// listBranches renders the tree, children indented under their parent.
+func (m Model) listBranches() string {
+	children := map[int][]int{}
+	for i, b := range m.branches {
+		children[b.parent] = append(children[b.parent], i)
+	}
+
+	var b strings.Builder
+	b.WriteString("Branches:")
+	var walk func(parent, depth int)
+	walk = func(parent, depth int) {
+		for _, i := range children[parent] {
+			br := m.branches[i]
+			messages := br.messages
+			if i == m.branch {
+				messages = m.messages
+			}
+			marker := " "
+			if i == m.branch {
+				marker = "*"
+			}
+			fmt.Fprintf(&b, "\n%s %s%d %s (%d turns", marker, strings.Repeat("  ", depth), i, br.name, turns(messages))
+			if br.parent >= 0 {
+				fmt.Fprintf(&b, ", from %s after turn %d", m.branches[br.parent].name, br.forkAt)
+			}
+			b.WriteString(")")
+			walk(i, depth+1)
+		}
+	}
+	walk(-1, 0)
+	return b.String()
+}
@@ -0,0 +1,72 @@
+package ui
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// clockTickMsg updates the session clock of the status line, unless a later
+// clockTick replaced the one that scheduled it.
+type clockTickMsg struct{ id int }
+
+// clockTick schedules the next update of the session clock, on the next
+// whole minute of the session, while it is shown. Only the status line
+// changes: the transcript isn't rendered again.
+func (m *Model) clockTick() tea.Cmd {
+	m.clockID++
+	if !m.showClock {
+		return nil
+	}
+	id := m.clockID
+	return tea.Tick(time.Minute-time.Since(m.startedAt)%time.Minute, func(time.Time) tea.Msg {
+		return clockTickMsg{id: id}
+	})
+}
+
+// clock is the session clock of the status line: how long the session has
+// lasted and, once a message went out, how long ago the last message was
+// sent or reply ended.
+func (m Model) clock() string {
+	if !m.showClock {
+		return ""
+	}
+	s := "⏱ " + formatElapsed(time.Since(m.startedAt))
+	if !m.lastActivity.IsZero() {
+		if since := time.Since(m.lastActivity); since < time.Minute {
+			s += ", active now"
+		} else {
+			s += ", active " + formatElapsed(since) + " ago"
+		}
+	}
+	return s
+}
+
+// formatElapsed formats d to the minute, e.g. 7m or 1h05m.
+func formatElapsed(d time.Duration) string {
+	minutes := int(d / time.Minute)
+	if minutes < 60 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%dh%02dm", minutes/60, minutes%60)
+}
+
+// clockCommand handles /clock [on|off], which shows or hides the session
+// clock, or toggles it without an argument.
+func (m Model) clockCommand(args []string) (Model, tea.Cmd, error) {
+	switch {
+	case len(args) == 0:
+		m.showClock = !m.showClock
+	case args[0] == "on" || args[0] == "off":
+		m.showClock = args[0] == "on"
+	default:
+		return m, nil, fmt.Errorf("usage: /clock [on|off]")
+	}
+	text := "Session clock off"
+	if m.showClock {
+		text = "Session clock on"
+	}
+	m, flash := m.flash(text)
+	return m, tea.Batch(flash, m.clockTick()), nil
+}
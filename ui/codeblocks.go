@@ -0,0 +1,280 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	runTimeout   = 30 * time.Second
+	runOutputCap = 64 << 10
+	// runWaitDelay is how long a command that exited is waited on for
+	// children of it still holding its output.
+	runWaitDelay = time.Second
+)
+
+// cappedOutput keeps the first runOutputCap bytes written to it and drops
+// the rest as it comes, so that a chatty command can't fill the memory.
+type cappedOutput struct {
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (w *cappedOutput) Write(p []byte) (int, error) {
+	n := len(p)
+	if room := runOutputCap - w.buf.Len(); n > room {
+		p, w.truncated = p[:room], true
+	}
+	w.buf.Write(p)
+	return n, nil
+}
+
+// String is the output kept, marked when some was dropped.
+func (w *cappedOutput) String() string {
+	if w.truncated {
+		return w.buf.String() + "\n[output truncated]"
+	}
+	return w.buf.String()
+}
+
+type codeBlock struct {
+	lang string
+	code string
+}
+
+// fence is the position of a fenced code block within a slice of lines:
+// open and close are the indices of the fence lines. close is len(lines)
+// for a block that is never closed, such as one still streaming.
+type fence struct {
+	open, close int
+	lang        string
+}
+
+// findFences locates ``` and ~~~ fenced blocks the way CommonMark does: the
+// fence may be indented by up to three spaces and is closed by a fence of
+// the same character that is at least as long.
+func findFences(lines []string) []fence {
+	var (
+		fences []fence
+		cur    *fence
+		marker string
+	)
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " ")
+		if len(line)-len(trimmed) > 3 {
+			continue
+		}
+		if cur != nil {
+			if strings.HasPrefix(trimmed, marker) && strings.Trim(strings.TrimSpace(trimmed), marker[:1]) == "" {
+				cur.close = i
+				fences = append(fences, *cur)
+				cur = nil
+			}
+			continue
+		}
+		for _, c := range []string{"`", "~"} {
+			n := len(trimmed) - len(strings.TrimLeft(trimmed, c))
+			if n < 3 {
+				continue
+			}
+			marker = strings.Repeat(c, n)
+			info := strings.Fields(trimmed[n:])
+			cur = &fence{open: i}
+			if len(info) > 0 {
+				cur.lang = strings.ToLower(info[0])
+			}
+			break
+		}
+	}
+	if cur != nil {
+		cur.close = len(lines)
+		fences = append(fences, *cur)
+	}
+	return fences
+}
+
+// parseCodeBlocks returns the fenced code blocks of text, with their
+// contents exactly as written.
+func parseCodeBlocks(text string) []codeBlock {
+	lines := strings.Split(text, "\n")
+	var blocks []codeBlock
+	for _, f := range findFences(lines) {
+		code := strings.Join(lines[f.open+1:f.close], "\n")
+		if f.close > f.open+1 {
+			code += "\n"
+		}
+		blocks = append(blocks, codeBlock{lang: f.lang, code: code})
+	}
+	return blocks
+}
+
+// labelCodeBlocks puts a numbered label above every code block of text.
+// next is the number of the first block and is advanced past the last one,
+// so numbering continues across messages.
+func (m Model) labelCodeBlocks(text string, next *int) string {
+	lines := strings.Split(text, "\n")
+	fences := findFences(lines)
+	if len(fences) == 0 {
+		return text
+	}
+
+	out := make([]string, 0, len(lines)+len(fences))
+	f := 0
+	for i, line := range lines {
+		if f < len(fences) && fences[f].open == i {
+			out = append(out, m.codeLabel(*next, fences[f].lang))
+			*next++
+			f++
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+// codeLabel is the label of code block n, in lang. The block c and C
+// stepped to stands out and says how to copy it.
+func (m Model) codeLabel(n int, lang string) string {
+	label := fmt.Sprintf("── code #%d", n)
+	if lang != "" {
+		label += " (" + lang + ")"
+	}
+	if n == m.activeCode && m.selectedMessage() >= 0 {
+		return m.pinStyle.Render(label + "  ◀ y copies it")
+	}
+	return m.codeLabelStyle.Render(label)
+}
+
+// codeBlocks returns the code blocks of every reply in the transcript,
+// numbered from 1 in the order labelCodeBlocks shows them.
+func (m Model) codeBlocks() []codeBlock {
+	var blocks []codeBlock
+	for _, msg := range m.messages {
+		if msg.role == roleAssistant {
+			blocks = append(blocks, parseCodeBlocks(msg.content)...)
+		}
+	}
+	return blocks
+}
+
+func (m Model) codeBlock(arg string) (codeBlock, int, error) {
+	blocks := m.codeBlocks()
+	if len(blocks) == 0 {
+		return codeBlock{}, 0, errors.New("there are no code blocks yet")
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(arg, "#"))
+	if err != nil || n < 1 || n > len(blocks) {
+		return codeBlock{}, 0, fmt.Errorf("no code block %q, blocks are numbered 1 to %d", arg, len(blocks))
+	}
+	return blocks[n-1], n, nil
+}
+
+var langExtensions = map[string]string{
+	"go": ".go", "python": ".py", "py": ".py", "javascript": ".js", "js": ".js",
+	"typescript": ".ts", "ts": ".ts", "tsx": ".tsx", "jsx": ".jsx",
+	"bash": ".sh", "sh": ".sh", "shell": ".sh", "zsh": ".sh",
+	"rust": ".rs", "c": ".c", "cpp": ".cpp", "c++": ".cpp", "java": ".java",
+	"ruby": ".rb", "rb": ".rb", "perl": ".pl", "php": ".php", "lua": ".lua",
+	"json": ".json", "yaml": ".yaml", "yml": ".yaml", "toml": ".toml",
+	"html": ".html", "css": ".css", "sql": ".sql", "markdown": ".md", "md": ".md",
+	"dockerfile": ".dockerfile", "makefile": ".mk",
+}
+
+// saveCodeBlock handles /savecode <n> <file>. A file name without an
+// extension gets one from the block's language; existing files are never
+// overwritten.
+func (m Model) saveCodeBlock(args []string) (string, error) {
+	if len(args) != 2 {
+		return "", errors.New("usage: /savecode <n> <file>")
+	}
+	block, _, err := m.codeBlock(args[0])
+	if err != nil {
+		return "", err
+	}
+
+	path := args[1]
+	if filepath.Ext(path) == "" {
+		ext, ok := langExtensions[block.lang]
+		if !ok {
+			ext = ".txt"
+		}
+		path += ext
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.WriteString(block.code); err != nil {
+		f.Close()
+		return "", err
+	}
+	return path, f.Close()
+}
+
+// runners maps the languages /runcode accepts to an interpreter that reads
+// the program from stdin.
+var runners = map[string][]string{
+	"python": {"python3", "-"}, "py": {"python3", "-"}, "python3": {"python3", "-"},
+	"sh": {"sh", "-s"}, "bash": {"bash", "-s"}, "shell": {"sh", "-s"}, "zsh": {"zsh", "-s"},
+	"javascript": {"node", "-"}, "js": {"node", "-"}, "node": {"node", "-"},
+	"ruby": {"ruby", "-"}, "rb": {"ruby", "-"},
+	"perl": {"perl", "-"},
+}
+
+// codeRunMsg reports the outcome of /runcode.
+type codeRunMsg struct {
+	n      int
+	output string
+	err    error
+}
+
+// runCodeBlock handles /runcode <n>: it asks for confirmation, then runs the
+// block with the interpreter for its language.
+func (m Model) runCodeBlock(args []string) (Model, error) {
+	if len(args) != 1 {
+		return m, errors.New("usage: /runcode <n>")
+	}
+	block, n, err := m.codeBlock(args[0])
+	if err != nil {
+		return m, err
+	}
+	argv, ok := runners[block.lang]
+	if !ok {
+		return m, fmt.Errorf("code #%d is %q, /runcode only runs python, sh, bash, zsh, javascript, ruby and perl", n, block.lang)
+	}
+
+	prompt := fmt.Sprintf("Run code #%d with %s?", n, strings.Join(argv, " "))
+	return m.ask(prompt, func(m Model) (tea.Model, tea.Cmd) {
+		m.appendMessage(roleInfo, fmt.Sprintf("Running code #%d…", n))
+		return m, runCode(n, argv, block.code)
+	}), nil
+}
+
+func runCode(n int, argv []string, code string) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), runTimeout)
+		defer cancel()
+
+		var out cappedOutput
+		cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+		cmd.Stdin = strings.NewReader(code)
+		cmd.Stdout = &out
+		cmd.Stderr = &out
+		cmd.WaitDelay = runWaitDelay
+		err := cmd.Run()
+		if errors.Is(err, exec.ErrWaitDelay) {
+			err = nil
+		}
+		return codeRunMsg{n: n, output: out.String(), err: err}
+	}
+}
@@ -0,0 +1,49 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+)
+
+// setCodeOnly handles /code [on|off]. While on, replies show only their
+// code blocks; the full text stays in the conversation, and /page or
+// /code off show it. Without an argument it shows the current setting.
+func (m Model) setCodeOnly(args []string) (Model, error) {
+	switch {
+	case len(args) == 0:
+		if m.codeOnly {
+			m.appendMessage(roleInfo, "Responses show only their code blocks.")
+		} else {
+			m.appendMessage(roleInfo, "Responses are shown in full.")
+		}
+	case len(args) > 1 || (args[0] != "on" && args[0] != "off"):
+		return m, fmt.Errorf("usage: /code [on|off]")
+	case args[0] == "on":
+		m.codeOnly = true
+		m.appendMessage(roleInfo, "Responses will show only their code blocks (/code off shows them in full).")
+	default:
+		m.codeOnly = false
+		m.appendMessage(roleInfo, "Responses are shown in full again.")
+	}
+	return m, nil
+}
+
+// codeOnlyText keeps only the code blocks of text, labelled and numbered
+// from next as labelCodeBlocks does, a blank line apart. A reply without
+// code says so instead.
+func (m Model) codeOnlyText(text string, next *int) string {
+	lines := strings.Split(text, "\n")
+	fences := findFences(lines)
+	if len(fences) == 0 {
+		return m.codeLabelStyle.Render("⋯ no code in this response (/code off shows it)")
+	}
+
+	blocks := make([]string, 0, len(fences))
+	for _, f := range fences {
+		label := m.codeLabel(*next, f.lang)
+		*next++
+		block := lines[f.open:min(f.close+1, len(lines))]
+		blocks = append(blocks, label+"\n"+strings.Join(block, "\n"))
+	}
+	return strings.Join(blocks, "\n\n")
+}
@@ -0,0 +1,267 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bnema/cclui/api"
+	"github.com/bnema/cclui/config"
+	"github.com/bnema/cclui/prompts"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// commandOutputMsg carries text produced by a slash command, possibly
+// asynchronously, into the transcript.
+type commandOutputMsg string
+
+func isCommand(input string) bool {
+	return strings.HasPrefix(strings.TrimSpace(input), "/")
+}
+
+// splitArgs splits a command line on whitespace, keeping single- or
+// double-quoted sections together. A quote only opens one at the start of
+// an argument or right after an =, so that an apostrophe in a word, as in
+// text=don't, is kept as it is.
+func splitArgs(input string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		quote   rune
+		inArg   bool
+		last    rune
+	)
+	for _, r := range input {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case (r == '"' || r == '\'') && (!inArg || last == '='):
+			quote, inArg = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+		last = r
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// runCommand parses a command line and runs the command of the registry it
+// names.
+func (m Model) runCommand(input string) (tea.Model, tea.Cmd) {
+	fields, err := splitArgs(strings.TrimSpace(input))
+	if err != nil {
+		m.appendMessage(roleError, err.Error())
+		return m, nil
+	}
+	name, args := fields[0], fields[1:]
+
+	c, ok := commandIndex[name]
+	if !ok {
+		m.appendMessage(roleInfo, unknownCommand(name))
+		return m, nil
+	}
+	if err := c.checkArgs(name, args); err != nil {
+		m.appendMessage(roleError, err.Error())
+		return m, nil
+	}
+	return c.run(m, name, args)
+}
+
+// whoami reports which account the configured key belongs to. Organization
+// metadata is only readable with an admin key, so for regular keys it falls
+// back to the masked key and the base URL.
+func (m Model) whoami() tea.Cmd {
+	client := m.client
+	key, baseURL, proxy := m.config.APIKey, m.config.BaseURL, m.config.Proxy
+	return m.recovering(func() tea.Msg {
+		var b strings.Builder
+		fmt.Fprintf(&b, "Key:      %s\n", api.MaskKey(key))
+		fmt.Fprintf(&b, "Base URL: %s", baseURL)
+		if proxy != nil {
+			fmt.Fprintf(&b, "\nProxy:    %s", proxy.Redacted())
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		org, err := client.Organization(ctx)
+		if err != nil {
+			fmt.Fprintf(&b, "\nOrganization: unavailable (%v)", err)
+			return commandOutputMsg(b.String())
+		}
+		fmt.Fprintf(&b, "\nOrganization: %s (%s)", org.Name, org.ID)
+		return commandOutputMsg(b.String())
+	}, commandFailed)
+}
+
+// info summarizes the session state in one block.
+func (m Model) info() string {
+	count := 0
+	for _, msg := range m.messages {
+		if conversational(msg.role) {
+			count++
+		}
+	}
+	usage := m.usage()
+
+	system := "(none)"
+	if m.params.System != "" {
+		system = truncate(strings.Join(strings.Fields(m.params.System), " "), 60)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Model:       %s\n", m.params.Model)
+	if m.persona != "" {
+		fmt.Fprintf(&b, "Persona:     %s\n", m.persona)
+	}
+	fmt.Fprintf(&b, "System:      %s\n", system)
+	fmt.Fprintf(&b, "Max tokens:  %d\n", m.params.MaxTokens)
+	fmt.Fprintf(&b, "Temperature: %s\n", formatParam(m.params.Temperature))
+	fmt.Fprintf(&b, "Top P:       %s\n", formatParam(m.params.TopP))
+	fmt.Fprintf(&b, "Tier:        %s\n", m.serviceTier())
+	if len(m.tags) > 0 {
+		fmt.Fprintf(&b, "Tags:        %s\n", formatTags(m.tags))
+	}
+	fmt.Fprintf(&b, "Messages:    %d\n", count)
+	fmt.Fprintf(&b, "Tokens:      %d in / %d out\n", usage.InputTokens, usage.OutputTokens)
+	fmt.Fprintf(&b, "Base URL:    %s", m.config.BaseURL)
+	return b.String()
+}
+
+func formatParam(v *float64) string {
+	if v == nil {
+		return "default"
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+// truncate shortens s to at most n runes, marking the cut with an ellipsis.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+// describeConfig shows which source each setting was resolved from. It
+// reflects startup or the last /reload-config; later changes made with
+// commands are not included.
+func (m Model) describeConfig() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Config file: %s", m.config.File)
+	for _, e := range m.config.Describe() {
+		value := e.Value
+		if value == "" {
+			value = "(unset)"
+		}
+		value = truncate(strings.Join(strings.Fields(value), " "), 40)
+		fmt.Fprintf(&b, "\n  %-17s %-40s %s", e.Key, value, e.Source)
+		if e.Origin != "" {
+			b.WriteString(" (" + e.Origin + ")")
+		}
+	}
+	return b.String()
+}
+
+func listPaths() (string, error) {
+	p, err := config.ResolvePaths()
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Config:        %s\n", p.Config)
+	fmt.Fprintf(&b, "Templates:     %s\n", p.Templates)
+	fmt.Fprintf(&b, "Conversations: %s\n", p.Conversations)
+	fmt.Fprintf(&b, "Archive:       %s\n", p.Archive)
+	fmt.Fprintf(&b, "Logs:          %s\n", p.Logs)
+	fmt.Fprintf(&b, "History:       %s\n", p.History)
+	fmt.Fprintf(&b, "Last session:  %s\n", p.LastSession)
+	fmt.Fprintf(&b, "Cache:         %s", p.Cache)
+	return b.String(), nil
+}
+
+func listTemplates() (string, error) {
+	dir, err := config.TemplatesDir()
+	if err != nil {
+		return "", err
+	}
+	names, err := prompts.List(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(names) == 0 {
+		return fmt.Sprintf("No templates in %s", dir), nil
+	}
+
+	var b strings.Builder
+	b.WriteString("Templates:")
+	for _, name := range names {
+		b.WriteString("\n  " + name)
+		if t, err := prompts.Load(dir, name); err == nil && len(t.Vars()) > 0 {
+			b.WriteString(" (" + strings.Join(t.Vars(), ", ") + ")")
+		}
+	}
+	return b.String(), nil
+}
+
+// expandTemplate handles the arguments of /use and /run:
+// <template> [key=value …]. A word without = carries on the value before
+// it, so text=I don't know needs no quotes.
+func expandTemplate(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", fmt.Errorf("usage: /use <template> [key=value …]")
+	}
+
+	dir, err := config.TemplatesDir()
+	if err != nil {
+		return "", err
+	}
+	t, err := prompts.Load(dir, args[0])
+	if err != nil {
+		return "", err
+	}
+
+	values, err := templateValues(args[1:])
+	if err != nil {
+		return "", err
+	}
+	return t.Expand(values)
+}
+
+// templateValues reads the key=value arguments of expandTemplate.
+func templateValues(args []string) (map[string]string, error) {
+	values := map[string]string{}
+	key := ""
+	for _, arg := range args {
+		k, value, ok := strings.Cut(arg, "=")
+		switch {
+		case ok && k != "":
+			key = k
+			values[key] = value
+		case key != "":
+			values[key] += " " + arg
+		default:
+			return nil, fmt.Errorf("expected key=value, got %q", arg)
+		}
+	}
+	return values, nil
+}
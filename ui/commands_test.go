@@ -0,0 +1,47 @@
+package ui
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+		err   string
+	}{
+		{"/use translate text=I don't know", []string{"/use", "translate", "text=I", "don't", "know"}, ""},
+		{`/use review lang=go focus="error handling"`, []string{"/use", "review", "lang=go", "focus=error handling"}, ""},
+		{"/use t text='it is \"fine\"'", []string{"/use", "t", `text=it is "fine"`}, ""},
+		{`/load "my chat"`, []string{"/load", "my chat"}, ""},
+		{"/export 'my notes'.md", []string{"/export", "my notes.md"}, ""},
+		{"/tag rock'n'roll  jazz", []string{"/tag", "rock'n'roll", "jazz"}, ""},
+		{"/untag a\tb\nc", []string{"/untag", "a", "b", "c"}, ""},
+		{`/load "my chat`, nil, `unterminated " quote`},
+		{"/use t text='open", nil, "unterminated ' quote"},
+	}
+	for _, tt := range tests {
+		got, err := splitArgs(tt.input)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("splitArgs(%q) error = %v, want %q", tt.input, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitArgs(%q) = %q, %v, want %q", tt.input, got, err, tt.want)
+		}
+	}
+}
+
+func TestTemplateValues(t *testing.T) {
+	got, err := templateValues([]string{"text=I", "don't", "know", "lang=fr"})
+	want := map[string]string{"text": "I don't know", "lang": "fr"}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("values = %q, %v, want %q", got, err, want)
+	}
+	if _, err := templateValues([]string{"loose", "text=hi"}); err == nil {
+		t.Error("a word before any key=value was taken")
+	}
+}
@@ -0,0 +1,65 @@
+package ui
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// setCompact handles /compact and /expand, which switch the transcript
+// between its compact and full rendering. Only the display changes; the
+// messages, and what is sent to Claude, stay as they are.
+func (m Model) setCompact(on bool) (Model, tea.Cmd) {
+	m.compactView = on
+	m.refresh()
+	if on {
+		return m.flash("Compact transcript (/expand restores it)")
+	}
+	return m.flash("Full transcript")
+}
+
+// compactText drops trailing whitespace and collapses runs of blank lines
+// into one, leaving code blocks as they are.
+func compactText(text string) string {
+	lines := strings.Split(text, "\n")
+	out := make([]string, 0, len(lines))
+	inCode, blank := false, false
+	for _, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCode = !inCode
+		} else if !inCode {
+			line = strings.TrimRight(line, " \t")
+			if line == "" {
+				if blank {
+					continue
+				}
+				blank = true
+			} else {
+				blank = false
+			}
+		}
+		out = append(out, line)
+	}
+	return strings.Trim(strings.Join(out, "\n"), "\n")
+}
+
+// mergesWithPrevious reports whether the note at i is folded into the one
+// before it in the compact transcript, showing both under a single label.
+func (m Model) mergesWithPrevious(i int) bool {
+	return m.compactView && i > m.hiddenMessages() &&
+		m.messages[i].role == roleInfo && m.messages[i-1].role == roleInfo
+}
+
+// continued renders text like labelled, aligned under the label of the
+// message above rather than repeating it.
+func (m Model) continued(role, text string) string {
+	r := m.roles[role]
+	if r.label == "" {
+		return m.labelled(role, text, false)
+	}
+	width := lipgloss.Width(r.label+":") + 1
+	indent := strings.Repeat(" ", width)
+	text = wrap(text, m.wrapWidth()-width)
+	return indent + strings.ReplaceAll(text, "\n", "\n"+indent)
+}
@@ -0,0 +1,59 @@
+package ui
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/sahilm/fuzzy"
+)
+
+// commandNames returns the names of the commands of the registry, in its
+// order.
+func commandNames() []string {
+	var names []string
+	for _, c := range commands {
+		names = append(names, c.names...)
+	}
+	return names
+}
+
+// completions returns the commands the input may be the start of, best
+// fuzzy match first, while a command name is being typed: the input starts with
+// a slash and has no space yet. Esc dismisses them until the input
+// changes.
+func (m Model) completions() []string {
+	input := m.textarea.Value()
+	if !strings.HasPrefix(input, "/") || strings.ContainsAny(input, " \t\n") || input == m.dismissedCompletion || m.scrolling {
+		return nil
+	}
+	if input == "/" {
+		return commandNames()
+	}
+	var names []string
+	for _, match := range fuzzy.Find(input, commandNames()) {
+		names = append(names, match.Str)
+	}
+	return names
+}
+
+// complete handles Tab while completions are offered: the input becomes
+// the best match and a space, ready for arguments.
+func (m Model) complete(names []string) (tea.Model, tea.Cmd) {
+	m.textarea.SetValue(names[0] + " ")
+	return m, nil
+}
+
+// completionLine is the menu of completions, shown in place of the status
+// line. It lists as many as fit, the one Tab takes first.
+func (m Model) completionLine(names []string) string {
+	line := "tab " + m.senderStyle.Render(names[0])
+	width := len("tab ") + len(names[0])
+	for _, name := range names[1:] {
+		if width+2+len(name) > m.viewport.Width {
+			break
+		}
+		line += "  " + name
+		width += 2 + len(name)
+	}
+	return m.statusStyle.Render(line)
+}
@@ -0,0 +1,54 @@
+package ui
+
+import tea "github.com/charmbracelet/bubbletea"
+
+// confirmation is a yes/no question that takes over the input until it is
+// answered.
+type confirmation struct {
+	prompt string
+	onYes  func(Model) (tea.Model, tea.Cmd)
+	// onAlways, if set, is run for a, yes for the rest of the session.
+	onAlways func(Model) (tea.Model, tea.Cmd)
+}
+
+func (m Model) ask(prompt string, onYes func(Model) (tea.Model, tea.Cmd)) Model {
+	m.pending = &confirmation{prompt: prompt, onYes: onYes}
+	return m
+}
+
+// askAlways is ask with a third answer, a, to say yes for the rest of the
+// session.
+func (m Model) askAlways(prompt string, onYes, onAlways func(Model) (tea.Model, tea.Cmd)) Model {
+	m.pending = &confirmation{prompt: prompt, onYes: onYes, onAlways: onAlways}
+	return m
+}
+
+// keys are the answers the confirmation takes, as shown after its prompt.
+func (c *confirmation) keys() string {
+	if c.onAlways != nil {
+		return "[y/n/a]"
+	}
+	return "[y/n]"
+}
+
+// answer handles a key press while a confirmation is pending. Keys other
+// than y, n, Esc and, if it takes it, a are ignored.
+func (m Model) answer(key tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := m.pending
+	switch key.String() {
+	case "y", "Y":
+		m.pending = nil
+		return p.onYes(m)
+	case "a", "A":
+		if p.onAlways != nil {
+			m.pending = nil
+			return p.onAlways(m)
+		}
+	case "n", "N", "esc":
+		m.pending = nil
+		m.appendMessage(roleInfo, "Cancelled.")
+	case "ctrl+c":
+		return m, tea.Quit
+	}
+	return m, nil
+}
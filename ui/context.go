@@ -0,0 +1,262 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bnema/cclui/api"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// contextWindow is the context size of the Claude 3 models, in tokens.
+const contextWindow = 200000
+
+// unrunTool is the result sent back for the tool calls of a reply, which
+// cclui passes on but doesn't run.
+const unrunTool = "cclui does not run tools"
+
+// contextTurn is a user message and the reply to it, if any. Blocks are
+// the content blocks to send the reply as, see resentBlocks.
+type contextTurn struct {
+	user      string
+	assistant string
+	blocks    []api.ContentBlock
+	pinned    bool
+}
+
+// blank reports whether text has nothing but whitespace in it.
+func blank(text string) bool {
+	return strings.TrimSpace(text) == ""
+}
+
+func (t contextTurn) tokens() int {
+	return api.EstimateTokens(t.user) + api.EstimateTokens(t.assistant)
+}
+
+// contextTurns groups the transcript into turns. Only conversational
+// messages count, never what is only shown; a user message left without a
+// reply (the request failed, or the reply came back empty) is dropped so
+// that roles keep alternating.
+func (m Model) contextTurns() []contextTurn {
+	var turns []contextTurn
+	for _, msg := range m.messages {
+		switch {
+		case msg.pruned || !conversational(msg.role):
+		case msg.role == roleUser:
+			if n := len(turns); n > 0 && turns[n-1].assistant == "" && turns[n-1].blocks == nil {
+				turns = turns[:n-1]
+			}
+			turns = append(turns, contextTurn{user: msg.content, pinned: msg.pinned})
+		case msg.role == roleAssistant && len(turns) > 0:
+			blocks := msg.resentBlocks(len(m.params.Tools) > 0)
+			if blank(msg.content) && blocks == nil {
+				continue
+			}
+			t := &turns[len(turns)-1]
+			t.assistant = msg.content
+			t.blocks = blocks
+			t.pinned = t.pinned || msg.pinned
+		}
+	}
+	return turns
+}
+
+// trimTurns drops the oldest unpinned turns until the rest fits in budget
+// tokens. The last turn is always kept.
+func trimTurns(turns []contextTurn, budget int) []contextTurn {
+	total := 0
+	for _, t := range turns {
+		total += t.tokens()
+	}
+
+	out := make([]contextTurn, 0, len(turns))
+	for i, t := range turns {
+		if total > budget && !t.pinned && i < len(turns)-1 {
+			total -= t.tokens()
+			continue
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+// contextMessages returns the conversation as sent to the API, trimmed to
+// leave room in the context window for the system prompt and the reply.
+func (m Model) contextMessages() []api.MessageToSend {
+	budget := contextWindow - m.params.MaxTokens - api.EstimateTokens(m.systemPrompt())
+	var out []api.MessageToSend
+	var calls []api.ContentBlock
+	for _, t := range trimTurns(m.contextTurns(), budget) {
+		user := api.ConstructUserMessage(t.user)
+		// The API wants every tool call answered in the next message.
+		for _, call := range calls {
+			user.Blocks = append(user.Blocks, api.ContentBlock{Type: "tool_result", ToolUseID: call.ID, Content: unrunTool, IsError: true})
+		}
+		if user.Blocks != nil {
+			user.Blocks = append(user.Blocks, api.ContentBlock{Type: "text", Text: t.user})
+		}
+		out = append(out, user)
+
+		calls = nil
+		if t.assistant != "" || t.blocks != nil {
+			out = append(out, api.MessageToSend{Role: roleAssistant, Content: t.assistant, Blocks: t.blocks})
+		}
+		for _, block := range t.blocks {
+			if block.Type == "tool_use" {
+				calls = append(calls, block)
+			}
+		}
+	}
+	return out
+}
+
+// resentBlocks returns the content blocks to send a reply back as, or nil
+// when its text is enough. Replies with signed or redacted thinking are
+// sent back with it, as the API asks, and with their tool calls when tools
+// are still offered. Thinking without a signature, from a stream that was
+// cut short, is left out, as is blank text, which the API refuses. A reply
+// with nothing but thinking left has nothing to send.
+func (msg message) resentBlocks(tools bool) []api.ContentBlock {
+	var out []api.ContentBlock
+	needed, said := false, false
+	for _, block := range msg.blocks {
+		switch {
+		case block.Type == "text" && !blank(block.Text):
+			said = true
+		case block.Type == "tool_use" && tools:
+			needed, said = true, true
+		case block.Type == "thinking" && block.Signature != "",
+			block.Type == "redacted_thinking":
+			needed = true
+		default:
+			continue
+		}
+		out = append(out, block)
+	}
+	if !needed || !said {
+		return nil
+	}
+	return out
+}
+
+// contextTokens estimates the input tokens of the next request: the system
+// prompt, the context, the output attached with !! and what is typed in the
+// input so far.
+func (m Model) contextTokens() int {
+	tokens := api.EstimateTokens(m.systemPrompt()) + api.EstimateTokens(m.textarea.Value())
+	for _, a := range m.attached {
+		tokens += api.EstimateTokens(a)
+	}
+	for _, msg := range m.contextMessages() {
+		tokens += api.EstimateTokens(msg.Content)
+	}
+	return tokens
+}
+
+// countTokens handles /tokens, asking the API for the exact size of the
+// context that contextTokens estimates.
+func (m Model) countTokens() tea.Cmd {
+	client := m.client
+	req := m.structuredRequest(m.params)
+	req.System = m.systemPrompt()
+	req.Messages = m.contextMessages()
+	estimate := m.contextTokens()
+	return m.recovering(func() tea.Msg {
+		if len(req.Messages) == 0 {
+			return commandOutputMsg("The context is empty.")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		exact, err := client.CountTokens(ctx, req)
+		if err != nil {
+			return commandOutputMsg(fmt.Sprintf("Context: ~%d tokens (estimate; counting failed: %v)", estimate, err))
+		}
+		return commandOutputMsg(fmt.Sprintf("Context: %d tokens (estimated %d)", exact, estimate))
+	}, commandFailed)
+}
+
+// setPinned handles /pin and /unpin [turn], defaulting to the last turn.
+// Pinning applies to both the prompt and its reply.
+func (m Model) setPinned(args []string, pinned bool) (Model, error) {
+	total := turns(m.messages)
+	if total == 0 {
+		return m, fmt.Errorf("nothing to pin yet")
+	}
+	n := total
+	if len(args) > 0 {
+		v, err := strconv.Atoi(args[0])
+		if err != nil || v < 1 || v > total {
+			return m, fmt.Errorf("usage: /pin [turn], with turn between 1 and %d", total)
+		}
+		n = v
+	}
+
+	seen := 0
+	for i := range m.messages {
+		msg := &m.messages[i]
+		if msg.role == roleUser {
+			seen++
+		}
+		if seen == n && conversational(msg.role) {
+			msg.pinned = pinned
+			// Pinning a pruned turn brings it back.
+			msg.pruned = msg.pruned && !pinned
+		}
+	}
+	m.refresh()
+	return m, nil
+}
+
+// prune handles /prune <turns>, which leaves all but the last turns out of
+// the context, pinned ones aside. The transcript keeps them, marked.
+func (m Model) prune(args []string) (Model, error) {
+	keep, err := strconv.Atoi(args[0])
+	if err != nil || keep < 0 {
+		return m, fmt.Errorf("usage: /prune <turns>, with turns the number of last turns to keep, 0 or more")
+	}
+	total := turns(m.messages)
+	if keep >= total {
+		return m, fmt.Errorf("there are only %d turns, nothing to prune", total)
+	}
+
+	before := m.contextTokens()
+	pinned := false
+	pruned := 0
+	seen := 0
+	for i := range m.messages {
+		msg := &m.messages[i]
+		if msg.role == roleUser {
+			seen++
+			pinned = m.turnPinned(i)
+			if seen <= total-keep && !pinned && !msg.pruned {
+				pruned++
+			}
+		}
+		if seen <= total-keep && !pinned && conversational(msg.role) {
+			msg.pruned = true
+		}
+	}
+	if pruned == 0 {
+		return m, fmt.Errorf("the turns before the last %d are already out of the context or pinned", keep)
+	}
+	m.appendMessage(roleInfo, fmt.Sprintf("Pruned %d turns from the context, freeing ~%d tokens. They stay in the transcript; /pin brings one back.", pruned, before-m.contextTokens()))
+	return m, nil
+}
+
+// turnPinned reports whether the turn starting with the user message at i
+// is pinned, through the message or the replies to it.
+func (m Model) turnPinned(i int) bool {
+	for j := i; j < len(m.messages); j++ {
+		if j > i && m.messages[j].role == roleUser {
+			break
+		}
+		if m.messages[j].pinned {
+			return true
+		}
+	}
+	return false
+}
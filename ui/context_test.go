@@ -0,0 +1,73 @@
+package ui
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/bnema/cclui/api"
+)
+
+func TestContextLeavesOutDisplayOnlyRoles(t *testing.T) {
+	shown := []string{roleInfo, rolePanel, roleBanner, roleOpener, roleError}
+	m := newTestModel(t)
+	for _, role := range shown {
+		m.appendMessage(role, "shown only: "+role+" before")
+	}
+	m.appendMessage(roleUser, "hi")
+	for _, role := range shown {
+		m.appendMessage(role, "shown only: "+role+" between")
+	}
+	m.appendMessage(roleAssistant, "hello")
+	for _, role := range shown {
+		m.appendMessage(role, "shown only: "+role+" after")
+	}
+	m.appendMessage(roleUser, "how are you?")
+	m.appendMessage(roleAssistant, "fine")
+
+	context := m.contextMessages()
+	var got []string
+	for _, msg := range context {
+		got = append(got, msg.Role+": "+msg.Content)
+	}
+	want := []string{"user: hi", "assistant: hello", "user: how are you?", "assistant: fine"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("context =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	data, err := json.Marshal(context)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "shown only") {
+		t.Errorf("request carries a display-only message: %s", data)
+	}
+}
+
+func TestToolOnlyReplyStaysInTheContext(t *testing.T) {
+	m := newTestModel(t)
+	m.params.Tools = []api.Tool{{Name: "search"}}
+	m.appendMessage(roleUser, "hi")
+	m.appendMessage(roleAssistant, "")
+	m.messages[len(m.messages)-1].blocks = []api.ContentBlock{
+		{Type: "tool_use", ID: "toolu_1", Name: "search", Input: json.RawMessage(`{"query":"owls"}`)},
+	}
+	m.appendMessage(roleUser, "next")
+
+	context := m.contextMessages()
+	if len(context) != 3 {
+		t.Fatalf("context = %+v, want the prompt, the tool call and the next prompt", context)
+	}
+	if call := context[1]; call.Role != roleAssistant || len(call.Blocks) != 1 || call.Blocks[0].Type != "tool_use" || call.Blocks[0].ID != "toolu_1" {
+		t.Errorf("reply = %+v, want its tool_use", call)
+	}
+	next := context[2]
+	if len(next.Blocks) != 2 {
+		t.Fatalf("next prompt = %+v, want a tool_result and its text", next)
+	}
+	if result := next.Blocks[0]; result.Type != "tool_result" || result.ToolUseID != "toolu_1" || !result.IsError {
+		t.Errorf("tool result = %+v, want an is_error answer to toolu_1", result)
+	}
+	if text := next.Blocks[1]; text.Type != "text" || text.Text != "next" {
+		t.Errorf("text = %+v, want the next prompt", text)
+	}
+}
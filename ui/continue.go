@@ -0,0 +1,43 @@
+package ui
+
+import tea "github.com/charmbracelet/bubbletea"
+
+// continueNudge is the message /continue sends after a cut-off reply.
+const continueNudge = "Your last response was cut off. Continue exactly where it stopped, without repeating anything or adding an introduction."
+
+// continueReply handles /continue, which asks Claude to carry on with its
+// last reply, typically one that hit max_tokens. The reply stays in the
+// context as it is, followed by a short nudge.
+func (m Model) continueReply() (tea.Model, tea.Cmd) {
+	if m.streaming {
+		m.appendMessage(roleError, "wait for the response to finish before continuing it")
+		return m, nil
+	}
+	if m.lastReply() == "" || m.lastTurnRole() != roleAssistant {
+		m.appendMessage(roleError, "no response to continue")
+		return m, nil
+	}
+	m.appendMessage(roleUser, continueNudge)
+	return m, m.CallClaude()
+}
+
+// lastTurnRole returns whether the user or the assistant spoke last,
+// skipping notes and errors.
+func (m Model) lastTurnRole() string {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if role := m.messages[i].role; conversational(role) {
+			return role
+		}
+	}
+	return ""
+}
+
+// lastStopReason returns the stop reason of the latest assistant message.
+func (m Model) lastStopReason() string {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].role == roleAssistant {
+			return m.messages[i].stopReason
+		}
+	}
+	return ""
+}
@@ -0,0 +1,87 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/atotto/clipboard"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// copiedMsg reports the outcome of /copy once the clipboard has the text.
+type copiedMsg struct {
+	what  string
+	chars int
+	err   error
+}
+
+// copyText handles /copy [all] [markdown|text]: the last reply, or with all
+// the whole conversation, goes to the clipboard in the given format,
+// copy_format by default.
+func (m Model) copyText(args []string) (tea.Cmd, error) {
+	all, format := false, m.config.CopyFormat
+	for _, arg := range args {
+		switch arg {
+		case "all":
+			all = true
+		case "markdown", "text":
+			format = arg
+		default:
+			return nil, fmt.Errorf("usage: /copy [all] [markdown|text]")
+		}
+	}
+
+	if !all {
+		reply := m.lastReply()
+		if reply == "" {
+			return nil, fmt.Errorf("no response to copy yet")
+		}
+		return m.recovering(writeClipboard("the last reply", func() string { return reply }), commandFailed), nil
+	}
+
+	// Only the messages are copied here; rendering a long transcript and
+	// handing it to the clipboard tool happen off the UI goroutine.
+	msgs := make([]message, 0, len(m.messages))
+	for _, msg := range m.messages {
+		if conversational(msg.role) {
+			msgs = append(msgs, msg)
+		}
+	}
+	if len(msgs) == 0 {
+		return nil, fmt.Errorf("nothing to copy yet")
+	}
+	labels := map[string]string{roleUser: "User", roleAssistant: "Assistant"}
+	for role := range labels {
+		if l := m.roles[role].label; l != "" {
+			labels[role] = l
+		}
+	}
+	return m.recovering(writeClipboard("the conversation", func() string {
+		return transcript(msgs, labels, format)
+	}), commandFailed), nil
+}
+
+// transcript renders msgs for pasting elsewhere. In Markdown each message
+// is a section headed by its role; in text it is prefixed with the role.
+func transcript(msgs []message, labels map[string]string, format string) string {
+	var b strings.Builder
+	for i, msg := range msgs {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		if format == "markdown" {
+			fmt.Fprintf(&b, "## %s\n\n%s", labels[msg.role], msg.content)
+		} else {
+			fmt.Fprintf(&b, "%s: %s", labels[msg.role], msg.content)
+		}
+	}
+	return b.String() + "\n"
+}
+
+func writeClipboard(what string, text func() string) tea.Cmd {
+	return func() tea.Msg {
+		s := text()
+		return copiedMsg{what: what, chars: utf8.RuneCountInString(s), err: clipboard.WriteAll(s)}
+	}
+}
@@ -0,0 +1,93 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bnema/cclui/api"
+	"github.com/bnema/cclui/config"
+)
+
+// price returns what model costs per million tokens: the configured price
+// if there is one, the built-in one otherwise. ok is false when neither
+// knows the model.
+func (m Model) price(model string) (p config.Price, ok bool) {
+	if p, ok := m.config.Prices[model]; ok {
+		return p, true
+	}
+	if info, ok := api.LookupModel(model); ok && info.InputPrice > 0 {
+		return config.Price{Input: info.InputPrice, Output: info.OutputPrice}, true
+	}
+	return config.Price{}, false
+}
+
+// costOf is the cost of u at price p, in dollars.
+func costOf(p config.Price, u api.Usage) float64 {
+	return (float64(u.InputTokens)*p.Input + float64(u.OutputTokens)*p.Output) / 1e6
+}
+
+// addSpent counts tokens used by model. Unlike usage, which sums the
+// transcript, this keeps counting replies that were retried or stopped,
+// since they were paid for all the same.
+func (m *Model) addSpent(model string, u api.Usage) {
+	total := m.spent[model]
+	total.InputTokens += u.InputTokens
+	total.OutputTokens += u.OutputTokens
+	m.spent[model] = total
+}
+
+// sessionCost is the estimated cost of the session so far, in dollars, over
+// the models whose price is known.
+func (m Model) sessionCost() float64 {
+	var total float64
+	for model, u := range m.spent {
+		if p, ok := m.price(model); ok {
+			total += costOf(p, u)
+		}
+	}
+	return total
+}
+
+// costReport breaks the session cost down by model.
+func (m Model) costReport() string {
+	if len(m.spent) == 0 {
+		return "No tokens used yet."
+	}
+
+	models := make([]string, 0, len(m.spent))
+	for model := range m.spent {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+
+	var b strings.Builder
+	for _, model := range models {
+		u := m.spent[model]
+		fmt.Fprintf(&b, "%s: %d in / %d out", model, u.InputTokens, u.OutputTokens)
+		if p, ok := m.price(model); ok {
+			fmt.Fprintf(&b, ", $%.4f\n", costOf(p, u))
+		} else {
+			b.WriteString(", price unknown (set it with prices)\n")
+		}
+	}
+	fmt.Fprintf(&b, "Total: $%.4f (estimate)", m.sessionCost())
+	return b.String()
+}
+
+// ExitSummary is the line printed on exit when exit_summary is on, or ""
+// when it is off: the turns of the conversation, the tokens spent over the
+// session, their estimated cost and how long the session lasted.
+func (m Model) ExitSummary() string {
+	if !m.config.ExitSummary {
+		return ""
+	}
+	var in, out int
+	for _, u := range m.spent {
+		in += u.InputTokens
+		out += u.OutputTokens
+	}
+	return fmt.Sprintf("cclui: %d turns, %d in / %d out tokens, $%.4f (estimate), %s",
+		turns(m.messages), in, out, m.sessionCost(), time.Since(m.startedAt).Round(time.Second))
+}
@@ -0,0 +1,158 @@
+package ui
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// formatInstructions are added to the system prompt by /format.
+var formatInstructions = map[string]string{
+	"json":     "Respond with valid JSON only, without any text or code fences around it.",
+	"markdown": "Format your responses as Markdown.",
+	"plain":    "Respond in plain text, without any Markdown formatting.",
+}
+
+// verbosityInstructions are added to the system prompt by /verbosity, for
+// the levels the config file doesn't give instructions of its own.
+var verbosityInstructions = map[string]string{
+	"brief":    "Keep your responses brief: get to the point in a few sentences, without preamble, caveats or a closing summary, unless asked for more.",
+	"detailed": "Give thorough, detailed responses: explain your reasoning, cover edge cases and alternatives, and include examples where they help.",
+}
+
+// systemPrompt is the system prompt sent with requests: the configured one,
+// its variables filled in, followed by the instructions of /lang, /format,
+// /verbosity and /json, if set.
+func (m Model) systemPrompt() string {
+	parts := []string{}
+	if m.params.System != "" {
+		parts = append(parts, ExpandSystem(m.config, m.params.System))
+	}
+	if m.config.OpenerContext && m.config.Opener != "" {
+		parts = append(parts, "You opened the conversation by saying:\n\n"+m.config.Opener)
+	}
+	if m.lang != "" {
+		parts = append(parts, fmt.Sprintf("Always respond in the language with code %q, whatever language the user writes in.", m.lang))
+	}
+	if m.format != "" {
+		parts = append(parts, formatInstructions[m.format])
+	}
+	if m.verbosity != "" {
+		parts = append(parts, m.verbosityLevel(m.verbosity))
+	}
+	if s := m.structuredInstructions(); s != "" {
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// setLang handles /lang [code|off]. Without an argument it shows the
+// current setting.
+func (m Model) setLang(args []string) (Model, error) {
+	switch {
+	case len(args) == 0:
+		if m.lang == "" {
+			m.appendMessage(roleInfo, "No response language is enforced.")
+		} else {
+			m.appendMessage(roleInfo, "Responses are in "+m.lang+".")
+		}
+	case len(args) > 1:
+		return m, fmt.Errorf("usage: /lang [code|off]")
+	case args[0] == "off":
+		m.lang = ""
+		m.appendMessage(roleInfo, "Response language no longer enforced.")
+	default:
+		m.lang = args[0]
+		m.appendMessage(roleInfo, "Responses will be in "+m.lang+".")
+	}
+	return m, nil
+}
+
+// setFormat handles /format [json|markdown|plain|off].
+func (m Model) setFormat(args []string) (Model, error) {
+	switch {
+	case len(args) == 0:
+		if m.format == "" {
+			m.appendMessage(roleInfo, "No response format is enforced.")
+		} else {
+			m.appendMessage(roleInfo, "Responses are formatted as "+m.format+".")
+		}
+	case len(args) > 1:
+		return m, fmt.Errorf("usage: /format [json|markdown|plain|off]")
+	case args[0] == "off":
+		m.format = ""
+		m.appendMessage(roleInfo, "Response format no longer enforced.")
+	case formatInstructions[args[0]] != "":
+		m.format = args[0]
+		m.appendMessage(roleInfo, "Responses will be formatted as "+m.format+".")
+	default:
+		return m, fmt.Errorf("unknown format %q, want json, markdown, plain or off", args[0])
+	}
+	return m, nil
+}
+
+// verbosityLevel returns the instructions of the /verbosity level, from
+// the config file or built in, or "" for a level there are none of.
+func (m Model) verbosityLevel(level string) string {
+	if text, ok := m.config.Verbosity[level]; ok {
+		return text
+	}
+	return verbosityInstructions[level]
+}
+
+// verbosityLevels lists the levels of /verbosity, sorted, normal first.
+func (m Model) verbosityLevels() []string {
+	var levels []string
+	for level := range verbosityInstructions {
+		levels = append(levels, level)
+	}
+	for level := range m.config.Verbosity {
+		if _, ok := verbosityInstructions[level]; !ok {
+			levels = append(levels, level)
+		}
+	}
+	sort.Strings(levels)
+	return append([]string{"normal"}, levels...)
+}
+
+// setVerbosity handles /verbosity [level|normal]. normal drops the
+// instructions of the others.
+func (m Model) setVerbosity(args []string) (Model, error) {
+	switch {
+	case len(args) == 0:
+		level := m.verbosity
+		if level == "" {
+			level = "normal"
+		}
+		m.appendMessage(roleInfo, fmt.Sprintf("Verbosity: %s (levels: %s).", level, strings.Join(m.verbosityLevels(), ", ")))
+	case args[0] == "normal":
+		m.verbosity = ""
+		m.appendMessage(roleInfo, "Verbosity back to normal.")
+	case m.verbosityLevel(args[0]) != "":
+		m.verbosity = args[0]
+		m.appendMessage(roleInfo, "Verbosity set to "+m.verbosity+".")
+	default:
+		return m, fmt.Errorf("unknown verbosity %q, want one of %s", args[0], strings.Join(m.verbosityLevels(), ", "))
+	}
+	return m, nil
+}
+
+// enforcement sums up /lang, /format, /verbosity and /json for the status
+// line.
+func (m Model) enforcement() string {
+	var parts []string
+	if m.lang != "" {
+		parts = append(parts, "lang:"+m.lang)
+	}
+	if m.format != "" {
+		parts = append(parts, "format:"+m.format)
+	}
+	if m.verbosity != "" {
+		parts = append(parts, "verbosity:"+m.verbosity)
+	}
+	if m.structured != nil {
+		parts = append(parts, "json:"+filepath.Base(m.structured.path))
+	}
+	return strings.Join(parts, " ")
+}
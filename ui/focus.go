@@ -0,0 +1,65 @@
+package ui
+
+import tea "github.com/charmbracelet/bubbletea"
+
+// toggleFocus moves the keyboard focus between the input and the
+// transcript. The selection only lasts while the transcript has it.
+func (m Model) toggleFocus() Model {
+	if m.selectedMessage() >= 0 {
+		m = m.clearSelection()
+	}
+	m.selected = -1
+	m.scrolling = !m.scrolling
+	if m.scrolling {
+		m.textarea.Blur()
+	} else {
+		m.textarea.Focus()
+	}
+	return m
+}
+
+// clearSelection drops the selection, leaving the transcript where it is.
+func (m Model) clearSelection() Model {
+	m.selected = -1
+	m.activeCode = 0
+	m.setContent(m.renderMessages())
+	return m
+}
+
+// scrollKey handles a key while the transcript has the focus: none reach
+// the input. The arrow keys and j/k select messages, the keys of
+// selectionKey act on the selection and the others scroll. Without a
+// selection, r retries a failed turn, giving the focus back to the input,
+// and ? shows the help. Esc closes a view such as the help, drops the
+// selection, then gives the focus back, as Tab does at once.
+func (m Model) scrollKey(key tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if model, cmd, ok := m.selectionKey(key); ok {
+		return model, cmd
+	}
+	if key.String() == "r" && m.canRetry() {
+		return m.toggleFocus().retryFailed()
+	}
+	if key.String() == "?" && !m.streaming {
+		m.showOverlay(helpText())
+		return m, nil
+	}
+	switch key.Type {
+	case tea.KeyEsc:
+		if m.overlay {
+			m.overlay = false
+			m.refresh()
+			return m, nil
+		}
+		if m.selectedMessage() >= 0 {
+			return m.clearSelection(), nil
+		}
+		return m.toggleFocus(), nil
+	case tea.KeyTab:
+		return m.toggleFocus(), nil
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+	}
+	var cmd tea.Cmd
+	m.viewport, cmd = m.viewport.Update(key)
+	return m, cmd
+}
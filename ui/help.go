@@ -0,0 +1,52 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// shellHelp lists the shell lines for /help, after the commands.
+var shellHelp = [][2]string{
+	{"!<command>", "run a shell command, with allow_shell"},
+	{"!!<command>", "run it and send its output with the next message"},
+}
+
+// keyHelp lists the keys for /help.
+var keyHelp = [][2]string{
+	{"Enter", "send the message"},
+	{"Esc", "stop the reply, close a view or clear the input"},
+	{"Tab", "complete a command after /, or move the focus to the transcript"},
+	{"↑/↓, j/k", "in the transcript, select a message"},
+	{"y, p, b, r", "copy, pin, branch before or regenerate the selected message"},
+	{"d, D", "delete the selected message or its whole turn"},
+	{"e", "expand or collapse the selected long response"},
+	{"c, C", "step through the code blocks of the selected reply, for y to copy one"},
+	{"Ctrl+O", "pick a model"},
+	{"Ctrl+T", "collapse or expand thinking"},
+	{"Ctrl+R", "show replies as raw text or rendered Markdown"},
+	{"r", "in the transcript, retry a failed request, with the input empty"},
+	{"?", "in the transcript, show this help"},
+	{"Ctrl+C", "quit"},
+}
+
+// helpText is the text of /help.
+func helpText() string {
+	var b strings.Builder
+	b.WriteString("Commands\n\n")
+	writeHelp(&b, append(commandHelp(), shellHelp...))
+	b.WriteString("\nKeys\n\n")
+	writeHelp(&b, keyHelp)
+	b.WriteString("\n(Esc to close)")
+	return b.String()
+}
+
+func writeHelp(b *strings.Builder, entries [][2]string) {
+	width := 0
+	for _, e := range entries {
+		width = max(width, utf8.RuneCountInString(e[0]))
+	}
+	for _, e := range entries {
+		fmt.Fprintf(b, "  %-*s  %s\n", width, e[0], e[1])
+	}
+}
@@ -0,0 +1,335 @@
+package ui
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// htmlStyle is the style sheet of /export, inlined so that the page stands
+// on its own. It follows the reader's light or dark preference.
+const htmlStyle = `
+:root { --fg: #1f2328; --bg: #ffffff; --muted: #656d76; --border: #d0d7de; --code-bg: #f6f8fa;
+  --user: #8250df; --assistant: #0969da; --keyword: #cf222e; --string: #0a3069; --comment: #6e7781; --number: #0550ae; }
+@media (prefers-color-scheme: dark) {
+  :root { --fg: #e6edf3; --bg: #0d1117; --muted: #8d96a0; --border: #30363d; --code-bg: #161b22;
+    --user: #d2a8ff; --assistant: #79c0ff; --keyword: #ff7b72; --string: #a5d6ff; --comment: #8b949e; --number: #79c0ff; }
+}
+body { margin: 0; background: var(--bg); color: var(--fg); font: 16px/1.6 system-ui, -apple-system, "Segoe UI", sans-serif; }
+main { max-width: 50rem; margin: 0 auto; padding: 2rem 1rem; }
+h1 { font-size: 1.4rem; margin: 0; }
+.meta { color: var(--muted); font-size: .9rem; margin: .25rem 0 2rem; }
+section { border-top: 1px solid var(--border); padding: 1rem 0; }
+section header { display: flex; justify-content: space-between; align-items: baseline; }
+.role { font-weight: 600; }
+.user .role { color: var(--user); }
+.assistant .role { color: var(--assistant); }
+time, .note { color: var(--muted); font-size: .85rem; }
+pre { background: var(--code-bg); border: 1px solid var(--border); border-radius: 6px; padding: .75rem 1rem; overflow-x: auto; }
+code { font: .9em/1.5 ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; }
+:not(pre) > code { background: var(--code-bg); border-radius: 4px; padding: .1em .3em; }
+pre[data-lang]::before { content: attr(data-lang); display: block; color: var(--muted); font-size: .75rem; margin-bottom: .25rem; }
+blockquote { margin: 0; padding-left: 1rem; border-left: 3px solid var(--border); color: var(--muted); }
+table { border-collapse: collapse; }
+th, td { border: 1px solid var(--border); padding: .3rem .6rem; }
+img { max-width: 100%; }
+details { color: var(--muted); margin-bottom: .5rem; }
+.kw { color: var(--keyword); } .str { color: var(--string); } .com { color: var(--comment); font-style: italic; } .num { color: var(--number); }
+`
+
+// exportHTML handles /export <file>: the conversation goes to a standalone
+// HTML page, Markdown rendered, code highlighted and the style sheet
+// inlined, to share with those who don't read it in a terminal. A file name
+// without an extension gets .html, and an existing file is never
+// overwritten.
+func (m Model) exportHTML(args []string) (tea.Cmd, error) {
+	path := args[0]
+	if filepath.Ext(path) == "" {
+		path += ".html"
+	}
+	msgs := make([]message, 0, len(m.messages))
+	for _, msg := range m.messages {
+		if conversational(msg.role) {
+			msgs = append(msgs, msg)
+		}
+	}
+	if len(msgs) == 0 {
+		return nil, fmt.Errorf("nothing to export yet")
+	}
+	labels := map[string]string{roleUser: "User", roleAssistant: "Assistant"}
+	for role := range labels {
+		if l := m.roles[role].label; l != "" {
+			labels[role] = l
+		}
+	}
+	model := m.params.Model
+	// As with /copy all, the page is rendered off the UI goroutine.
+	return m.recovering(func() tea.Msg {
+		page := htmlPage(msgs, labels, model, time.Now())
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return commandFailed(err)
+		}
+		if _, err := f.WriteString(page); err != nil {
+			f.Close()
+			return commandFailed(err)
+		}
+		if err := f.Close(); err != nil {
+			return commandFailed(err)
+		}
+		return commandOutputMsg(fmt.Sprintf("Exported %d messages to %s.", len(msgs), path))
+	}, commandFailed), nil
+}
+
+// htmlPage renders msgs as a standalone HTML page, each message a section
+// headed by its role and the time it was added.
+func htmlPage(msgs []message, labels map[string]string, model string, now time.Time) string {
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	b.WriteString("<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n")
+	fmt.Fprintf(&b, "<title>%s</title>\n<style>%s</style>\n</head>\n<body>\n<main>\n", html.EscapeString(pageTitle(msgs)), htmlStyle)
+	fmt.Fprintf(&b, "<h1>%s</h1>\n", html.EscapeString(pageTitle(msgs)))
+	fmt.Fprintf(&b, "<p class=\"meta\">%s, exported from cclui on %s</p>\n", html.EscapeString(model), now.Format("2006-01-02 15:04"))
+	for _, msg := range msgs {
+		fmt.Fprintf(&b, "<section class=\"%s\">\n<header><span class=\"role\">%s</span>", msg.role, html.EscapeString(labels[msg.role]))
+		if !msg.at.IsZero() {
+			fmt.Fprintf(&b, "<time datetime=\"%s\">%s</time>", msg.at.Format(time.RFC3339), msg.at.Format("2006-01-02 15:04"))
+		}
+		b.WriteString("</header>\n")
+		if msg.thinking != "" {
+			fmt.Fprintf(&b, "<details><summary>Thinking</summary>\n%s</details>\n", markdownHTML(sanitize(msg.thinking)))
+		}
+		if blank(msg.content) {
+			fmt.Fprintf(&b, "<p class=\"note\">%s</p>\n", noText)
+		} else {
+			b.WriteString(markdownHTML(sanitize(msg.content)))
+		}
+		for _, tool := range msg.tools {
+			fmt.Fprintf(&b, "<p class=\"note\">Tool call: %s</p>\n<pre><code>%s</code></pre>\n", html.EscapeString(tool.Name), html.EscapeString(string(tool.Input)))
+		}
+		b.WriteString("</section>\n")
+	}
+	b.WriteString("</main>\n</body>\n</html>\n")
+	return b.String()
+}
+
+// pageTitle is the title of an exported page: the first prompt, shortened.
+func pageTitle(msgs []message) string {
+	for _, msg := range msgs {
+		if msg.role == roleUser && !blank(msg.content) {
+			return truncate(strings.Join(strings.Fields(msg.content), " "), 60)
+		}
+	}
+	return "Conversation"
+}
+
+var (
+	headingLine  = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	ruleLine     = regexp.MustCompile(`^\s*([-*_])(\s*([-*_])){2,}\s*$`)
+	listItemLine = regexp.MustCompile(`^\s*([-*+]|\d+[.)])\s+(.*)$`)
+	quoteLine    = regexp.MustCompile(`^\s*>\s?(.*)$`)
+)
+
+// markdownHTML renders the Markdown text as HTML: fenced code blocks,
+// headings, lists, block quotes, tables, rules and paragraphs, with the
+// inline markup of inlineHTML. It covers what Claude writes rather than
+// all of CommonMark; lists are not nested.
+func markdownHTML(text string) string {
+	lines := strings.Split(text, "\n")
+	fences := findFences(lines)
+	var b strings.Builder
+	var para []string
+	flush := func() {
+		if len(para) > 0 {
+			fmt.Fprintf(&b, "<p>%s</p>\n", inlineHTML(strings.Join(para, "\n")))
+			para = nil
+		}
+	}
+
+	for i := 0; i < len(lines); {
+		if len(fences) > 0 && fences[0].open == i {
+			flush()
+			f := fences[0]
+			fences = fences[1:]
+			code := strings.Join(lines[f.open+1:min(f.close, len(lines))], "\n")
+			if f.lang != "" {
+				fmt.Fprintf(&b, "<pre data-lang=\"%s\"><code class=\"language-%[1]s\">%s</code></pre>\n", html.EscapeString(f.lang), highlight(code, f.lang))
+			} else {
+				fmt.Fprintf(&b, "<pre><code>%s</code></pre>\n", html.EscapeString(code))
+			}
+			i = f.close + 1
+			continue
+		}
+
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			flush()
+			i++
+		case headingLine.MatchString(line):
+			flush()
+			h := headingLine.FindStringSubmatch(line)
+			// The page title is the only h1.
+			fmt.Fprintf(&b, "<h%d>%s</h%[1]d>\n", min(len(h[1])+1, 6), inlineHTML(h[2]))
+			i++
+		case ruleLine.MatchString(line) && len(para) == 0:
+			b.WriteString("<hr>\n")
+			i++
+		case quoteLine.MatchString(line):
+			flush()
+			var quoted []string
+			for ; i < len(lines) && quoteLine.MatchString(lines[i]); i++ {
+				quoted = append(quoted, quoteLine.FindStringSubmatch(lines[i])[1])
+			}
+			fmt.Fprintf(&b, "<blockquote>\n%s</blockquote>\n", markdownHTML(strings.Join(quoted, "\n")))
+		case listItemLine.MatchString(line):
+			flush()
+			tag := listTag(line)
+			fmt.Fprintf(&b, "<%s>\n", tag)
+			for ; i < len(lines) && listItemLine.MatchString(lines[i]) && listTag(lines[i]) == tag; i++ {
+				item := listItemLine.FindStringSubmatch(lines[i])[2]
+				// Lines indented under an item carry it on.
+				for i+1 < len(lines) && strings.HasPrefix(lines[i+1], "  ") && strings.TrimSpace(lines[i+1]) != "" && !listItemLine.MatchString(lines[i+1]) {
+					i++
+					item += "\n" + strings.TrimSpace(lines[i])
+				}
+				fmt.Fprintf(&b, "<li>%s</li>\n", inlineHTML(item))
+			}
+			fmt.Fprintf(&b, "</%s>\n", tag)
+		case strings.Contains(line, "|") && i+1 < len(lines) && tableDelimiter.MatchString(lines[i+1]):
+			flush()
+			b.WriteString("<table>\n<tr>")
+			for _, cell := range tableCells(line) {
+				fmt.Fprintf(&b, "<th>%s</th>", inlineHTML(cell))
+			}
+			b.WriteString("</tr>\n")
+			for i += 2; i < len(lines) && strings.Contains(lines[i], "|") && strings.TrimSpace(lines[i]) != ""; i++ {
+				b.WriteString("<tr>")
+				for _, cell := range tableCells(lines[i]) {
+					fmt.Fprintf(&b, "<td>%s</td>", inlineHTML(cell))
+				}
+				b.WriteString("</tr>\n")
+			}
+			b.WriteString("</table>\n")
+		default:
+			para = append(para, line)
+			i++
+		}
+	}
+	flush()
+	return b.String()
+}
+
+// listTag returns the tag of the list the item line starts or goes on
+// with: ol for a numbered item, ul for the others.
+func listTag(line string) string {
+	if marker := listItemLine.FindStringSubmatch(line)[1]; marker[0] >= '0' && marker[0] <= '9' {
+		return "ol"
+	}
+	return "ul"
+}
+
+var (
+	codeSpan   = regexp.MustCompile("`+[^`]+`+")
+	linkRef    = regexp.MustCompile(`(!?)\[([^\]]*)\]\(\s*([^)\s]+)(?:\s+&#34;[^&]*&#34;)?\s*\)`)
+	strongSpan = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	emSpan     = regexp.MustCompile(`\*([^*\s][^*]*)\*`)
+	strikeSpan = regexp.MustCompile(`~~([^~]+)~~`)
+)
+
+// inlineHTML renders the inline Markdown of text, escaped: code spans,
+// images, links, bold, italics and strikethrough. Links and images only
+// keep http, https and mailto URLs, so that an exported page can't run
+// anything.
+func inlineHTML(text string) string {
+	// Code spans are set aside first, as nothing inside them is markup.
+	var spans []string
+	text = codeSpan.ReplaceAllStringFunc(text, func(s string) string {
+		spans = append(spans, "<code>"+html.EscapeString(strings.TrimSpace(strings.Trim(s, "`")))+"</code>")
+		return fmt.Sprintf("\x00%d\x00", len(spans)-1)
+	})
+	text = html.EscapeString(text)
+	text = linkRef.ReplaceAllStringFunc(text, func(s string) string {
+		g := linkRef.FindStringSubmatch(s)
+		url := html.UnescapeString(g[3])
+		if !safeURL(url) {
+			return g[2]
+		}
+		if g[1] == "!" {
+			return fmt.Sprintf(`<img src="%s" alt="%s">`, html.EscapeString(url), g[2])
+		}
+		return fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(url), g[2])
+	})
+	text = strongSpan.ReplaceAllString(text, "<strong>$1$2</strong>")
+	text = emSpan.ReplaceAllString(text, "<em>$1</em>")
+	text = strikeSpan.ReplaceAllString(text, "<del>$1</del>")
+	for i, span := range spans {
+		text = strings.Replace(text, fmt.Sprintf("\x00%d\x00", i), span, 1)
+	}
+	return text
+}
+
+// safeURL reports whether url can be linked to from an exported page.
+func safeURL(url string) bool {
+	lower := strings.ToLower(url)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "mailto:")
+}
+
+// hashComments are the languages whose comments start with #, and
+// dashComments those whose comments start with --; the others are taken to
+// use // and /* */.
+var (
+	hashComments = map[string]bool{
+		"python": true, "py": true, "bash": true, "sh": true, "shell": true, "zsh": true, "ruby": true, "rb": true,
+		"perl": true, "yaml": true, "yml": true, "toml": true, "r": true, "makefile": true, "dockerfile": true,
+		"powershell": true, "ps1": true, "elixir": true, "nim": true,
+	}
+	dashComments = map[string]bool{"sql": true, "lua": true, "haskell": true, "hs": true}
+	plainLangs   = map[string]bool{"text": true, "txt": true, "plain": true, "markdown": true, "md": true, "diff": true, "output": true, "console": true}
+)
+
+// codeKeywords are highlighted in code whatever its language: the keywords
+// common to the languages Claude writes most.
+var codeKeywords = `\b(?:func|function|def|fn|return|if|else|elif|for|foreach|while|do|switch|case|default|break|continue|` +
+	`var|let|const|type|struct|interface|class|enum|import|from|package|export|module|use|mod|pub|impl|trait|` +
+	`go|defer|select|chan|map|range|nil|null|None|true|false|True|False|try|catch|except|finally|raise|throw|` +
+	`new|public|private|protected|static|void|async|await|yield|lambda|match|self|this|then|fi|done|esac|in|not|and|or)\b`
+
+// highlight escapes code and marks up its keywords, strings, numbers and
+// comments, going by a few rules shared across languages rather than a
+// full grammar for each.
+func highlight(code, lang string) string {
+	if plainLangs[lang] {
+		return html.EscapeString(code)
+	}
+	comment := `//[^\n]*|/\*[\s\S]*?\*/`
+	switch {
+	case hashComments[lang]:
+		comment = `#[^\n]*`
+	case dashComments[lang]:
+		comment = `--[^\n]*`
+	}
+	tokens := regexp.MustCompile(`(?P<com>` + comment + `)|(?P<str>"(?:[^"\\\n]|\\.)*"|'(?:[^'\\\n]|\\.)*'|` + "`[^`]*`" + `)|(?P<num>\b\d+(?:\.\d+)?\b)|(?P<kw>` + codeKeywords + `)`)
+	names := tokens.SubexpNames()
+
+	var b strings.Builder
+	last := 0
+	for _, match := range tokens.FindAllStringSubmatchIndex(code, -1) {
+		b.WriteString(html.EscapeString(code[last:match[0]]))
+		for g := 1; g < len(names); g++ {
+			if match[2*g] >= 0 {
+				fmt.Fprintf(&b, `<span class="%s">%s</span>`, names[g], html.EscapeString(code[match[0]:match[1]]))
+				break
+			}
+		}
+		last = match[1]
+	}
+	b.WriteString(html.EscapeString(code[last:]))
+	return b.String()
+}
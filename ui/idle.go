@@ -0,0 +1,38 @@
+package ui
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// idleTickMsg fires idle_disconnect after the request that scheduled it,
+// unless a later one replaced it.
+type idleTickMsg struct{ id int }
+
+// idleAfter schedules the end of the connections to the API for when the
+// session will have been idle for idle_disconnect, replacing any earlier
+// schedule.
+func (m *Model) idleAfter() tea.Cmd {
+	m.idleID++
+	if m.config.IdleDisconnect <= 0 {
+		return nil
+	}
+	id := m.idleID
+	return tea.Tick(m.config.IdleDisconnect, func(time.Time) tea.Msg {
+		return idleTickMsg{id: id}
+	})
+}
+
+// disconnect closes the idle connections to the API, if the client keeps
+// any. The next request connects again.
+func (m Model) disconnect(msg idleTickMsg) Model {
+	if msg.id != m.idleID || m.streaming {
+		return m
+	}
+	if c, ok := m.client.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+		m.idle = true
+	}
+	return m
+}
@@ -0,0 +1,261 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// importedConversation is a conversation read from another tool's export.
+type importedConversation struct {
+	title    string
+	system   string
+	messages []message
+	// skipped counts messages that could not be mapped to a turn, such as
+	// tool output or images.
+	skipped int
+}
+
+// chatgptConversation is one conversation of a ChatGPT export
+// (conversations.json). Messages form a tree in mapping; the thread shown
+// to the user runs from current_node up through the parents.
+type chatgptConversation struct {
+	Title       string                 `json:"title"`
+	CurrentNode string                 `json:"current_node"`
+	Mapping     map[string]chatgptNode `json:"mapping"`
+}
+
+type chatgptNode struct {
+	Parent  string `json:"parent"`
+	Message *struct {
+		Author struct {
+			Role string `json:"role"`
+		} `json:"author"`
+		Content struct {
+			ContentType string            `json:"content_type"`
+			Parts       []json.RawMessage `json:"parts"`
+			Text        string            `json:"text"`
+		} `json:"content"`
+	} `json:"message"`
+}
+
+// openaiMessage is a message in the OpenAI API format, whose content is
+// either a string or a list of parts.
+type openaiMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// importConversation reads an OpenAI or ChatGPT export. An export holding
+// several conversations yields the one picked by number or title, the first
+// one by default.
+func importConversation(path, pick string) (importedConversation, int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return importedConversation{}, 0, err
+	}
+
+	var probe any
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return importedConversation{}, 0, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	var (
+		c     importedConversation
+		count = 1
+	)
+	switch v := probe.(type) {
+	case map[string]any:
+		switch {
+		case v["mapping"] != nil:
+			var conv chatgptConversation
+			err = json.Unmarshal(data, &conv)
+			c = fromChatGPT(conv)
+		case v["messages"] != nil:
+			var export struct {
+				Title    string          `json:"title"`
+				Messages []openaiMessage `json:"messages"`
+			}
+			err = json.Unmarshal(data, &export)
+			c = fromOpenAI(export.Messages)
+			c.title = export.Title
+		default:
+			err = fmt.Errorf("no messages found")
+		}
+	case []any:
+		if len(v) == 0 {
+			return c, 0, fmt.Errorf("%s: the export is empty", path)
+		}
+		if first, ok := v[0].(map[string]any); ok && first["mapping"] != nil {
+			var convs []chatgptConversation
+			if err := json.Unmarshal(data, &convs); err != nil {
+				return c, 0, fmt.Errorf("reading %s: %w", path, err)
+			}
+			i, err := pickConversation(convs, pick)
+			if err != nil {
+				return c, 0, err
+			}
+			c, count = fromChatGPT(convs[i]), len(convs)
+		} else {
+			var msgs []openaiMessage
+			err = json.Unmarshal(data, &msgs)
+			c = fromOpenAI(msgs)
+		}
+	default:
+		err = fmt.Errorf("unrecognized format")
+	}
+	if err != nil {
+		return c, 0, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if len(c.messages) == 0 {
+		return c, 0, fmt.Errorf("%s: no user or assistant messages to import", path)
+	}
+	return c, count, nil
+}
+
+func pickConversation(convs []chatgptConversation, pick string) (int, error) {
+	if pick == "" {
+		return 0, nil
+	}
+	if n, err := strconv.Atoi(pick); err == nil {
+		if n < 1 || n > len(convs) {
+			return 0, fmt.Errorf("the export has %d conversations, no #%d", len(convs), n)
+		}
+		return n - 1, nil
+	}
+	for i, c := range convs {
+		if strings.EqualFold(c.Title, pick) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("no conversation titled %q in the export", pick)
+}
+
+func fromChatGPT(conv chatgptConversation) importedConversation {
+	c := importedConversation{title: conv.Title}
+
+	// Walk up from the current node, guarding against cycles, then put the
+	// thread back in order.
+	var thread []chatgptNode
+	seen := map[string]bool{}
+	for id := conv.CurrentNode; id != "" && !seen[id]; id = conv.Mapping[id].Parent {
+		seen[id] = true
+		thread = append(thread, conv.Mapping[id])
+	}
+	for i := len(thread) - 1; i >= 0; i-- {
+		msg := thread[i].Message
+		if msg == nil {
+			continue
+		}
+		text := msg.Content.Text
+		for _, part := range msg.Content.Parts {
+			if t := partText(part); t != "" {
+				text = joinText(text, t)
+			}
+		}
+		c.add(msg.Author.Role, text)
+	}
+	return c
+}
+
+func fromOpenAI(msgs []openaiMessage) importedConversation {
+	var c importedConversation
+	for _, msg := range msgs {
+		text := partText(msg.Content)
+		if text == "" {
+			var parts []json.RawMessage
+			if json.Unmarshal(msg.Content, &parts) == nil {
+				for _, part := range parts {
+					text = joinText(text, partText(part))
+				}
+			}
+		}
+		c.add(msg.Role, text)
+	}
+	return c
+}
+
+// partText returns the text of a content part, which is either a string or
+// an object with a text field; anything else, like an image, has none.
+func partText(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var obj struct {
+		Text string `json:"text"`
+	}
+	if json.Unmarshal(raw, &obj) == nil {
+		return obj.Text
+	}
+	return ""
+}
+
+func joinText(a, b string) string {
+	if a == "" || b == "" {
+		return a + b
+	}
+	return a + "\n\n" + b
+}
+
+// add maps a message to the transcript. System messages become the system
+// prompt; consecutive messages of the same role are merged so roles keep
+// alternating, and the thread has to start with the user.
+func (c *importedConversation) add(role, text string) {
+	text = strings.TrimSpace(text)
+	switch {
+	case text == "":
+		return
+	case role == "system" || role == "developer":
+		c.system = joinText(c.system, text)
+		return
+	case !conversational(role):
+		c.skipped++
+		return
+	case len(c.messages) == 0 && role == roleAssistant:
+		c.skipped++
+		return
+	}
+
+	if n := len(c.messages); n > 0 && c.messages[n-1].role == role {
+		c.messages[n-1].content = joinText(c.messages[n-1].content, text)
+		return
+	}
+	c.messages = append(c.messages, message{role: role, content: text})
+}
+
+// Import replaces the conversation with one imported from an OpenAI or
+// ChatGPT export, so it can be continued with Claude. pick selects a
+// conversation by number or title when the export holds several.
+func (m Model) Import(path, pick string) (Model, error) {
+	if m.streaming {
+		return m, fmt.Errorf("wait for the response to finish before importing")
+	}
+	c, count, err := importConversation(path, pick)
+	if err != nil {
+		return m, err
+	}
+
+	m.branches = []branch{{name: "main", parent: -1}}
+	m.branch = 0
+	m.messages = c.messages
+	m.resetArchive()
+	if c.system != "" {
+		m.params.System = c.system
+	}
+
+	note := fmt.Sprintf("Imported %d messages", len(c.messages))
+	if c.title != "" {
+		note += fmt.Sprintf(" from %q", c.title)
+	}
+	if c.skipped > 0 {
+		note += fmt.Sprintf(", skipped %d that have no Claude equivalent", c.skipped)
+	}
+	if count > 1 {
+		note += fmt.Sprintf(". The export holds %d conversations; pick another with /import <file> <number or title>", count)
+	}
+	m.appendMessage(roleInfo, note+".")
+	return m, nil
+}
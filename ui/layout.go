@@ -0,0 +1,76 @@
+package ui
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// chromeHeight is the number of lines View adds around the viewport and
+// the input: the separator and the status line. One more is kept free for
+// View's final newline.
+const chromeHeight = 2
+
+// resize fits the layout to the terminal, rewrapping the transcript and a
+// reply being streamed into it. A transcript scrolled up from its end keeps
+// the message at its top in view, wherever rewrapping moves it.
+func (m Model) resize(msg tea.WindowSizeMsg) Model {
+	anchor := -1
+	if !m.overlay && m.scrolling && m.selectedMessage() < 0 && !m.viewport.AtBottom() {
+		anchor = m.topMessage()
+	}
+
+	m.viewport.Width = msg.Width
+	m.viewport.Height = max(msg.Height-m.textarea.Height()-chromeHeight-1, 1)
+	m.textarea.SetWidth(msg.Width)
+	m.progress.Width = msg.Width
+	if m.picker != nil {
+		m.picker.SetSize(m.viewport.Width, m.viewport.Height+m.textarea.Height()+chromeHeight)
+	}
+
+	switch {
+	case m.overlay:
+		m.setContent(m.overlayText)
+	case anchor >= 0:
+		m.setContent(m.renderMessages())
+		m.viewport.SetYOffset(m.messageOffsets()[anchor])
+	default:
+		m.refresh()
+	}
+	return m
+}
+
+// wrapWidth is the width the transcript is wrapped at: wrap_width, unless
+// the viewport is narrower or it is unset.
+func (m Model) wrapWidth() int {
+	if w := m.config.WrapWidth; w > 0 && w < m.viewport.Width {
+		return w
+	}
+	return m.viewport.Width
+}
+
+// wrap breaks the lines of text that are longer than width.
+func wrap(text string, width int) string {
+	if width <= 0 {
+		return text
+	}
+	return lipgloss.NewStyle().Width(width).Render(text)
+}
+
+// setContent puts text in the viewport, wrapped at wrapWidth and aligned
+// per wrap_align when that is narrower than the viewport.
+func (m *Model) setContent(text string) {
+	width := m.wrapWidth()
+	text = wrap(text, width)
+	if width < m.viewport.Width && m.config.WrapAlign == "center" {
+		text = lipgloss.PlaceHorizontal(m.viewport.Width, lipgloss.Center, text)
+	}
+	m.viewport.SetContent(text)
+}
+
+// showOverlay replaces the transcript with text, from its top, until Esc.
+func (m *Model) showOverlay(text string) {
+	m.overlay = true
+	m.overlayText = text
+	m.setContent(text)
+	m.viewport.GotoTop()
+}
@@ -0,0 +1,47 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bnema/cclui/api"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestResizeWhileStreaming(t *testing.T) {
+	m := newTestModel(t)
+	m, events := startStream(t, m, "tell me a story")
+	m = chunk(t, m, events, api.StreamEvent{Type: "content_block_start", Block: "text"})
+
+	word := "lorem ipsum dolor sit amet consectetur adipiscing elit "
+	var want strings.Builder
+	for i, size := range []tea.WindowSizeMsg{{Width: 40, Height: 20}, {Width: 100, Height: 30}, {Width: 25, Height: 12}, {Width: 60, Height: 18}} {
+		for range 3 {
+			m = chunk(t, m, events, api.StreamEvent{Type: "content_block_delta", Block: "text", Text: word})
+			want.WriteString(word)
+		}
+		m = update(t, m, size)
+
+		if got := m.messages[m.replyIndex].content; got != want.String() {
+			t.Fatalf("after resize %d the reply is %q, want %q", i, got, want.String())
+		}
+		if !m.viewport.AtBottom() {
+			t.Errorf("after resize %d the transcript no longer follows the reply", i)
+		}
+		for _, line := range strings.Split(m.viewport.View(), "\n") {
+			if w := lipgloss.Width(line); w > size.Width {
+				t.Errorf("after resize %d a line is %d wide, over %d: %q", i, w, size.Width, line)
+			}
+		}
+		if lines := strings.Count(m.View(), "\n") + 1; lines > size.Height {
+			t.Errorf("after resize %d the screen has %d lines, over %d", i, lines, size.Height)
+		}
+	}
+
+	m = chunk(t, m, events, api.StreamEvent{Type: "content_block_stop", Block: "text"})
+	m = update(t, m, streamDoneMsg{events: events})
+	if m.lastReply() != want.String() {
+		t.Errorf("reply = %q once done", m.lastReply())
+	}
+}
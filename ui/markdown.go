@@ -0,0 +1,226 @@
+package ui
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// tableDelimiter matches the row under the header of a Markdown table,
+// such as |:---|---:|, which also gives the alignment of each column.
+var tableDelimiter = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+
+// imageRef matches a Markdown image, ![alt](url "title").
+var imageRef = regexp.MustCompile(`!\[([^\]]*)\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)`)
+
+// minColumn is the narrowest a table column is wrapped to.
+const minColumn = 3
+
+// tableCells splits a row of a Markdown table into its cells, trimmed. A
+// pipe escaped with a backslash is part of its cell.
+func tableCells(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// formatTables aligns the columns of the Markdown tables of text, outside
+// code blocks. A table wider than width has its widest columns narrowed and
+// their cells wrapped, or with clip its lines cut at width instead.
+func formatTables(text string, width int, clip bool) string {
+	lines := strings.Split(text, "\n")
+	fences := findFences(lines)
+	inFence := func(i int) bool { return inFences(fences, i) }
+
+	out := make([]string, 0, len(lines))
+	for i := 0; i < len(lines); i++ {
+		header := lines[i]
+		if inFence(i) || i+1 >= len(lines) || inFence(i+1) || !strings.Contains(header, "|") || !tableDelimiter.MatchString(lines[i+1]) {
+			out = append(out, header)
+			continue
+		}
+		columns := tableCells(header)
+		delimiter := tableCells(lines[i+1])
+		if len(columns) != len(delimiter) {
+			out = append(out, header)
+			continue
+		}
+		rows := [][]string{columns}
+		j := i + 2
+		for ; j < len(lines) && !inFence(j) && strings.Contains(lines[j], "|") && strings.TrimSpace(lines[j]) != ""; j++ {
+			rows = append(rows, tableCells(lines[j]))
+		}
+		out = append(out, renderTable(rows, delimiter, width, clip)...)
+		i = j - 1
+	}
+	return strings.Join(out, "\n")
+}
+
+// renderTable lays out the rows of a table, the header first, in columns
+// as wide as their widest cell, aligned per the delimiter row.
+func renderTable(rows [][]string, delimiter []string, width int, clip bool) []string {
+	n := len(delimiter)
+	aligns := make([]lipgloss.Position, n)
+	for c, d := range delimiter {
+		switch {
+		case strings.HasPrefix(d, ":") && strings.HasSuffix(d, ":"):
+			aligns[c] = lipgloss.Center
+		case strings.HasSuffix(d, ":"):
+			aligns[c] = lipgloss.Right
+		}
+	}
+	widths := make([]int, n)
+	for r := range rows {
+		// Missing cells are empty and extra ones dropped, as GitHub does.
+		rows[r] = append(rows[r], make([]string, max(0, n-len(rows[r])))...)[:n]
+		for c, cell := range rows[r] {
+			widths[c] = max(widths[c], lipgloss.Width(cell), minColumn)
+		}
+	}
+
+	// Each column takes its width, two spaces and a pipe, plus the pipe
+	// opening the row.
+	total := 1
+	for _, w := range widths {
+		total += w + 3
+	}
+	for width > 0 && total > width && !clip {
+		widest := 0
+		for c := range widths {
+			if widths[c] > widths[widest] {
+				widest = c
+			}
+		}
+		if widths[widest] <= minColumn {
+			break
+		}
+		widths[widest]--
+		total--
+	}
+
+	var out []string
+	for r, row := range rows {
+		cells := make([][]string, n)
+		height := 1
+		for c, cell := range row {
+			cells[c] = strings.Split(lipgloss.NewStyle().Width(widths[c]).Align(aligns[c]).Render(cell), "\n")
+			height = max(height, len(cells[c]))
+		}
+		for l := 0; l < height; l++ {
+			parts := make([]string, n)
+			for c := range cells {
+				part := strings.Repeat(" ", widths[c])
+				if l < len(cells[c]) {
+					part = cells[c][l]
+				}
+				parts[c] = part
+			}
+			out = append(out, "| "+strings.Join(parts, " | ")+" |")
+		}
+		if r == 0 {
+			out = append(out, delimiterRow(widths, aligns))
+		}
+	}
+
+	if clip && width > 0 && total > width {
+		cut := lipgloss.NewStyle().MaxWidth(width - 1)
+		for i, line := range out {
+			out[i] = cut.Render(line) + "…"
+		}
+	}
+	return out
+}
+
+// delimiterRow is the row under the header of a table laid out with widths,
+// marking the alignment of each column.
+func delimiterRow(widths []int, aligns []lipgloss.Position) string {
+	var b strings.Builder
+	b.WriteString("|")
+	for c, w := range widths {
+		switch aligns[c] {
+		case lipgloss.Center:
+			b.WriteString(":" + strings.Repeat("-", w) + ":")
+		case lipgloss.Right:
+			b.WriteString(strings.Repeat("-", w+1) + ":")
+		default:
+			b.WriteString(strings.Repeat("-", w+2))
+		}
+		b.WriteString("|")
+	}
+	return b.String()
+}
+
+// image is a Markdown image of a reply.
+type image struct {
+	alt, url string
+}
+
+// findImages returns the Markdown images of text, outside code blocks, in
+// order.
+func findImages(text string) []image {
+	var images []image
+	lines := strings.Split(text, "\n")
+	fences := findFences(lines)
+	for i, line := range lines {
+		if inFences(fences, i) {
+			continue
+		}
+		for _, match := range imageRef.FindAllStringSubmatch(line, -1) {
+			images = append(images, image{alt: match[1], url: match[2]})
+		}
+	}
+	return images
+}
+
+// inFences reports whether line i is part of one of fences.
+func inFences(fences []fence, i int) bool {
+	for _, f := range fences {
+		if i >= f.open && i <= f.close {
+			return true
+		}
+	}
+	return false
+}
+
+// imagePlaceholders replaces the Markdown images of text, outside code
+// blocks, with a numbered placeholder giving their alt text and URL, the
+// number /open takes.
+func (m Model) imagePlaceholders(text string) string {
+	lines := strings.Split(text, "\n")
+	fences := findFences(lines)
+	n := 0
+	for i, line := range lines {
+		if inFences(fences, i) {
+			continue
+		}
+		lines[i] = imageRef.ReplaceAllStringFunc(line, func(ref string) string {
+			match := imageRef.FindStringSubmatch(ref)
+			n++
+			label := fmt.Sprintf("[image %d]", n)
+			if match[1] != "" {
+				label = fmt.Sprintf("[image %d: %s]", n, match[1])
+			}
+			return m.codeLabelStyle.Render(label) + " " + match[2]
+		})
+	}
+	return strings.Join(lines, "\n")
+}
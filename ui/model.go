@@ -0,0 +1,401 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/bnema/cclui/api"
+	"github.com/bnema/cclui/config"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	roleUser      = "user"
+	roleAssistant = "assistant"
+	roleError     = "error"
+	// roleInfo marks command output and other notes that are only shown.
+	roleInfo = "info"
+	// rolePanel is like roleInfo but framed as a block.
+	rolePanel = "panel"
+	// roleBanner is the startup banner. Its content is only the note under
+	// it; the rest is drawn from the current settings, see banner.
+	roleBanner = "banner"
+	// roleOpener is the opener, shown as the assistant's but not part of
+	// the conversation, see config.Settings.Opener.
+	roleOpener = "opener"
+)
+
+// conversational reports whether messages of role are part of the
+// conversation with Claude. Only those are sent to the API and saved; the
+// others, the banner, the opener, notes, panels and errors, are only
+// shown, however they read.
+func conversational(role string) bool {
+	return role == roleUser || role == roleAssistant
+}
+
+// message is one entry of the transcript. content is kept exactly as
+// received; it is only cleaned up when rendered.
+type message struct {
+	role    string
+	content string
+	usage   api.Usage
+	// at is when the message was added to the transcript; zero for those
+	// of conversations saved or imported without it.
+	at time.Time
+	// thinking is the extended thinking that preceded a reply.
+	thinking string
+	// tools are the tool calls of a reply, in order. Its text blocks are
+	// all in content.
+	tools []api.ToolUse
+	// blocks are the content blocks of a reply as the API streamed them,
+	// signatures included, to send it back as it was, see resentBlocks.
+	blocks []api.ContentBlock
+	// stopReason is why the API ended a reply, max_tokens when it was cut
+	// short, and stopSequence the stop sequence that ended it, if one did.
+	stopReason   string
+	stopSequence string
+	// model is the model that produced a reply, and request the request
+	// it answers, for /repro. Continuations after a reconnect keep the
+	// first one.
+	model   string
+	request *api.MessageRequest
+	// requestID is the ID the API gave the request of a reply, or of the
+	// one that failed with an error.
+	requestID string
+	// started is when the stream of a reply started, and streamed the time
+	// from then to its last event, see tokensPerSecond.
+	started  time.Time
+	streamed time.Duration
+	// processed is the output of post_process for a reply, shown instead
+	// of or under content; content is what Claude sees and /copy takes.
+	processed string
+	// pinned messages are never trimmed from the context.
+	pinned bool
+	// pruned messages are left out of the context by /prune.
+	pruned bool
+	// reconnects counts the times the stream of a reply dropped and was
+	// picked up, see reconnect. carried is the usage of the streams before
+	// the current one, and resumed is set until the continuation's first
+	// block, which carries on the last one.
+	reconnects int
+	carried    api.Usage
+	resumed    bool
+	// expanded is set on a reply taller than collapse_lines to show it in
+	// full, see collapse.
+	expanded bool
+	// failed marks the error that ended a turn, which can be retried with r
+	// while it is the last message.
+	failed bool
+}
+
+type Model struct {
+	client api.Provider
+	config *config.Config
+	// logger is the debug log, see debugLogger.
+	logger *log.Logger
+	// params holds the settings every request is built from; its Messages
+	// are left empty.
+	params      api.MessageRequest
+	viewport    viewport.Model
+	messages    []message
+	textarea    textarea.Model
+	senderStyle lipgloss.Style
+	// roles maps config.Roles to how their messages are shown.
+	roles      map[string]roleStyle
+	panelStyle lipgloss.Style
+	pinStyle   lipgloss.Style
+	// codeLabelStyle renders the numbered labels above code blocks.
+	codeLabelStyle lipgloss.Style
+	thinkingStyle  lipgloss.Style
+	// thinkingCollapsed folds every thinking pane into a single line.
+	thinkingCollapsed bool
+	// codeOnly shows only the code blocks of replies, see /code.
+	codeOnly bool
+	// compactView renders the transcript compactly, see /compact.
+	compactView bool
+	// rawView shows the text of replies as written instead of rendered,
+	// see toggleRaw.
+	rawView bool
+	// showRate shows the tokens per second of replies in their footer, see
+	// /tokens-per-second.
+	showRate bool
+	// branches holds every line of the conversation tree; messages is the
+	// live transcript of branches[branch].
+	branches  []branch
+	branch    int
+	streaming bool
+	// cancel stops the request in flight and events is the stream being
+	// shown; replies from any other stream are dropped. requestSeq numbers
+	// the requests, so that the start or failure of one that was stopped
+	// is told from those of the next.
+	cancel     context.CancelFunc
+	events     <-chan api.StreamEvent
+	requestSeq int
+	// replyIndex is the index of the reply the stream is written to, or -1
+	// before it starts. Notes appended meanwhile go after it, see
+	// streamingReply.
+	replyIndex int
+	// previousReply is the reply replaced by the last /retry.
+	previousReply string
+	// overlay is set while the transcript is replaced by a /diff or a /page
+	// view; Esc brings it back.
+	overlay     bool
+	overlayText string
+	// vim is set for the vim key scheme, whose normal mode is left for
+	// insert mode while vimInsert is set.
+	vim       bool
+	vimInsert bool
+	// lang and format are the response language and format enforced with
+	// /lang and /format, and verbosity the level set with /verbosity, see
+	// systemPrompt.
+	lang      string
+	format    string
+	verbosity string
+	// structured is the schema of /json, if on, and structuredRetries the
+	// number of times a reply to the current prompt that doesn't match it
+	// may still be sent back.
+	structured        *structuredMode
+	structuredRetries int
+	// scrolling is set while the transcript has the keyboard focus, and
+	// selected is then the index of the selected message, or -1.
+	scrolling bool
+	selected  int
+	// activeCode is the number of the code block of the selected reply
+	// that c and C stepped to, or 0, see stepCode.
+	activeCode int
+	// readOnly is set for -view, see NewViewer. searching is set while the
+	// input takes a search of the viewer, and search is the last one.
+	readOnly  bool
+	searching bool
+	search    string
+	// replay is the /replay in progress, if any.
+	replay *replayState
+	// picker is the model picker while it is open.
+	picker *list.Model
+	// webhook posts replies to webhook_url, if set. requestStart is when
+	// the last request was sent.
+	webhook      *webhook
+	requestStart time.Time
+	// startedAt is when the session started, for ExitSummary, and lastSend
+	// when the last message was sent, for send_guard.
+	startedAt time.Time
+	lastSend  time.Time
+	// lastActivity is when the last message was sent or reply ended, and
+	// showClock shows it in the status line along with how long the session
+	// lasted, see clock.
+	lastActivity time.Time
+	showClock    bool
+	clockID      int
+	// largeAllowed is set once requests over confirm_tokens were allowed
+	// for the rest of the session.
+	largeAllowed bool
+	// form is the /params form while it is open.
+	form *paramsForm
+	// attached holds the output of !! commands, to be sent with the next
+	// message.
+	attached []string
+	// question is the question of -stdin-context, sent on start, see askQuestion.
+	question string
+	// dismissedCompletion is the input Esc hid the command completions
+	// for, see completions.
+	dismissedCompletion string
+	// pending is the question waiting for a y/n answer, if any.
+	pending *confirmation
+	// tags are the tags of the conversation, see /tag.
+	tags []string
+	// title is the title of the conversation, see auto_title, and titledAs
+	// the name /save without a name saved it under, which it keeps.
+	title    string
+	titledAs string
+	// persona is the persona switched to with /persona, if any.
+	persona string
+	// autosaveName is this session's autosave file and autosavedTurns the
+	// number of turns it holds.
+	autosaveName   string
+	autosavedTurns int
+	// spent holds the tokens used this session by model, see addSpent.
+	spent map[string]api.Usage
+	// revealed is the number of messages /older brought back past
+	// max_transcript, and archived the number of messages written to
+	// archivePath so far, see archive.
+	revealed    int
+	archived    int
+	archivePath string
+	archiveErr  error
+	// idle is set once the connections to the API were closed for lack of
+	// activity, see idleAfter.
+	idle   bool
+	idleID int
+	// flashText is a transient note in the status line.
+	flashText   string
+	flashID     int
+	statusStyle lipgloss.Style
+	progress    progress.Model
+	err         error
+}
+
+// errMsg reports a request that failed before its response started; seq
+// is the requestSeq of the request.
+type errMsg struct {
+	err error
+	seq int
+}
+
+// checkAPIConnection fails if the API can't be used with cfg. It returns a
+// note for the start of the transcript, if there is something to say.
+func checkAPIConnection(client api.Provider, cfg *config.Config) (string, error) {
+	if _, ok := client.(*api.Demo); ok {
+		return "Demo mode: replies are canned and nothing is sent to the API.", nil
+	}
+	if cfg.APIKey == "" {
+		return "", errors.New("ANTHROPIC_API_KEY is not set")
+	}
+	return "", nil
+}
+
+// New builds the session of the TUI with client and cfg. It fails, for the
+// caller to exit with, when the API can't be used.
+func New(client api.Provider, cfg *config.Config) (Model, error) {
+	m := newModel(client, cfg)
+	note, err := checkAPIConnection(client, cfg)
+	if err != nil {
+		return m, err
+	}
+	switch {
+	case cfg.Banner:
+		m.appendMessage(roleBanner, note)
+	case note != "":
+		m.appendMessage(roleInfo, note)
+	}
+	if cfg.Opener != "" {
+		m.appendMessage(roleOpener, cfg.Opener)
+	}
+	if _, ok := client.(*api.Client); ok && cfg.InsecureSkipVerify {
+		m.appendMessage(roleError, "Warning: "+config.InsecureWarning)
+	}
+	if cfg.WebhookURL != "" {
+		m.webhook = newWebhook(cfg.WebhookURL, cfg.WebhookTimeout)
+	}
+	if cfg.Continue {
+		m = m.resume()
+	}
+	return m, nil
+}
+
+// newModel builds the Model of a session with client and cfg, with an
+// empty transcript.
+func newModel(client api.Provider, cfg *config.Config) Model {
+	ta := textarea.New()
+	ta.Placeholder = "Send a message..."
+	ta.Focus()
+
+	ta.Prompt = "┃ "
+	// No limit, so that pasted text and expanded templates fit.
+	ta.CharLimit = 0
+
+	ta.SetWidth(30)
+	ta.SetHeight(3)
+
+	// Remove cursor line styling
+	ta.FocusedStyle.CursorLine = lipgloss.NewStyle()
+	// The prompt bar shows which side has the focus.
+	ta.FocusedStyle.Prompt = lipgloss.NewStyle().Foreground(lipgloss.Color("5"))
+	ta.BlurredStyle.Prompt = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+
+	ta.ShowLineNumbers = false
+
+	vp := viewport.New(30, 5)
+
+	ta.KeyMap.InsertNewline.SetEnabled(false)
+
+	panelColor := "5"
+	if c := cfg.Roles["system"].Color; c != "" {
+		panelColor = c
+	}
+
+	return Model{
+		client:         client,
+		logger:         debugLogger(client),
+		config:         cfg,
+		autosaveName:   newAutosaveName(),
+		startedAt:      time.Now(),
+		statusStyle:    lipgloss.NewStyle().Foreground(lipgloss.Color("8")),
+		params:         cfg.Request(),
+		textarea:       ta,
+		messages:       []message{},
+		selected:       -1,
+		replyIndex:     -1,
+		branches:       []branch{{name: "main", parent: -1}},
+		progress:       progress.New(progress.WithDefaultGradient(), progress.WithWidth(30), progress.WithoutPercentage()),
+		viewport:       vp,
+		senderStyle:    lipgloss.NewStyle().Foreground(lipgloss.Color("5")),
+		roles:          newRoleStyles(cfg.Roles),
+		vim:            cfg.Editor == "vim",
+		showRate:       cfg.ShowTokensPerSecond,
+		showClock:      cfg.ShowClock,
+		spent:          map[string]api.Usage{},
+		pinStyle:       lipgloss.NewStyle().Foreground(lipgloss.Color("3")),
+		codeLabelStyle: lipgloss.NewStyle().Foreground(lipgloss.Color("8")),
+		thinkingStyle: lipgloss.NewStyle().
+			Faint(true).
+			Border(lipgloss.NormalBorder(), false, false, false, true).
+			BorderForeground(lipgloss.Color("8")).
+			PaddingLeft(1),
+		panelStyle: lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color(panelColor)).
+			Padding(0, 1),
+		err: nil,
+	}
+}
+
+// streamProgress estimates how far the current response is towards
+// max_tokens. Usage is only reported at the end of a stream, so until then
+// the output so far is estimated from its length.
+func (m Model) streamProgress() float64 {
+	i := m.streamingReply()
+	if i < 0 || m.params.MaxTokens <= 0 {
+		return 0
+	}
+	last := m.messages[i]
+
+	out := last.usage.OutputTokens
+	if est := api.EstimateTokens(last.content); est > out {
+		out = est
+	}
+	return min(float64(out)/float64(m.params.MaxTokens), 1)
+}
+
+// usage returns the token totals of the session so far.
+func (m Model) usage() api.Usage {
+	var total api.Usage
+	for _, msg := range m.messages {
+		total.InputTokens += msg.usage.InputTokens
+		total.OutputTokens += msg.usage.OutputTokens
+	}
+	return total
+}
+
+func (m Model) Init() tea.Cmd {
+	return tea.Batch(textarea.Blink, m.autosaveTick(), m.clockTick(), m.waitForWebhook(), m.askQuestion())
+}
+
+// questionMsg sends the question of -stdin-context.
+type questionMsg string
+
+// askQuestion sends the question given with -stdin-context, if any, as the first
+// message.
+func (m Model) askQuestion() tea.Cmd {
+	if m.question == "" {
+		return nil
+	}
+	question := m.question
+	return func() tea.Msg { return questionMsg(question) }
+}
@@ -0,0 +1,17 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/bnema/cclui/api"
+	"github.com/bnema/cclui/config"
+)
+
+func TestNewWithoutAPIKey(t *testing.T) {
+	if _, err := New(api.NewClient("", "https://api.anthropic.com"), &config.Config{}); err == nil || err.Error() != "ANTHROPIC_API_KEY is not set" {
+		t.Errorf("error = %v, want the key reported missing", err)
+	}
+	if _, err := New(api.NewDemo(), &config.Config{}); err != nil {
+		t.Errorf("demo mode: %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package ui
+
+import (
+	"fmt"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strconv"
+)
+
+// openImage handles /open [n], which opens image n of the last reply,
+// the first by default, with the system's handler for its URL.
+func (m Model) openImage(args []string) (Model, error) {
+	images := findImages(m.lastReply())
+	if len(images) == 0 {
+		return m, fmt.Errorf("the last response has no images")
+	}
+	n := 1
+	if len(args) > 0 {
+		v, err := strconv.Atoi(args[0])
+		if err != nil || v < 1 || v > len(images) {
+			return m, fmt.Errorf("usage: /open [n], with n between 1 and %d", len(images))
+		}
+		n = v
+	}
+
+	// Only web URLs: a reply could as well point at a local file or an
+	// application's URL scheme.
+	target := images[n-1].url
+	if u, err := url.Parse(target); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return m, fmt.Errorf("only http and https images can be opened, not %s", sanitize(target))
+	}
+	if err := openURL(target).Start(); err != nil {
+		return m, fmt.Errorf("opening %s: %w", sanitize(target), err)
+	}
+	m.appendMessage(roleInfo, "Opened "+sanitize(target))
+	return m, nil
+}
+
+// openURL is the command opening target with the system's handler.
+func openURL(target string) *exec.Cmd {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", target)
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", target)
+	}
+	return exec.Command("xdg-open", target)
+}
@@ -0,0 +1,53 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// pagerDoneMsg is sent when the pager exits and the TUI is back.
+type pagerDoneMsg struct {
+	err error
+}
+
+// pagerCommand returns the command line of the user's pager: $PAGER, or
+// less if that is unset. ok is false when the pager can't be found.
+func pagerCommand() (argv []string, ok bool) {
+	argv = strings.Fields(os.Getenv("PAGER"))
+	if len(argv) == 0 {
+		argv = []string{"less", "-R"}
+	}
+	if _, err := exec.LookPath(argv[0]); err != nil {
+		return nil, false
+	}
+	return argv, true
+}
+
+// page shows the last reply in the pager, suspending the TUI until it exits.
+// Without a pager the reply replaces the transcript instead, to be scrolled
+// there until Esc.
+func (m Model) page() (Model, tea.Cmd, error) {
+	if m.streaming {
+		return m, nil, fmt.Errorf("wait for the response to finish before paging it")
+	}
+	reply := sanitize(m.lastReply())
+	if reply == "" {
+		return m, nil, fmt.Errorf("no response to page yet")
+	}
+
+	argv, ok := pagerCommand()
+	if !ok {
+		m.showOverlay(reply + "\n\n(no pager found, Esc to close)")
+		return m, nil, nil
+	}
+
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stdin = strings.NewReader(reply + "\n")
+	return m, tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return pagerDoneMsg{err: err}
+	}), nil
+}
@@ -0,0 +1,242 @@
+package ui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bnema/cclui/api"
+	"github.com/bnema/cclui/config"
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// The fields of the /params form, in order. The system prompt comes last,
+// in a textarea as it may span lines.
+const (
+	fieldModel = iota
+	fieldMaxTokens
+	fieldTemperature
+	fieldTopP
+	fieldSystem
+	fieldCount
+)
+
+var fieldLabels = [fieldCount]string{"Model", "Max tokens", "Temperature", "Top P", "System"}
+
+// paramsForm edits the request parameters at once, see /params. Each field
+// is checked as it is typed, with the parsers of the config settings.
+type paramsForm struct {
+	inputs [fieldSystem]textinput.Model
+	system textarea.Model
+	focus  int
+	// errs are the problems of each field, and err that of the whole form
+	// once submitted.
+	errs [fieldCount]string
+	err  string
+}
+
+// openParams handles /params, which opens the form over the transcript
+// filled with the current parameters.
+func (m Model) openParams() Model {
+	f := &paramsForm{}
+	values := [fieldSystem]string{
+		m.params.Model,
+		strconv.Itoa(m.params.MaxTokens),
+		optional(m.params.Temperature),
+		optional(m.params.TopP),
+	}
+	for i := range f.inputs {
+		in := textinput.New()
+		in.Prompt = ""
+		in.Cursor.SetMode(cursor.CursorStatic)
+		in.SetValue(values[i])
+		f.inputs[i] = in
+	}
+	f.inputs[fieldTemperature].Placeholder = "default"
+	f.inputs[fieldTopP].Placeholder = "default"
+
+	f.system = textarea.New()
+	f.system.Prompt = "┃ "
+	f.system.Placeholder = "none"
+	f.system.ShowLineNumbers = false
+	f.system.CharLimit = 0
+	f.system.Cursor.SetMode(cursor.CursorStatic)
+	f.system.FocusedStyle.CursorLine = lipgloss.NewStyle()
+	f.system.SetWidth(max(m.viewport.Width-2, 20))
+	f.system.SetHeight(4)
+	f.system.SetValue(m.params.System)
+
+	f.setFocus(0)
+	m.form = f
+	return m
+}
+
+func optional(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+func (f *paramsForm) setFocus(i int) {
+	f.focus = (i + fieldCount) % fieldCount
+	for j := range f.inputs {
+		if j == f.focus {
+			f.inputs[j].Focus()
+		} else {
+			f.inputs[j].Blur()
+		}
+	}
+	if f.focus == fieldSystem {
+		f.system.Focus()
+	} else {
+		f.system.Blur()
+	}
+}
+
+// updateParams handles a key press while the form is open: Tab and
+// Shift+Tab move between fields, as do Up and Down outside the system
+// prompt, Enter applies the form (Alt+Enter starts a new line of the system
+// prompt) and Esc closes it unchanged.
+func (m Model) updateParams(key tea.KeyMsg) (tea.Model, tea.Cmd) {
+	f := m.form
+	switch {
+	case key.Type == tea.KeyCtrlC:
+		return m, tea.Quit
+	case key.Type == tea.KeyEsc:
+		m.form = nil
+		return m, nil
+	case key.Type == tea.KeyTab, key.Type == tea.KeyDown && f.focus != fieldSystem:
+		f.setFocus(f.focus + 1)
+		return m, nil
+	case key.Type == tea.KeyShiftTab, key.Type == tea.KeyUp && f.focus != fieldSystem:
+		f.setFocus(f.focus - 1)
+		return m, nil
+	case key.Type == tea.KeyEnter && !key.Alt:
+		return m.applyParams()
+	}
+
+	var cmd tea.Cmd
+	if f.focus == fieldSystem {
+		if key.Type == tea.KeyEnter {
+			key = tea.KeyMsg{Type: tea.KeyEnter}
+		}
+		f.system, cmd = f.system.Update(key)
+	} else {
+		f.inputs[f.focus], cmd = f.inputs[f.focus].Update(key)
+		_, f.errs[f.focus] = f.parse(f.focus)
+	}
+	f.err = ""
+	return m, cmd
+}
+
+// parse checks field i and returns its value, as the config setting of the
+// same name would take it.
+func (f *paramsForm) parse(i int) (any, string) {
+	v := strings.TrimSpace(f.inputs[i].Value())
+	var value any
+	var err error
+	switch i {
+	case fieldModel:
+		if v == "" {
+			err = fmt.Errorf("model can't be empty")
+		}
+		value = v
+	case fieldMaxTokens:
+		value, err = config.ParseMaxTokens(v)
+	case fieldTemperature, fieldTopP:
+		name := "temperature"
+		if i == fieldTopP {
+			name = "top_p"
+		}
+		var p *float64
+		if v != "" {
+			p, err = config.ParseUnit(name, v)
+		}
+		value = p
+	}
+	if err != nil {
+		return nil, err.Error()
+	}
+	return value, ""
+}
+
+// applyParams sets the parameters of the form if they are all valid, and
+// otherwise moves to the first field in error.
+func (m Model) applyParams() (tea.Model, tea.Cmd) {
+	f := m.form
+	var values [fieldSystem]any
+	first := -1
+	for i := range f.inputs {
+		values[i], f.errs[i] = f.parse(i)
+		if f.errs[i] != "" && first < 0 {
+			first = i
+		}
+	}
+	if first >= 0 {
+		f.setFocus(first)
+		return m, nil
+	}
+
+	params := m.params
+	params.Model = values[fieldModel].(string)
+	params.MaxTokens = values[fieldMaxTokens].(int)
+	params.Temperature = values[fieldTemperature].(*float64)
+	params.TopP = values[fieldTopP].(*float64)
+	params.System = f.system.Value()
+	if err := checkThinking(params); err != nil {
+		f.err = err.Error()
+		return m, nil
+	}
+
+	m.params = params
+	m.form = nil
+	m.appendMessage(roleInfo, "Request parameters: "+m.settingsSummary()+".")
+	return m, nil
+}
+
+func checkThinking(params api.MessageRequest) error {
+	budget := 0
+	if params.Thinking != nil {
+		budget = params.Thinking.BudgetTokens
+	}
+	return config.CheckThinking(budget, params.MaxTokens, params.Temperature)
+}
+
+// viewParams draws the form in place of the transcript.
+func (m Model) viewParams() string {
+	f := m.form
+	errStyle := m.roles["error"].style
+	width := 0
+	for _, label := range fieldLabels {
+		width = max(width, len(label))
+	}
+
+	var b strings.Builder
+	b.WriteString(m.senderStyle.Render("Request parameters") + "\n")
+	b.WriteString(m.codeLabelStyle.Render("Tab moves, Enter applies, Esc cancels; empty means the API default") + "\n\n")
+	for i := range f.inputs {
+		marker := "  "
+		if i == f.focus {
+			marker = m.senderStyle.Render("▸ ")
+		}
+		fmt.Fprintf(&b, "%s%-*s  %s\n", marker, width, fieldLabels[i], f.inputs[i].View())
+		if f.errs[i] != "" {
+			fmt.Fprintf(&b, "  %*s  %s\n", width, "", errStyle.Render(f.errs[i]))
+		}
+	}
+	marker := "  "
+	if f.focus == fieldSystem {
+		marker = m.senderStyle.Render("▸ ")
+	}
+	b.WriteString(marker + fieldLabels[fieldSystem] + " (Alt+Enter for a new line)\n")
+	b.WriteString(f.system.View())
+	if f.err != "" {
+		b.WriteString("\n\n" + errStyle.Render(f.err))
+	}
+	return lipgloss.NewStyle().Height(m.viewport.Height).MaxHeight(m.viewport.Height).Render(b.String())
+}
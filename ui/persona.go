@@ -0,0 +1,61 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+)
+
+// setPersona handles /persona <name>, which starts a new conversation with
+// the system prompt and parameters of a persona of the config file. The
+// /lang, /format and /verbosity settings are kept.
+func (m Model) setPersona(args []string) (Model, error) {
+	if len(args) != 1 {
+		return m, fmt.Errorf("usage: /persona <name>")
+	}
+	if m.streaming {
+		return m, fmt.Errorf("wait for the response to finish before switching persona")
+	}
+	params, err := m.config.PersonaRequest(args[0])
+	if err != nil {
+		return m, err
+	}
+
+	m.params = params
+	m.persona = args[0]
+	m.branches = []branch{{name: "main", parent: -1}}
+	m.branch = 0
+	m.messages = nil
+	m.tags = nil
+	m.title, m.titledAs = "", ""
+	m.previousReply = ""
+	m.attached = nil
+	m.selected = -1
+	m.resetArchive()
+	// The new conversation is autosaved apart from the one it replaces.
+	m.autosaveName = newAutosaveName()
+	m.autosavedTurns = 0
+	m.appendMessage(roleInfo, fmt.Sprintf("Persona %s: new conversation with %s.", m.persona, m.settingsSummary()))
+	return m, nil
+}
+
+// listPersonas handles /personas.
+func (m Model) listPersonas() string {
+	names := m.config.PersonaNames()
+	if len(names) == 0 {
+		return fmt.Sprintf("No personas in %s", m.config.File)
+	}
+
+	var b strings.Builder
+	b.WriteString("Personas:")
+	for _, name := range names {
+		mark := "  "
+		if name == m.persona {
+			mark = "* "
+		}
+		b.WriteString("\n" + mark + name)
+		if system := m.config.Personas[name].System; system != "" {
+			b.WriteString("  " + truncate(strings.Join(strings.Fields(system), " "), 60))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,109 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bnema/cclui/api"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// modelsMsg carries the models to pick from. err is set when the models
+// endpoint failed and the built-in list is offered instead.
+type modelsMsg struct {
+	models []api.ModelInfo
+	err    error
+}
+
+// modelItem is a model as shown in the picker.
+type modelItem struct {
+	api.ModelInfo
+}
+
+func (i modelItem) Title() string       { return i.ID }
+func (i modelItem) FilterValue() string { return i.ID + " " + i.DisplayName }
+
+func (i modelItem) Description() string {
+	desc := i.DisplayName
+	if i.ContextWindow > 0 {
+		desc += fmt.Sprintf(" · %dK context", i.ContextWindow/1000)
+	}
+	if i.InputPrice > 0 {
+		desc += fmt.Sprintf(" · $%g/$%g per MTok", i.InputPrice, i.OutputPrice)
+	}
+	return desc
+}
+
+// fetchModels asks the API which models the key can use.
+func (m Model) fetchModels() tea.Cmd {
+	client := m.client
+	return m.recovering(func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		models, err := client.Models(ctx)
+		if err != nil || len(models) == 0 {
+			return modelsMsg{models: api.KnownModels, err: err}
+		}
+		return modelsMsg{models: models}
+	}, commandFailed)
+}
+
+// openPicker shows the model picker over the transcript, with the current
+// model selected.
+func (m Model) openPicker(msg modelsMsg) Model {
+	items := make([]list.Item, len(msg.models))
+	selected := 0
+	for i, info := range msg.models {
+		items[i] = modelItem{info}
+		if info.ID == m.params.Model {
+			selected = i
+		}
+	}
+
+	l := list.New(items, list.NewDefaultDelegate(), m.viewport.Width, m.viewport.Height+m.textarea.Height()+chromeHeight)
+	l.Title = "Pick a model"
+	if msg.err != nil {
+		l.Title += " (built-in list)"
+	}
+	l.SetShowHelp(false)
+	l.Select(selected)
+	m.picker = &l
+	return m
+}
+
+// updatePicker handles a key press while the picker is open: Enter applies
+// the selected model and Esc closes the picker, unless a filter is being
+// typed, in which case both go to the filter.
+func (m Model) updatePicker(key tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if key.Type == tea.KeyCtrlC {
+		return m, tea.Quit
+	}
+	if m.picker.FilterState() != list.Filtering {
+		switch key.Type {
+		case tea.KeyEsc:
+			m.picker = nil
+			return m, nil
+		case tea.KeyEnter:
+			item, ok := m.picker.SelectedItem().(modelItem)
+			m.picker = nil
+			if ok {
+				m = m.setModel(item.ID)
+			}
+			return m, nil
+		}
+	}
+
+	l, cmd := m.picker.Update(key)
+	m.picker = &l
+	return m, cmd
+}
+
+// setModel switches the model used for the following requests.
+func (m Model) setModel(id string) Model {
+	m.params.Model = id
+	m.appendMessage(roleInfo, "Model set to "+id+".")
+	return m
+}
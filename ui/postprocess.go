@@ -0,0 +1,99 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// postProcessCap is the largest reply piped through post_process, and the
+// largest output taken from it. Past it the reply is shown as received.
+const postProcessCap = 256 << 10
+
+// postProcessMsg reports the output of post_process for the reply at
+// index, whose content was content when it ran.
+type postProcessMsg struct {
+	index   int
+	content string
+	output  string
+	err     error
+}
+
+// postProcess pipes the reply at index, which just ended, through
+// post_process, if one is set.
+func (m Model) postProcess(index int) tea.Cmd {
+	command := m.config.PostProcess
+	if command == "" || index < 0 || m.messages[index].content == "" {
+		return nil
+	}
+	content, timeout := m.messages[index].content, m.config.PostProcessTimeout
+	return m.recovering(func() tea.Msg {
+		output, err := runPostProcess(command, content, timeout)
+		return postProcessMsg{index: index, content: content, output: output, err: err}
+	}, commandFailed)
+}
+
+func runPostProcess(command, input string, timeout time.Duration) (string, error) {
+	if len(input) > postProcessCap {
+		return "", fmt.Errorf("the response is over the %d KiB limit", postProcessCap>>10)
+	}
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var out, stderr bytes.Buffer
+	cmd := shellCommand(ctx, command)
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	// Don't wait on children of the shell still holding its output.
+	cmd.WaitDelay = time.Second
+	err := cmd.Run()
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return "", fmt.Errorf("it took over %v", timeout)
+	case err != nil:
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%v: %s", err, strings.SplitN(msg, "\n", 2)[0])
+		}
+		return "", err
+	case out.Len() > postProcessCap:
+		return "", fmt.Errorf("its output is over the %d KiB limit", postProcessCap>>10)
+	}
+	return strings.TrimRight(out.String(), "\n"), nil
+}
+
+// postProcessDone keeps the output of post_process to be shown with its
+// reply, unless the reply has changed since. A failure leaves the reply as
+// received.
+func (m Model) postProcessDone(msg postProcessMsg) Model {
+	if msg.index >= len(m.messages) || m.messages[msg.index].content != msg.content {
+		return m
+	}
+	if msg.err != nil {
+		m.appendMessage(roleError, fmt.Sprintf("post_process failed, the response is shown as received: %v", msg.err))
+		return m
+	}
+	m.messages[msg.index].processed = msg.output
+	m.refresh()
+	return m
+}
+
+// processedText is how a reply that went through post_process is shown:
+// text is the reply as rendered, replaced by the output of post_process or
+// followed by it as post_process_mode says.
+func (m Model) processedText(msg message, text string) string {
+	output := sanitize(msg.processed)
+	if m.config.PostProcessMode == "append" {
+		return text + "\n" + m.codeLabelStyle.Render("── post_process") + "\n" + output
+	}
+	return output
+}
@@ -0,0 +1,32 @@
+package ui
+
+import (
+	"fmt"
+	"time"
+)
+
+// Close tears the session down once the program has quit, whichever way it
+// did: it cancels the request in flight, lets the posts to the webhook go
+// out, autosaves the conversation if
+// autosave is on and it changed since, and closes the connections to the
+// API.
+func (m Model) Close() error {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	if c, ok := m.client.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+	if m.webhook != nil {
+		m.webhook.close(max(m.config.WebhookTimeout, time.Second))
+	}
+
+	autosave := m.config.AutosaveTurns > 0 || m.config.AutosaveMinutes > 0
+	if n := turns(m.messages); !autosave || n == 0 || n == m.autosavedTurns {
+		return nil
+	}
+	if _, err := m.saveConversation(m.autosaveName); err != nil {
+		return fmt.Errorf("autosave on exit: %w", err)
+	}
+	return nil
+}
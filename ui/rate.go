@@ -0,0 +1,75 @@
+package ui
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bnema/cclui/api"
+)
+
+// minRateWindow is how long a reply must have streamed for its tokens per
+// second to mean something.
+const minRateWindow = 100 * time.Millisecond
+
+// tokensPerSecond is the output throughput of a reply: its output tokens
+// over the time from the start of its stream to its last event, so that
+// the wait for the first token doesn't count. It is 0 when there is too
+// little to go by, as for replies loaded from a file.
+func tokensPerSecond(msg message) float64 {
+	if msg.streamed < minRateWindow {
+		return 0
+	}
+	out, _ := outputTokens(msg)
+	return float64(out) / msg.streamed.Seconds()
+}
+
+// outputTokens is the number of tokens of a reply. Usage only gives the
+// final count at the end of the stream, so until then, or when the reply
+// was stopped, it is estimated from the text as streamProgress does.
+func outputTokens(msg message) (n int, estimated bool) {
+	if msg.stopReason != "" {
+		return msg.usage.OutputTokens, false
+	}
+	return max(msg.usage.OutputTokens, api.EstimateTokens(msg.thinking+msg.content)), true
+}
+
+func formatRate(rate float64) string {
+	return fmt.Sprintf("%.1f tok/s", rate)
+}
+
+// tokensPerSecondCommand handles /tokens-per-second [on|off]. Without an
+// argument it shows the throughput of the last reply, the one streaming
+// included; on and off show the throughput of each reply under it, or stop.
+func (m Model) tokensPerSecondCommand(args []string) (Model, error) {
+	switch {
+	case len(args) > 1 || (len(args) == 1 && args[0] != "on" && args[0] != "off"):
+		return m, fmt.Errorf("usage: /tokens-per-second [on|off]")
+	case len(args) == 1:
+		m.showRate = args[0] == "on"
+		if m.showRate {
+			m.appendMessage(roleInfo, "Responses will show their tokens per second (/tokens-per-second off hides it).")
+		} else {
+			m.appendMessage(roleInfo, "Responses no longer show their tokens per second.")
+		}
+		return m, nil
+	}
+
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		msg := m.messages[i]
+		if msg.role != roleAssistant {
+			continue
+		}
+		rate := tokensPerSecond(msg)
+		if rate == 0 {
+			return m, fmt.Errorf("the last response has no timing to go by")
+		}
+		out, estimated := outputTokens(msg)
+		tokens := fmt.Sprintf("%d tokens", out)
+		if estimated {
+			tokens = fmt.Sprintf("about %d tokens", out)
+		}
+		m.appendMessage(roleInfo, fmt.Sprintf("%s: %s in %.1fs of streaming.", formatRate(rate), tokens, msg.streamed.Seconds()))
+		return m, nil
+	}
+	return m, fmt.Errorf("no response yet")
+}
@@ -0,0 +1,28 @@
+package ui
+
+// toggleRaw switches the transcript between rendered Markdown and the raw
+// text of replies, for Ctrl+R. The transcript stays where it was: on the
+// selected message, at its end, or with the same message at the top, as
+// far into it as it still reaches.
+func (m Model) toggleRaw() Model {
+	m.rawView = !m.rawView
+	if m.overlay {
+		// Rendered again as the view closes.
+		return m
+	}
+	atBottom := m.viewport.AtBottom()
+	top := m.topMessage()
+	into := m.viewport.YOffset - m.messageOffsets()[top]
+
+	m.setContent(m.renderMessages())
+	switch {
+	case m.selectedMessage() >= 0:
+		m.showSelected()
+	case atBottom:
+		m.viewport.GotoBottom()
+	default:
+		offsets := m.messageOffsets()
+		m.viewport.SetYOffset(offsets[top] + max(min(into, offsets[top+1]-offsets[top]-1), 0))
+	}
+	return m
+}
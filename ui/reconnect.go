@@ -0,0 +1,45 @@
+package ui
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bnema/cclui/api"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// reconnect picks up the reply whose stream failed with err, when it looks
+// like a passing failure and reconnects are left for it: the part received
+// is sent back, followed by the nudge of /continue, and the continuation is
+// streamed into the same message. It returns nil when the turn fails
+// instead.
+func (m *Model) reconnect(err error) tea.Cmd {
+	n := m.streamingReply()
+	if n < 0 || m.messages[n].reconnects >= m.config.Reconnects || !transient(err) {
+		return nil
+	}
+	last := &m.messages[n]
+	last.reconnects++
+	last.carried = last.usage
+	last.resumed = true
+
+	messages := m.contextMessages()
+	if last.content != "" {
+		messages = append(messages, api.ConstructUserMessage(continueNudge))
+	}
+	m.refresh()
+	return m.request(messages, true)
+}
+
+// transient reports whether err, which ended a stream, may well not happen
+// again: a network error or stall rather than a refused request.
+func transient(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var apiErr *api.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Temporary()
+	}
+	return true
+}
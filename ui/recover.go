@@ -0,0 +1,50 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"runtime/debug"
+
+	"github.com/bnema/cclui/api"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// debugLogger returns the debug log, which is the client's: it discards
+// everything unless cclui runs with -debug.
+func debugLogger(client api.Provider) *log.Logger {
+	if c, ok := client.(*api.Client); ok && c.Logger != nil {
+		return c.Logger
+	}
+	return log.New(io.Discard, "", 0)
+}
+
+// commandErrorMsg reports a command that failed in the background. Unlike
+// errMsg, it does not end the turn.
+type commandErrorMsg struct {
+	err error
+}
+
+// recovering wraps cmd so that a panic in it is turned into the message
+// fail returns, with the stack in the debug log. Bubble Tea restores the
+// terminal after a panic in Update, but commands run in goroutines of their
+// own where it can't catch them, and would take the program down with the
+// terminal left in raw mode.
+func (m Model) recovering(cmd tea.Cmd, fail func(error) tea.Msg) tea.Cmd {
+	logger := m.logger
+	return func() (msg tea.Msg) {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.Printf("panic: %v\n%s", r, debug.Stack())
+				msg = fail(fmt.Errorf("internal error: %v", r))
+			}
+		}()
+		return cmd()
+	}
+}
+
+// commandFailed reports err as the outcome of a command, leaving any
+// running stream alone.
+func commandFailed(err error) tea.Msg {
+	return commandErrorMsg{err: err}
+}
@@ -0,0 +1,400 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// command is a slash command. names are the names it is typed as, and
+// usage and help how /help lists it. run gets the name typed and the
+// arguments after it, once their number has been checked against usage,
+// see arity.
+type command struct {
+	names []string
+	usage string
+	help  string
+	run   func(m Model, name string, args []string) (tea.Model, tea.Cmd)
+}
+
+// commands is the registry of slash commands, in the order of the README,
+// which /help follows. It is filled by init, as /help refers to it.
+var commands []command
+
+// commandIndex maps each name of a command to it.
+var commandIndex = map[string]*command{}
+
+// failing runs f for a command that changes the session or fails with an
+// error to show.
+func failing(f func(Model, []string) (Model, error)) func(Model, string, []string) (tea.Model, tea.Cmd) {
+	return func(m Model, _ string, args []string) (tea.Model, tea.Cmd) {
+		m, err := f(m, args)
+		if err != nil {
+			m.appendMessage(roleError, err.Error())
+		}
+		return m, nil
+	}
+}
+
+// starting runs f for a command without arguments that may start
+// something, or fail with an error to show.
+func starting(f func(Model) (Model, tea.Cmd, error)) func(Model, string, []string) (tea.Model, tea.Cmd) {
+	return func(m Model, _ string, _ []string) (tea.Model, tea.Cmd) {
+		m, cmd, err := f(m)
+		if err != nil {
+			m.appendMessage(roleError, err.Error())
+		}
+		return m, cmd
+	}
+}
+
+// showing runs f for a command that only shows text, in role, or fails.
+func showing(role string, f func(Model) (string, error)) func(Model, string, []string) (tea.Model, tea.Cmd) {
+	return func(m Model, _ string, _ []string) (tea.Model, tea.Cmd) {
+		out, err := f(m)
+		if err != nil {
+			m.appendMessage(roleError, err.Error())
+			return m, nil
+		}
+		m.appendMessage(role, out)
+		return m, nil
+	}
+}
+
+// report adapts a report that can't fail to showing.
+func report(f func(Model) string) func(Model) (string, error) {
+	return func(m Model) (string, error) { return f(m), nil }
+}
+
+func init() {
+	commands = []command{
+		{names: []string{"/help"}, usage: "/help", help: "show this help",
+			run: func(m Model, _ string, _ []string) (tea.Model, tea.Cmd) {
+				m.showOverlay(helpText())
+				return m, nil
+			}},
+		{names: []string{"/whoami"}, usage: "/whoami", help: "show the key, base URL and organization",
+			run: func(m Model, _ string, _ []string) (tea.Model, tea.Cmd) { return m, m.whoami() }},
+		{names: []string{"/info"}, usage: "/info", help: "summarize the session",
+			run: showing(rolePanel, report(Model.info))},
+		{names: []string{"/cost"}, usage: "/cost", help: "show the estimated cost of the session",
+			run: showing(roleInfo, report(Model.costReport))},
+		{names: []string{"/tokens"}, usage: "/tokens", help: "count the tokens of the context exactly",
+			run: func(m Model, _ string, _ []string) (tea.Model, tea.Cmd) { return m, m.countTokens() }},
+		{names: []string{"/retry"}, usage: "/retry", help: "regenerate the last response",
+			run: func(m Model, _ string, _ []string) (tea.Model, tea.Cmd) { return m.retry() }},
+		{names: []string{"/retry-with"}, usage: "/retry-with <model>", help: "regenerate it with another model, just this once",
+			run: func(m Model, _ string, args []string) (tea.Model, tea.Cmd) { return m.retryWith(args) }},
+		{names: []string{"/repro"}, usage: "/repro [file]", help: "show or save the request of the last response",
+			run: failing(Model.repro)},
+		{names: []string{"/requestid"}, usage: "/requestid", help: "show the request-id of the last request",
+			run: showing(roleInfo, func(m Model) (string, error) {
+				id, err := m.lastRequestID()
+				return "request-id of the last request: " + id, err
+			})},
+		{names: []string{"/tokens-per-second"}, usage: "/tokens-per-second [on|off]", help: "show how fast the last response streamed",
+			run: failing(Model.tokensPerSecondCommand)},
+		{names: []string{"/clock"}, usage: "/clock [on|off]", help: "show or hide the session clock",
+			run: func(m Model, _ string, args []string) (tea.Model, tea.Cmd) {
+				m, cmd, err := m.clockCommand(args)
+				if err != nil {
+					m.appendMessage(roleError, err.Error())
+				}
+				return m, cmd
+			}},
+		{names: []string{"/continue"}, usage: "/continue", help: "have Claude carry on with a cut-off response",
+			run: func(m Model, _ string, _ []string) (tea.Model, tea.Cmd) { return m.continueReply() }},
+		{names: []string{"/diff"}, usage: "/diff", help: "compare with the response /retry replaced",
+			run: failing(func(m Model, _ []string) (Model, error) { return m.showDiff() })},
+		{names: []string{"/replay"}, usage: "/replay", help: "stream the last response again",
+			run: starting(Model.startReplay)},
+		{names: []string{"/copy"}, usage: "/copy [all] [markdown|text]", help: "copy the last response or the conversation",
+			run: func(m Model, _ string, args []string) (tea.Model, tea.Cmd) {
+				cmd, err := m.copyText(args)
+				if err != nil {
+					m.appendMessage(roleError, err.Error())
+				}
+				return m, cmd
+			}},
+		{names: []string{"/export"}, usage: "/export <file>", help: "write the conversation to a standalone HTML page",
+			run: func(m Model, _ string, args []string) (tea.Model, tea.Cmd) {
+				cmd, err := m.exportHTML(args)
+				if err != nil {
+					m.appendMessage(roleError, err.Error())
+				}
+				return m, cmd
+			}},
+		{names: []string{"/open"}, usage: "/open [n]", help: "open image n of the last response",
+			run: failing(Model.openImage)},
+		{names: []string{"/page"}, usage: "/page", help: "open the last response in the pager",
+			run: starting(Model.page)},
+		{names: []string{"/older"}, usage: "/older", help: "show messages hidden by max_transcript",
+			run: starting(Model.showOlder)},
+		{names: []string{"/savecode"}, usage: "/savecode <n> <file>", help: "write code block n to a file",
+			run: func(m Model, _ string, args []string) (tea.Model, tea.Cmd) {
+				path, err := m.saveCodeBlock(args)
+				if err != nil {
+					m.appendMessage(roleError, err.Error())
+					return m, nil
+				}
+				m.appendMessage(roleInfo, "Wrote "+path)
+				return m, nil
+			}},
+		{names: []string{"/runcode"}, usage: "/runcode <n>", help: "run code block n after confirmation",
+			run: failing(Model.runCodeBlock)},
+		{names: []string{"/save"}, usage: "/save [name]", help: "save the conversation",
+			run: func(m Model, _ string, args []string) (tea.Model, tea.Cmd) {
+				if len(args) == 0 {
+					return m.saveUntitled()
+				}
+				return m.reportSave(m.saveConversation(args[0])), nil
+			}},
+		{names: []string{"/load"}, usage: "/load <name>", help: "load a saved conversation",
+			run: func(m Model, _ string, args []string) (tea.Model, tea.Cmd) {
+				loaded, err := m.loadConversation(args[0])
+				if err != nil {
+					m.appendMessage(roleError, err.Error())
+					return m, nil
+				}
+				return loaded.flash("Loaded with " + loaded.settingsSummary())
+			}},
+		{names: []string{"/list"}, usage: "/list [tag …]", help: "list saved conversations, those with the tags",
+			run: func(m Model, _ string, args []string) (tea.Model, tea.Cmd) {
+				out, err := listConversations(args)
+				if err != nil {
+					m.appendMessage(roleError, err.Error())
+					return m, nil
+				}
+				m.appendMessage(roleInfo, out)
+				return m, nil
+			}},
+		{names: []string{"/stats"}, usage: "/stats", help: "add up the usage of the saved conversations",
+			run: func(m Model, _ string, _ []string) (tea.Model, tea.Cmd) { return m, m.usageStats() }},
+		{names: []string{"/tag", "/untag"}, usage: "/tag [tag …], /untag <tag …>", help: "tag the conversation, or show its tags",
+			run: func(m Model, name string, args []string) (tea.Model, tea.Cmd) {
+				var err error
+				if name == "/tag" {
+					m, err = m.tag(args)
+				} else {
+					m, err = m.untag(args)
+				}
+				if err != nil {
+					m.appendMessage(roleError, err.Error())
+				}
+				return m, nil
+			}},
+		{names: []string{"/branch"}, usage: "/branch [turns]", help: "fork the conversation",
+			run: failing(Model.branchCommand)},
+		{names: []string{"/branches"}, usage: "/branches", help: "show the branch tree",
+			run: showing(roleInfo, report(Model.listBranches))},
+		{names: []string{"/switch"}, usage: "/switch <branch>", help: "switch to a branch",
+			run: failing(Model.switchCommand)},
+		{names: []string{"/code"}, usage: "/code [on|off]", help: "show only the code blocks of responses",
+			run: failing(Model.setCodeOnly)},
+		{names: []string{"/lang"}, usage: "/lang [code|off]", help: "enforce the response language",
+			run: failing(Model.setLang)},
+		{names: []string{"/format"}, usage: "/format [json|markdown|plain|off]", help: "enforce the response format",
+			run: failing(Model.setFormat)},
+		{names: []string{"/verbosity"}, usage: "/verbosity [level|normal]", help: "set how much Claude says, or show it",
+			run: failing(Model.setVerbosity)},
+		{names: []string{"/json"}, usage: "/json [<schema-file> [tool]|off]", help: "check responses against a JSON schema",
+			run: failing(Model.setStructured)},
+		{names: []string{"/compact", "/expand"}, usage: "/compact, /expand", help: "tidy the transcript display, or restore it",
+			run: func(m Model, name string, _ []string) (tea.Model, tea.Cmd) { return m.setCompact(name == "/compact") }},
+		{names: []string{"/pin", "/unpin"}, usage: "/pin [turn], /unpin [turn]", help: "keep a turn in context when trimming",
+			run: func(m Model, name string, args []string) (tea.Model, tea.Cmd) {
+				m, err := m.setPinned(args, name == "/pin")
+				if err != nil {
+					m.appendMessage(roleError, err.Error())
+				}
+				return m, nil
+			}},
+		{names: []string{"/prune"}, usage: "/prune <turns>", help: "leave all but the last turns out of the context",
+			run: failing(Model.prune)},
+		{names: []string{"/params"}, usage: "/params", help: "edit model, max_tokens, sampling and system prompt",
+			run: func(m Model, _ string, _ []string) (tea.Model, tea.Cmd) { return m.openParams(), nil }},
+		{names: []string{"/system"}, usage: "/system", help: "show the system prompt as sent, variables filled in",
+			run: showing(rolePanel, report(Model.showSystem))},
+		{names: []string{"/persona"}, usage: "/persona <name>", help: "start over with a persona of the config file",
+			run: failing(Model.setPersona)},
+		{names: []string{"/personas"}, usage: "/personas", help: "list the personas",
+			run: showing(roleInfo, report(Model.listPersonas))},
+		{names: []string{"/tier"}, usage: "/tier [auto|standard_only|default]", help: "set or show the service tier",
+			run: failing(Model.setServiceTier)},
+		{names: []string{"/model"}, usage: "/model [id]", help: "switch model, or pick one",
+			run: func(m Model, _ string, args []string) (tea.Model, tea.Cmd) {
+				if len(args) == 0 {
+					return m, m.fetchModels()
+				}
+				return m.setModel(args[0]), nil
+			}},
+		{names: []string{"/tools"}, usage: "/tools", help: "list the declared tools",
+			run: showing(roleInfo, report(Model.toolsReport))},
+		{names: []string{"/toolchoice"}, usage: "/toolchoice [auto|any|none|<tool>]", help: "control when Claude uses a tool",
+			run: failing(Model.setToolChoice)},
+		{names: []string{"/paralleltools"}, usage: "/paralleltools [on|off]", help: "let Claude call several tools per reply, or one",
+			run: failing(Model.setParallelTools)},
+		{names: []string{"/import"}, usage: "/import <file> [n or title]", help: "import an OpenAI or ChatGPT export",
+			run: func(m Model, _ string, args []string) (tea.Model, tea.Cmd) {
+				var pick string
+				if len(args) == 2 {
+					pick = args[1]
+				}
+				imported, err := m.Import(args[0], pick)
+				if err != nil {
+					m.appendMessage(roleError, err.Error())
+					return m, nil
+				}
+				return imported, nil
+			}},
+		{names: []string{"/env"}, usage: "/env", help: "show each setting and its source",
+			run: showing(roleInfo, report(Model.describeConfig))},
+		{names: []string{"/reload-config"}, usage: "/reload-config", help: "apply changes to the config file and environment",
+			run: starting(Model.reloadConfig)},
+		{names: []string{"/paths"}, usage: "/paths", help: "show where cclui keeps its files",
+			run: showing(roleInfo, func(Model) (string, error) { return listPaths() })},
+		{names: []string{"/templates"}, usage: "/templates", help: "list prompt templates",
+			run: showing(roleInfo, func(Model) (string, error) { return listTemplates() })},
+		{names: []string{"/use"}, usage: "/use <template> [key=value …]", help: "expand a template into the input",
+			run: useTemplate},
+		{names: []string{"/run"}, usage: "/run <template> [key=value …]", help: "expand a template and send it",
+			run: useTemplate},
+	}
+	for i := range commands {
+		for _, name := range commands[i].names {
+			commandIndex[name] = &commands[i]
+		}
+	}
+}
+
+// useTemplate handles /use and /run.
+func useTemplate(m Model, name string, args []string) (tea.Model, tea.Cmd) {
+	text, err := expandTemplate(args)
+	if err != nil {
+		m.appendMessage(roleError, err.Error())
+		return m, nil
+	}
+	if name == "/run" {
+		return m.send(text)
+	}
+	m.textarea.SetValue(text)
+	return m, nil
+}
+
+// usageOf returns the part of the usage of c for name, which is the whole
+// of it unless c has several names, as in "/pin [turn], /unpin [turn]".
+func (c *command) usageOf(name string) string {
+	for _, part := range strings.Split(c.usage, ", ") {
+		if fields := strings.Fields(part); len(fields) > 0 && fields[0] == name {
+			return part
+		}
+	}
+	return c.usage
+}
+
+// arity reads the number of arguments a usage line allows: one for each
+// <argument> and up to one more for each [optional] one, nested ones
+// included. The alternatives of an optional argument, as in [auto|<tool>],
+// are one argument. An argument ending in … can be repeated, which leaves
+// no upper bound, reported as most -1.
+func arity(usage string) (least, most int) {
+	_, spec, _ := strings.Cut(usage, " ")
+	depth := 0
+	for _, r := range spec {
+		switch r {
+		case '<':
+			if depth == 0 {
+				least++
+				most++
+			}
+		case '[':
+			depth++
+			most++
+		case ']':
+			depth--
+		case '…':
+			return least, -1
+		}
+	}
+	return least, most
+}
+
+// checkArgs reports the usage of name when args don't fit it.
+func (c *command) checkArgs(name string, args []string) error {
+	usage := c.usageOf(name)
+	least, most := arity(usage)
+	if len(args) < least || most >= 0 && len(args) > most {
+		return fmt.Errorf("usage: %s", usage)
+	}
+	return nil
+}
+
+// unknownCommand is the note for a command that isn't in the registry,
+// with the names it may have been a typo of.
+func unknownCommand(name string) string {
+	note := "Unknown command: " + name
+	if names := suggestCommands(name); len(names) > 0 {
+		note += ". Did you mean " + strings.Join(names, " or ") + "?"
+	}
+	return note + " (/help lists them)"
+}
+
+// suggestCommands returns the names of commands that name starts, or is
+// one or two edits away from, closest first, at most three.
+func suggestCommands(name string) []string {
+	type match struct {
+		name     string
+		distance int
+	}
+	var matches []match
+	for _, c := range commands {
+		for _, n := range c.names {
+			d := editDistance(name, n)
+			if len(name) > 1 && strings.HasPrefix(n, name) {
+				d = 0
+			}
+			if d <= 2 {
+				matches = append(matches, match{n, d})
+			}
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].distance < matches[j].distance })
+	names := make([]string, 0, 3)
+	for _, match := range matches[:min(len(matches), 3)] {
+		names = append(names, match.name)
+	}
+	return names
+}
+
+// editDistance is the Levenshtein distance between a and b, in runes.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
+
+// commandHelp lists the slash commands for /help, as usage and help.
+func commandHelp() [][2]string {
+	entries := make([][2]string, 0, len(commands))
+	for _, c := range commands {
+		entries = append(entries, [2]string{c.usage, c.help})
+	}
+	return entries
+}
@@ -0,0 +1,61 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMalformedCommand(t *testing.T) {
+	tests := []struct {
+		input string
+		role  string
+		note  string
+	}{
+		{"/retry-with", roleError, "usage: /retry-with <model>"},
+		{"/savecode 1", roleError, "usage: /savecode <n> <file>"},
+		{"/clock on off", roleError, "usage: /clock [on|off]"},
+		{"/info now", roleError, "usage: /info"},
+		{"/unpin 1 2", roleError, "usage: /unpin [turn]"},
+		{"/untag", roleError, "usage: /untag <tag …>"},
+		{"/hlep", roleInfo, "Unknown command: /hlep. Did you mean /help?"},
+		{"/nosuchthing", roleInfo, "Unknown command: /nosuchthing (/help lists them)"},
+		{`/load "my chat`, roleError, `unterminated " quote`},
+		{"/export 'notes.md", roleError, "unterminated ' quote"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			next, cmd := newTestModel(t).runCommand(tt.input)
+			m := next.(Model)
+			if cmd != nil {
+				t.Error("the command ran")
+			}
+			if len(m.messages) != 1 {
+				t.Fatalf("messages = %+v, want one note", m.messages)
+			}
+			if got := m.messages[0]; got.role != tt.role || !strings.HasPrefix(got.content, tt.note) {
+				t.Errorf("note = %s %q, want %s %q", got.role, got.content, tt.role, tt.note)
+			}
+		})
+	}
+}
+
+func TestArity(t *testing.T) {
+	tests := []struct {
+		usage       string
+		least, most int
+	}{
+		{"/help", 0, 0},
+		{"/retry-with <model>", 1, 1},
+		{"/copy [all] [markdown|text]", 0, 2},
+		{"/savecode <n> <file>", 2, 2},
+		{"/json [<schema-file> [tool]|off]", 0, 2},
+		{"/toolchoice [auto|any|none|<tool>]", 0, 1},
+		{"/use <template> [key=value …]", 1, -1},
+	}
+	for _, tt := range tests {
+		least, most := arity(tt.usage)
+		if least != tt.least || most != tt.most {
+			t.Errorf("arity(%q) = %d, %d, want %d, %d", tt.usage, least, most, tt.least, tt.most)
+		}
+	}
+}
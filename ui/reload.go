@@ -0,0 +1,163 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bnema/cclui/api"
+	"github.com/bnema/cclui/config"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// clientKeys are the settings of the API client, which /reload-config
+// applies by replacing it.
+var clientKeys = map[string]bool{
+	"api_key": true, "base_url": true, "proxy": true, "ca_file": true,
+	"insecure_skip_verify": true, "anthropic_version": true,
+	"connect_timeout": true, "idle_timeout": true,
+}
+
+// startupKeys are the settings only read on startup; a change to them
+// waits for the next one.
+var startupKeys = map[string]bool{
+	"continue": true, "banner": true, "opener": true, "webhook_url": true, "webhook_timeout": true,
+}
+
+// reloadConfig handles /reload-config, which reads the configuration again
+// and applies what changed to the session. A changed request parameter
+// replaces the one set for the session, if any; the others stay as set.
+func (m Model) reloadConfig() (Model, tea.Cmd, error) {
+	if m.streaming {
+		return m, nil, fmt.Errorf("wait for the response to finish before reloading the config")
+	}
+	cfg, err := m.config.Reload()
+	if err != nil {
+		return m, nil, fmt.Errorf("config not reloaded: %w", err)
+	}
+	keys := m.config.Changed(cfg)
+	if len(keys) == 0 {
+		m.config = cfg
+		m.appendMessage(roleInfo, fmt.Sprintf("Reloaded %s: nothing changed.", cfg.File))
+		return m, nil, nil
+	}
+
+	changed := map[string]bool{}
+	for _, key := range keys {
+		changed[key] = true
+	}
+	params := m.params
+	req := cfg.Request()
+	if changed["model"] {
+		params.Model = req.Model
+	}
+	if changed["max_tokens"] {
+		params.MaxTokens = req.MaxTokens
+	}
+	if changed["system"] {
+		params.System = req.System
+	}
+	if changed["temperature"] {
+		params.Temperature = req.Temperature
+	}
+	if changed["top_p"] {
+		params.TopP = req.TopP
+	}
+	if changed["thinking_budget"] {
+		params.Thinking = req.Thinking
+	}
+	if changed["service_tier"] {
+		params.ServiceTier = req.ServiceTier
+	}
+	if changed["tools"] || changed["tool_choice"] || changed["disable_parallel_tool_use"] {
+		params.Tools, params.ToolChoice = req.Tools, req.ToolChoice
+	}
+	if err := checkThinking(params); err != nil {
+		return m, nil, fmt.Errorf("config not reloaded: with this session's parameters, %w", err)
+	}
+
+	before := describe(m.config)
+	after := describe(cfg)
+	var applied, pending []string
+	replaceClient := false
+	for _, key := range keys {
+		change := fmt.Sprintf("%s %s → %s", key, before[key], after[key])
+		if before[key] == after[key] {
+			change = fmt.Sprintf("%s %s, changed on disk", key, after[key])
+		}
+		if startupKeys[key] {
+			pending = append(pending, change)
+			continue
+		}
+		if clientKeys[key] {
+			if _, ok := m.client.(*api.Client); !ok {
+				pending = append(pending, change)
+				continue
+			}
+			replaceClient = true
+		}
+		applied = append(applied, change)
+	}
+
+	if replaceClient {
+		old := m.client.(*api.Client)
+		client := cfg.Client()
+		client.Logger = old.Logger
+		old.CloseIdleConnections()
+		m.client = client
+		m.idle = false
+	}
+	autosaveWasOff := m.config.AutosaveMinutes <= 0
+	m.params = params
+	m.config = cfg
+	m.roles = newRoleStyles(cfg.Roles)
+	panelColor := "5"
+	if c := cfg.Roles["system"].Color; c != "" {
+		panelColor = c
+	}
+	m.panelStyle = m.panelStyle.BorderForeground(lipgloss.Color(panelColor))
+	if vim := cfg.Editor == "vim"; vim != m.vim {
+		m.vim, m.vimInsert = vim, false
+	}
+	if changed["show_tokens_per_second"] {
+		m.showRate = cfg.ShowTokensPerSecond
+	}
+	var clock tea.Cmd
+	if changed["show_clock"] {
+		m.showClock = cfg.ShowClock
+		clock = m.clockTick()
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Reloaded %s:", cfg.File)
+	for _, change := range applied {
+		b.WriteString("\n  " + change)
+	}
+	if len(pending) > 0 {
+		b.WriteString("\nApplied on the next start:")
+		for _, change := range pending {
+			b.WriteString("\n  " + change)
+		}
+	}
+	m.appendMessage(roleInfo, b.String())
+
+	var cmd tea.Cmd
+	if autosaveWasOff {
+		cmd = m.autosaveTick()
+	}
+	return m, tea.Batch(cmd, clock), nil
+}
+
+// describe maps the key of each setting of cfg to its value as /config
+// shows it, on one line.
+func describe(cfg *config.Config) map[string]string {
+	values := map[string]string{}
+	for _, e := range cfg.Describe() {
+		v := truncate(strings.Join(strings.Fields(e.Value), " "), 40)
+		if v == "" {
+			v = `""`
+		}
+		values[e.Key] = v
+	}
+	return values
+}
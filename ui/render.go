@@ -0,0 +1,109 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/bnema/cclui/api"
+)
+
+// The types of content a reply is made of, each with its renderer.
+const (
+	// contentMarkdown is the text of a reply, shown as written with its
+	// tables aligned, images as placeholders and code blocks labelled.
+	contentMarkdown = "markdown"
+	// contentCode is the text of a reply under /code, only its code, and
+	// contentRaw its text exactly as written, after Ctrl+R.
+	contentCode     = "code"
+	contentRaw      = "raw"
+	contentThinking = "thinking"
+	contentToolUse  = "tool_use"
+)
+
+// content is a piece of a reply to render: its text, or the call for a
+// tool_use, along with the reply and its index in the transcript.
+type content struct {
+	text  string
+	tool  api.ToolUse
+	msg   message
+	index int
+	// block is the number of the next code block, advanced by the
+	// renderers that number the code blocks of their text.
+	block *int
+}
+
+// renderer renders one type of content of a reply.
+type renderer interface {
+	render(m Model, c content) string
+}
+
+// renderFunc adapts a function to renderer.
+type renderFunc func(m Model, c content) string
+
+func (f renderFunc) render(m Model, c content) string { return f(m, c) }
+
+// renderers holds the renderer of each type of content. Showing a type
+// differently, or a new one, is a matter of an entry here.
+var renderers = map[string]renderer{
+	contentMarkdown: renderFunc(renderMarkdown),
+	contentCode: renderFunc(func(m Model, c content) string {
+		return m.codeOnlyText(c.text, c.block)
+	}),
+	contentRaw: renderFunc(func(m Model, c content) string {
+		return c.text
+	}),
+	contentThinking: renderFunc(func(m Model, c content) string {
+		return m.renderThinking(c.text)
+	}),
+	contentToolUse: renderFunc(func(m Model, c content) string {
+		return m.codeLabelStyle.Render(fmt.Sprintf("⚙ %s %s", c.tool.Name, sanitize(string(c.tool.Input))))
+	}),
+}
+
+// renderMarkdown renders the text of a reply, along with the output of
+// post_process for it once it is complete.
+func renderMarkdown(m Model, c content) string {
+	text := formatTables(c.text, m.wrapWidth()-m.labelWidth(roleAssistant), m.config.WideTables == "clip")
+	text = m.imagePlaceholders(text)
+	text = m.labelCodeBlocks(text, c.block)
+	if c.msg.processed != "" && (m.replay == nil || m.replay.index != c.index) {
+		text = m.processedText(c.msg, text)
+	}
+	return text
+}
+
+// noText stands in for the text of a reply that has none.
+const noText = "(no text content)"
+
+// renderReply renders the reply at index i, text being its content as far
+// as it is shown, through the renderer of each of its parts. The thinking
+// goes above the label of the reply, and body next to it.
+func (m Model) renderReply(msg message, i int, text string, block *int) (thinking, body string) {
+	c := content{text: text, msg: msg, index: i, block: block}
+	if msg.thinking != "" {
+		c.text = msg.thinking
+		thinking = renderers[contentThinking].render(m, c) + "\n"
+	}
+
+	kind := contentMarkdown
+	switch {
+	case m.codeOnly:
+		kind = contentCode
+	case m.rawView:
+		kind = contentRaw
+	}
+	c.text = text
+	text = m.collapse(renderers[kind].render(m, c), i)
+	// A reply that ended without any text, only tool calls or nothing at
+	// all, says so rather than leave its label hanging.
+	if blank(msg.content) && msg.processed == "" && !m.arriving(i) {
+		text = m.codeLabelStyle.Render(noText)
+	}
+	for _, tool := range msg.tools {
+		c.tool = tool
+		text += "\n" + renderers[contentToolUse].render(m, c)
+	}
+	if footer := m.replyFooter(msg); footer != "" {
+		text += "\n" + m.codeLabelStyle.Render(footer)
+	}
+	return thinking, text
+}
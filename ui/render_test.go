@@ -0,0 +1,45 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bnema/cclui/api"
+)
+
+func TestReplyWithoutText(t *testing.T) {
+	tests := []struct {
+		name  string
+		parts []string
+	}{
+		{"no blocks", nil},
+		{"an empty text block", []string{""}},
+		{"white space", []string{" \n", "\t"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestModel(t)
+			m, events := startStream(t, m, "hi")
+			if tt.parts != nil {
+				m = text(t, m, events, tt.parts...)
+			}
+			if strings.Contains(m.renderMessages(), noText) {
+				t.Error("the reply says it has no text while it streams")
+			}
+			m = chunk(t, m, events, api.StreamEvent{Type: "message_delta", StopReason: "end_turn"})
+			m = update(t, m, streamDoneMsg{events: events})
+
+			if !strings.Contains(m.renderMessages(), noText) {
+				t.Errorf("the reply doesn't say it has no text:\n%s", m.renderMessages())
+			}
+			last := m.messages[len(m.messages)-1]
+			if last.role != roleInfo || !strings.HasPrefix(last.content, "The response has no text.") {
+				t.Errorf("last message = %s %q, want the note on the empty response", last.role, last.content)
+			}
+			m.appendMessage(roleUser, "hi again")
+			if context := m.contextMessages(); len(context) != 1 || context[0].Content != "hi again" {
+				t.Errorf("context = %+v, want the unanswered prompt left out", context)
+			}
+		})
+	}
+}
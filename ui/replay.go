@@ -0,0 +1,156 @@
+package ui
+
+import (
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	replayInterval = 30 * time.Millisecond
+	// replayStep is about how many bytes each tick reveals.
+	replayStep = 12
+	// revealInterval is the shortest tick of stream_reveal; faster rates
+	// reveal more words per tick.
+	revealInterval = 20 * time.Millisecond
+)
+
+// replayState is a /replay in progress: messages[index] is shown up to
+// shown bytes of its content. It also paces a streamed reply under
+// stream_reveal, then with words set to how many words each tick reveals,
+// every interval.
+type replayState struct {
+	id    int
+	index int
+	shown int
+
+	words    int
+	interval time.Duration
+}
+
+// replayTickMsg reveals the next part of the replay it was scheduled for.
+type replayTickMsg struct{ id int }
+
+func (r *replayState) tick() tea.Cmd {
+	interval, id := r.interval, r.id
+	if interval == 0 {
+		interval = replayInterval
+	}
+	return tea.Tick(interval, func(time.Time) tea.Msg {
+		return replayTickMsg{id: id}
+	})
+}
+
+// startReplay shows the last reply again as if it was streaming, from the
+// stored text.
+func (m Model) startReplay() (Model, tea.Cmd, error) {
+	if m.streaming {
+		return m, nil, fmt.Errorf("wait for the response to finish before replaying it")
+	}
+	index := -1
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].role == roleAssistant && m.messages[i].content != "" {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return m, nil, fmt.Errorf("no response to replay yet")
+	}
+
+	m.replay = &replayState{id: m.nextReplayID(), index: index}
+	m.refresh()
+	return m, m.replay.tick(), nil
+}
+
+func (m Model) nextReplayID() int {
+	if m.replay == nil {
+		return 1
+	}
+	return m.replay.id + 1
+}
+
+// startReveal paces the reply about to stream as stream_reveal says,
+// stream_reveal words per second, at most a tick every revealInterval.
+func (m Model) startReveal() (Model, tea.Cmd) {
+	if m.replay != nil {
+		m = m.stopReplay()
+	}
+	rate := m.config.StreamReveal
+	if rate <= 0 {
+		return m, nil
+	}
+	interval := max(time.Second/time.Duration(rate), revealInterval)
+	words := max(int(int64(rate)*int64(interval)/int64(time.Second)), 1)
+	m.replay = &replayState{id: m.nextReplayID(), index: m.replyIndex, words: words, interval: interval}
+	return m, m.replay.tick()
+}
+
+// live reports whether the paced reply is still streaming, so that
+// catching up with it doesn't end the reveal.
+func (m Model) live(r *replayState) bool {
+	return r.words > 0 && r.index == m.streamingReply()
+}
+
+// advanceReplay reveals the next piece of the replayed reply, ending the
+// replay once all of it is shown.
+func (m Model) advanceReplay(msg replayTickMsg) (Model, tea.Cmd) {
+	r := m.replay
+	if r == nil || r.id != msg.id {
+		return m, nil
+	}
+	if r.index >= len(m.messages) {
+		// The transcript changed under the replay.
+		return m.stopReplay(), nil
+	}
+
+	content := m.messages[r.index].content
+	var shown int
+	if r.words > 0 {
+		shown = skipWords(content, r.shown, r.words)
+	} else {
+		shown = min(r.shown+replayStep, len(content))
+		for shown < len(content) && !utf8.RuneStart(content[shown]) {
+			shown++
+		}
+	}
+
+	if shown >= len(content) && !m.live(r) {
+		m.replay = nil
+		m.refresh()
+		return m, nil
+	}
+	next := *r
+	next.shown = shown
+	m.replay = &next
+	m.refresh()
+	return m, next.tick()
+}
+
+// skipWords returns the offset in text n words past from, a word being a
+// run of non-space bytes with the spaces before it.
+func skipWords(text string, from, n int) int {
+	i := from
+	for ; n > 0 && i < len(text); n-- {
+		for i < len(text) && isSpaceByte(text[i]) {
+			i++
+		}
+		for i < len(text) && !isSpaceByte(text[i]) {
+			i++
+		}
+	}
+	return i
+}
+
+func isSpaceByte(b byte) bool {
+	return b == ' ' || b == '\n' || b == '\t' || b == '\r'
+}
+
+// stopReplay shows the replayed reply in full right away.
+func (m Model) stopReplay() Model {
+	m.replay = nil
+	m.refresh()
+	return m
+}
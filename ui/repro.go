@@ -0,0 +1,61 @@
+package ui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bnema/cclui/api"
+)
+
+// repro handles /repro [file], which shows the request that produced the
+// last reply as a curl command to send it again, or writes its body to
+// file. The key is left to the environment, never shown.
+func (m Model) repro(args []string) (Model, error) {
+	var req *api.MessageRequest
+	for i := len(m.messages) - 1; i >= 0 && req == nil; i-- {
+		if m.messages[i].role == roleAssistant {
+			req = m.messages[i].request
+			if req == nil {
+				return m, errors.New("the last response was not produced in this session, so its request is unknown")
+			}
+		}
+	}
+	if req == nil {
+		return m, errors.New("no response to reproduce yet")
+	}
+	body, err := json.MarshalIndent(req, "", "  ")
+	if err != nil {
+		return m, err
+	}
+
+	version := m.config.Version
+	if c, ok := m.client.(*api.Client); ok {
+		version = c.CurrentVersion()
+	}
+	curl := fmt.Sprintf("curl %s/v1/messages \\\n  --header \"x-api-key: $ANTHROPIC_API_KEY\" \\\n  --header \"anthropic-version: %s\" \\\n  --header \"content-type: application/json\" \\\n", m.config.BaseURL, version)
+
+	if len(args) == 0 {
+		m.showOverlay(fmt.Sprintf("The request of the last response (Esc closes this view):\n\n%s  --data-binary @- <<'JSON'\n%s\nJSON", curl, body))
+		return m, nil
+	}
+	path := args[0]
+	if filepath.Ext(path) == "" {
+		path += ".json"
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return m, err
+	}
+	if _, err := f.Write(append(body, '\n')); err != nil {
+		f.Close()
+		return m, err
+	}
+	if err := f.Close(); err != nil {
+		return m, err
+	}
+	m.appendMessage(roleInfo, fmt.Sprintf("Wrote the request of the last response to %s. Send it again with:\n%s  --data-binary @%s", path, curl, path))
+	return m, nil
+}
@@ -0,0 +1,177 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aymanbagabas/go-udiff"
+	"github.com/bnema/cclui/api"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	diffAddStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	diffDelStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	diffHunkStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("6"))
+)
+
+// retry drops the last reply and asks for a new one to the same prompt. The
+// dropped reply is kept so /diff can compare the two.
+func (m Model) retry() (tea.Model, tea.Cmd) {
+	if m.streaming {
+		m.appendMessage(roleError, "wait for the response to finish before retrying")
+		return m, nil
+	}
+
+	last := -1
+	for i, msg := range m.messages {
+		if msg.role == roleUser {
+			last = i
+		}
+	}
+	if last < 0 {
+		m.appendMessage(roleError, "nothing to retry yet")
+		return m, nil
+	}
+
+	for _, msg := range m.messages[last+1:] {
+		if msg.role == roleAssistant && msg.content != "" {
+			m.previousReply = msg.content
+		}
+	}
+	m.messages = m.messages[:last+1]
+	m.refresh()
+	return m, m.CallClaude()
+}
+
+// retryWith handles /retry-with <model>, which is /retry with the new reply
+// from another model, to compare the two with /diff. The following
+// requests still go to the session's model.
+func (m Model) retryWith(args []string) (tea.Model, tea.Cmd) {
+	if len(args) != 1 {
+		m.appendMessage(roleError, "usage: /retry-with <model>")
+		return m, nil
+	}
+	model := m.params.Model
+	m.params.Model = args[0]
+	retried, cmd := m.retry()
+	r := retried.(Model)
+	r.params.Model = model
+	return r, cmd
+}
+
+// failTurn records err as the end of the current turn, offering to retry
+// it.
+func (m *Model) failTurn(err error) {
+	msg := message{role: roleError, content: err.Error(), at: time.Now(), failed: true}
+	var apiErr *api.APIError
+	if errors.As(err, &apiErr) {
+		msg.requestID = apiErr.RequestID
+	}
+	m.messages = append(m.messages, msg)
+	m.refresh()
+}
+
+// lastRequestID returns the request-id of the last reply or failed
+// request, to quote to Anthropic support.
+func (m Model) lastRequestID() (string, error) {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if id := m.messages[i].requestID; id != "" {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("no request with a request-id yet")
+}
+
+// failedTurn returns the index of the error that ended the last turn, or -1
+// if the last turn did not fail. Only notes can follow it.
+func (m Model) failedTurn() int {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		switch msg := m.messages[i]; {
+		case msg.failed:
+			return i
+		case conversational(msg.role):
+			return -1
+		}
+	}
+	return -1
+}
+
+// canRetry reports whether the r key retries a failed turn. It is only
+// taken in the transcript, see scrollKey, while the input is empty.
+func (m Model) canRetry() bool {
+	return m.failedTurn() >= 0 && !m.streaming && m.textarea.Value() == ""
+}
+
+// retryFailed sends the prompt of a failed turn again, dropping the error
+// and whatever part of the reply arrived before it.
+func (m Model) retryFailed() (tea.Model, tea.Cmd) {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].role == roleUser {
+			m.messages = m.messages[:i+1]
+			break
+		}
+	}
+	m.refresh()
+	return m, m.CallClaude()
+}
+
+// lastReply returns the content of the latest assistant message.
+func (m Model) lastReply() string {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].role == roleAssistant {
+			return m.messages[i].content
+		}
+	}
+	return ""
+}
+
+// showDiff replaces the transcript with a diff between the reply replaced by
+// the last /retry and the current one, until dismissed with Esc or /diff.
+func (m Model) showDiff() (Model, error) {
+	if m.overlay {
+		m.overlay = false
+		m.refresh()
+		return m, nil
+	}
+	if m.streaming {
+		return m, fmt.Errorf("wait for the response to finish before comparing")
+	}
+	if m.previousReply == "" {
+		return m, fmt.Errorf("nothing to compare, use /retry first")
+	}
+
+	m.showOverlay(renderDiff(sanitize(m.previousReply), sanitize(m.lastReply())))
+	return m, nil
+}
+
+func renderDiff(before, after string) string {
+	if !strings.HasSuffix(before, "\n") {
+		before += "\n"
+	}
+	if !strings.HasSuffix(after, "\n") {
+		after += "\n"
+	}
+
+	diff := udiff.Unified("previous", "current", before, after)
+	if diff == "" {
+		return "The responses are identical. (Esc to close)"
+	}
+
+	lines := strings.Split(strings.TrimSuffix(diff, "\n"), "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		case strings.HasPrefix(line, "+"):
+			lines[i] = diffAddStyle.Render(line)
+		case strings.HasPrefix(line, "-"):
+			lines[i] = diffDelStyle.Render(line)
+		case strings.HasPrefix(line, "@@"):
+			lines[i] = diffHunkStyle.Render(line)
+		}
+	}
+	return strings.Join(lines, "\n") + "\n\n(Esc to close)"
+}
@@ -0,0 +1,24 @@
+package ui
+
+import (
+	"strings"
+	"unicode"
+)
+
+// sanitize makes text safe to put in the viewport. Invalid UTF-8 is replaced
+// with U+FFFD and control characters other than newline and tab are dropped,
+// which also defuses stray escape sequences that would corrupt the screen.
+func sanitize(s string) string {
+	s = strings.ToValidUTF8(s, "�")
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,346 @@
+package ui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bnema/cclui/api"
+	"github.com/bnema/cclui/config"
+)
+
+// savedVersion is the version of the conversation file format. Files
+// without a version predate it and are read as version 1. Version 2 added
+// Params.
+const savedVersion = 2
+
+// savedConversation is the on-disk form of the conversation tree. Only user
+// and assistant messages are kept; command output is not part of it.
+type savedConversation struct {
+	Version  int           `json:"version"`
+	Branches []savedBranch `json:"branches"`
+	Current  int           `json:"current"`
+	// Model, System and Params are the settings the conversation was held
+	// with.
+	Model  string       `json:"model,omitempty"`
+	System string       `json:"system,omitempty"`
+	Params *savedParams `json:"params,omitempty"`
+	// Tags are the tags of /tag, for /list to filter by.
+	Tags []string `json:"tags,omitempty"`
+	// Title is the title auto_title gave the conversation, for /list.
+	Title string `json:"title,omitempty"`
+}
+
+// savedParams are the request parameters and the /lang, /format and
+// /verbosity settings of a saved conversation.
+type savedParams struct {
+	MaxTokens   int           `json:"max_tokens,omitempty"`
+	Temperature *float64      `json:"temperature,omitempty"`
+	TopP        *float64      `json:"top_p,omitempty"`
+	Thinking    *api.Thinking `json:"thinking,omitempty"`
+	ServiceTier string        `json:"service_tier,omitempty"`
+	Lang        string        `json:"lang,omitempty"`
+	Format      string        `json:"format,omitempty"`
+	Verbosity   string        `json:"verbosity,omitempty"`
+}
+
+type savedBranch struct {
+	Name     string         `json:"name"`
+	Parent   int            `json:"parent"`
+	ForkAt   int            `json:"fork_at"`
+	Messages []savedMessage `json:"messages"`
+}
+
+type savedMessage struct {
+	Role     string        `json:"role"`
+	Content  string        `json:"content"`
+	Usage    api.Usage     `json:"usage"`
+	Thinking string        `json:"thinking,omitempty"`
+	Tools    []api.ToolUse `json:"tools,omitempty"`
+	// Blocks are the content blocks of a reply, see message.blocks.
+	Blocks []api.ContentBlock `json:"blocks,omitempty"`
+	Pinned bool               `json:"pinned,omitempty"`
+	Pruned bool               `json:"pruned,omitempty"`
+	// StopReason and StopSequence are why the API ended a reply.
+	StopReason   string `json:"stop_reason,omitempty"`
+	StopSequence string `json:"stop_sequence,omitempty"`
+	RequestID    string `json:"request_id,omitempty"`
+	// Model is the model that produced a reply.
+	Model string `json:"model,omitempty"`
+	// Processed is the output of post_process for a reply.
+	Processed string `json:"processed,omitempty"`
+	// At is when the message was added, if known.
+	At *time.Time `json:"at,omitempty"`
+}
+
+func savedMessageOf(msg message) savedMessage {
+	sm := savedMessage{Role: msg.role, Content: msg.content, Usage: msg.usage, Thinking: msg.thinking, Tools: msg.tools, Blocks: msg.blocks, Pinned: msg.pinned, Pruned: msg.pruned, StopReason: msg.stopReason, StopSequence: msg.stopSequence, RequestID: msg.requestID, Model: msg.model, Processed: msg.processed}
+	if !msg.at.IsZero() {
+		at := msg.at
+		sm.At = &at
+	}
+	return sm
+}
+
+func conversationPath(name string) (string, error) {
+	if err := config.ValidName(name); err != nil {
+		return "", fmt.Errorf("conversation: %w", err)
+	}
+	dir, err := config.ConversationsDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+func (m Model) snapshot() savedConversation {
+	m.branches[m.branch].messages = m.messages
+
+	out := savedConversation{
+		Version: savedVersion,
+		Current: m.branch,
+		Model:   m.params.Model,
+		System:  m.params.System,
+		Params: &savedParams{
+			MaxTokens:   m.params.MaxTokens,
+			Temperature: m.params.Temperature,
+			TopP:        m.params.TopP,
+			Thinking:    m.params.Thinking,
+			ServiceTier: m.params.ServiceTier,
+			Lang:        m.lang,
+			Format:      m.format,
+			Verbosity:   m.verbosity,
+		},
+		Tags:  m.tags,
+		Title: m.title,
+	}
+	for _, b := range m.branches {
+		sb := savedBranch{Name: b.name, Parent: b.parent, ForkAt: b.forkAt, Messages: []savedMessage{}}
+		for _, msg := range b.messages {
+			if conversational(msg.role) {
+				sb.Messages = append(sb.Messages, savedMessageOf(msg))
+			}
+		}
+		out.Branches = append(out.Branches, sb)
+	}
+	return out
+}
+
+func (m *Model) restore(c savedConversation) error {
+	if c.Version > savedVersion {
+		return fmt.Errorf("conversation file is version %d, this cclui reads up to %d; upgrade cclui to load it", c.Version, savedVersion)
+	}
+	if len(c.Branches) == 0 || c.Current < 0 || c.Current >= len(c.Branches) {
+		return fmt.Errorf("conversation file has no usable branches")
+	}
+	if err := checkBranches(c.Branches); err != nil {
+		return fmt.Errorf("conversation file: %w", err)
+	}
+
+	branches := make([]branch, 0, len(c.Branches))
+	for _, sb := range c.Branches {
+		b := branch{name: sb.Name, parent: sb.Parent, forkAt: sb.ForkAt}
+		for _, sm := range sb.Messages {
+			msg := message{role: sm.Role, content: sm.Content, usage: sm.Usage, thinking: sm.Thinking, tools: sm.Tools, blocks: sm.Blocks, pinned: sm.Pinned, pruned: sm.Pruned, stopReason: sm.StopReason, stopSequence: sm.StopSequence, requestID: sm.RequestID, model: sm.Model, processed: sm.Processed}
+			if sm.At != nil {
+				msg.at = *sm.At
+			}
+			b.messages = append(b.messages, msg)
+		}
+		branches = append(branches, b)
+	}
+
+	m.branches = branches
+	m.branch = c.Current
+	m.messages = branches[c.Current].messages
+	m.resetArchive()
+	m.tags = c.Tags
+	m.title, m.titledAs = c.Title, ""
+	if c.Model != "" {
+		m.params.Model = c.Model
+	}
+	if c.System != "" {
+		m.params.System = c.System
+	}
+	if p := c.Params; p != nil {
+		if p.MaxTokens > 0 {
+			m.params.MaxTokens = p.MaxTokens
+		}
+		m.params.Temperature = p.Temperature
+		m.params.TopP = p.TopP
+		m.params.Thinking = p.Thinking
+		m.params.ServiceTier = p.ServiceTier
+		m.lang = p.Lang
+		// A format this version doesn't know is dropped rather than sent
+		// without instructions.
+		if _, ok := formatInstructions[p.Format]; ok || p.Format == "" {
+			m.format = p.Format
+		}
+		if p.Verbosity == "" || m.verbosityLevel(p.Verbosity) != "" {
+			m.verbosity = p.Verbosity
+		}
+	}
+	return nil
+}
+
+// checkBranches reports a branch tree that listBranches and /switch could
+// not walk: a parent that is not a branch of the file, a loop of parents,
+// or a fork after the end of the parent.
+func checkBranches(branches []savedBranch) error {
+	for i, b := range branches {
+		if b.Parent == -1 {
+			continue
+		}
+		if b.Parent < 0 || b.Parent >= len(branches) {
+			return fmt.Errorf("branch %d has parent %d, which doesn't exist", i, b.Parent)
+		}
+		if b.ForkAt < 0 || b.ForkAt > savedTurns(branches[b.Parent].Messages) {
+			return fmt.Errorf("branch %d forks at turn %d, past the end of its parent", i, b.ForkAt)
+		}
+	}
+	// A chain of parents longer than the tree goes round in a loop.
+	for i := range branches {
+		for p, steps := i, 0; p != -1; p, steps = branches[p].Parent, steps+1 {
+			if steps > len(branches) {
+				return fmt.Errorf("branch %d is its own ancestor", i)
+			}
+		}
+	}
+	return nil
+}
+
+// savedTurns returns how many user turns messages contains, as turns does.
+func savedTurns(messages []savedMessage) int {
+	n := 0
+	for _, sm := range messages {
+		if sm.Role == roleUser {
+			n++
+		}
+	}
+	return n
+}
+
+// settingsSummary names the settings a loaded conversation runs with.
+func (m Model) settingsSummary() string {
+	parts := []string{m.params.Model, fmt.Sprintf("max_tokens %d", m.params.MaxTokens)}
+	if t := m.params.Temperature; t != nil {
+		parts = append(parts, fmt.Sprintf("temperature %g", *t))
+	}
+	if p := m.params.TopP; p != nil {
+		parts = append(parts, fmt.Sprintf("top_p %g", *p))
+	}
+	if t := m.params.Thinking; t != nil {
+		parts = append(parts, fmt.Sprintf("thinking %d", t.BudgetTokens))
+	}
+	if t := m.params.ServiceTier; t != "" {
+		parts = append(parts, "service_tier "+t)
+	}
+	if m.params.System != "" {
+		parts = append(parts, "system prompt")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// saveConversation writes the conversation tree to the conversations
+// directory and returns the file it wrote. The name is remembered for
+// -continue.
+func (m Model) saveConversation(name string) (string, error) {
+	if name == "" {
+		name = time.Now().Format("2006-01-02-150405")
+	}
+	path, err := conversationPath(name)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.MarshalIndent(m.snapshot(), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := config.EnsureDir(filepath.Dir(path)); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return "", err
+	}
+	return path, rememberSession(name)
+}
+
+// rememberSession records name as the conversation saved last.
+func rememberSession(name string) error {
+	path, err := config.LastSessionFile()
+	if err != nil {
+		return err
+	}
+	if err := config.EnsureDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(name+"\n"), 0o600)
+}
+
+// resume loads the conversation saved last. The session starts fresh, with a
+// note saying why, when there is none or it can't be read.
+func (m Model) resume() Model {
+	path, err := config.LastSessionFile()
+	if err != nil {
+		m.appendMessage(roleError, "can't continue: "+err.Error())
+		return m
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		m.appendMessage(roleInfo, "No saved conversation to continue, starting a new one.")
+		return m
+	}
+	if err != nil {
+		m.appendMessage(roleError, "can't continue: "+err.Error())
+		return m
+	}
+
+	name := strings.TrimSpace(string(data))
+	loaded, err := m.loadConversation(name)
+	if err != nil {
+		m.appendMessage(roleError, fmt.Sprintf("can't continue %q, starting a new conversation: %v", name, err))
+		return m
+	}
+	loaded.autosavedTurns = turns(loaded.messages)
+	loaded.appendMessage(roleInfo, fmt.Sprintf("Continuing %q with %s.", name, loaded.settingsSummary()))
+	return loaded
+}
+
+func (m Model) loadConversation(name string) (Model, error) {
+	if m.streaming {
+		return m, fmt.Errorf("wait for the response to finish before loading")
+	}
+	path, err := conversationPath(name)
+	if err != nil {
+		return m, err
+	}
+	m, err = m.loadFile(path)
+	// A titled conversation is saved back where it was loaded from, but
+	// not into an autosave file.
+	if err == nil && m.title != "" && !strings.HasPrefix(name, "autosave-") {
+		m.titledAs = name
+	}
+	return m, err
+}
+
+// loadFile replaces the conversation with the one saved at path.
+func (m Model) loadFile(path string) (Model, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return m, err
+	}
+	var c savedConversation
+	if err := json.Unmarshal(data, &c); err != nil {
+		return m, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if err := m.restore(c); err != nil {
+		return m, err
+	}
+	m.refresh()
+	return m, nil
+}
@@ -0,0 +1,108 @@
+package ui
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/bnema/cclui/api"
+)
+
+func TestSaveKeepsContentBlocks(t *testing.T) {
+	m := newTestModel(t)
+	m.params.Tools = []api.Tool{{Name: "search"}}
+	m.appendMessage(roleUser, "find owls")
+	m.appendMessage(roleAssistant, "Let me look.")
+	reply := &m.messages[len(m.messages)-1]
+	reply.thinking = "They want owls."
+	reply.tools = []api.ToolUse{{ID: "toolu_1", Name: "search", Input: json.RawMessage(`{"query":"owls"}`)}}
+	reply.blocks = []api.ContentBlock{
+		{Type: "thinking", Thinking: "They want owls.", Signature: "sig-1"},
+		{Type: "redacted_thinking", Data: "opaque"},
+		{Type: "text", Text: "Let me look."},
+		{Type: "tool_use", ID: "toolu_1", Name: "search", Input: json.RawMessage(`{"query":"owls"}`)},
+	}
+
+	data, err := json.MarshalIndent(m.snapshot(), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved savedConversation
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	loaded := newTestModel(t)
+	loaded.params.Tools = m.params.Tools
+	if err := loaded.restore(saved); err != nil {
+		t.Fatal(err)
+	}
+
+	got := loaded.messages[len(loaded.messages)-1]
+	if got.thinking != reply.thinking {
+		t.Errorf("thinking = %q, want %q", got.thinking, reply.thinking)
+	}
+	if len(got.tools) != 1 || got.tools[0].ID != "toolu_1" || compact(got.tools[0].Input) != `{"query":"owls"}` {
+		t.Errorf("tools = %+v", got.tools)
+	}
+	if len(got.blocks) != len(reply.blocks) || got.blocks[0].Signature != "sig-1" || got.blocks[1].Data != "opaque" {
+		t.Errorf("blocks = %+v", got.blocks)
+	}
+	// What goes back to the API is what it would have been before saving.
+	want, have := requestJSON(t, m), requestJSON(t, loaded)
+	if !strings.Contains(want, `"signature":"sig-1"`) {
+		t.Fatalf("context before saving has no signed thinking:\n%s", want)
+	}
+	if have != want {
+		t.Errorf("context after restore:\n%s\nwant\n%s", have, want)
+	}
+}
+
+// requestJSON is the context of m as the request sends it.
+func requestJSON(t *testing.T, m Model) string {
+	t.Helper()
+	data, err := json.Marshal(m.contextMessages())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRestoreChecksTheBranchTree(t *testing.T) {
+	turn := []savedMessage{{Role: roleUser, Content: "hi"}, {Role: roleAssistant, Content: "hello"}}
+	main := savedBranch{Name: "main", Parent: -1, Messages: turn}
+	tests := []struct {
+		name     string
+		branches []savedBranch
+		err      string
+	}{
+		{"a tree", []savedBranch{main, {Name: "b", Parent: 0, ForkAt: 1, Messages: turn}, {Name: "c", Parent: 1}}, ""},
+		{"a parent past the end", []savedBranch{main, {Name: "b", Parent: 2}}, "branch 1 has parent 2"},
+		{"a negative parent", []savedBranch{main, {Name: "b", Parent: -2}}, "branch 1 has parent -2"},
+		{"its own parent", []savedBranch{main, {Name: "b", Parent: 1}}, "branch 1 is its own ancestor"},
+		{"a loop", []savedBranch{{Name: "a", Parent: 1}, {Name: "b", Parent: 0}}, "branch 0 is its own ancestor"},
+		{"a fork past its parent", []savedBranch{main, {Name: "b", Parent: 0, ForkAt: 3}}, "branch 1 forks at turn 3"},
+		{"a fork past the last turn of its parent", []savedBranch{main, {Name: "b", Parent: 0, ForkAt: 2}}, "branch 1 forks at turn 2"},
+		{"a negative fork", []savedBranch{main, {Name: "b", Parent: 0, ForkAt: -1}}, "branch 1 forks at turn -1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestModel(t)
+			err := m.restore(savedConversation{Version: savedVersion, Branches: tt.branches})
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if list := m.listBranches(); !strings.Contains(list, "c (0 turns, from b after turn 0)") {
+					t.Errorf("branches:\n%s", list)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("error = %v, want %q", err, tt.err)
+			}
+			if len(m.branches) != 1 || len(m.messages) != 0 {
+				t.Error("the session changed before the file was refused")
+			}
+		})
+	}
+}
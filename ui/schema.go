@@ -0,0 +1,273 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// schema is a JSON schema, checked by validate. Only the keywords of
+// schemaKeywords are enforced; annotations such as title or format are
+// ignored, and schemas that rely on references are refused when loaded.
+type schema map[string]any
+
+// schemaKeywords are the validation keywords validate knows.
+var schemaKeywords = map[string]bool{
+	"type": true, "enum": true, "const": true,
+	"properties": true, "required": true, "additionalProperties": true,
+	"items": true, "minItems": true, "maxItems": true,
+	"minLength": true, "maxLength": true, "pattern": true,
+	"minimum": true, "maximum": true,
+	"allOf": true, "anyOf": true, "oneOf": true,
+}
+
+// readSchema reads the JSON schema in path.
+func readSchema(path string) (schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("%s is not a JSON schema: %w", path, err)
+	}
+	if err := checkSchema(s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// checkSchema refuses what validate can't enforce faithfully.
+func checkSchema(v any) error {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, sub := range v {
+			if key == "$ref" || key == "$defs" || key == "definitions" {
+				return fmt.Errorf("%s is not supported, inline the schemas it refers to", key)
+			}
+			if err := checkSchema(sub); err != nil {
+				return err
+			}
+		}
+	case schema:
+		return checkSchema(map[string]any(v))
+	case []any:
+		for _, sub := range v {
+			if err := checkSchema(sub); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validate checks value, as decoded by encoding/json, against s and
+// returns a description of each mismatch, prefixed by the JSON pointer of
+// the offending value.
+func (s schema) validate(value any) []string {
+	var errs []string
+	validateAt(s, value, "", &errs)
+	return errs
+}
+
+func validateAt(s map[string]any, value any, path string, errs *[]string) {
+	fail := func(format string, args ...any) {
+		at := path
+		if at == "" {
+			at = "/"
+		}
+		*errs = append(*errs, at+": "+fmt.Sprintf(format, args...))
+	}
+
+	if t, ok := s["type"]; ok && !matchesType(t, value) {
+		fail("want %s, got %s", typeNames(t), jsonType(value))
+		return
+	}
+	if enum, ok := s["enum"].([]any); ok && !contains(enum, value) {
+		fail("want one of %s, got %s", compact(enum), compact(value))
+	}
+	if c, ok := s["const"]; ok && !equalJSON(c, value) {
+		fail("want %s, got %s", compact(c), compact(value))
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		props, _ := s["properties"].(map[string]any)
+		if required, ok := s["required"].([]any); ok {
+			for _, r := range required {
+				if name, ok := r.(string); ok {
+					if _, ok := v[name]; !ok {
+						fail("missing required property %q", name)
+					}
+				}
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			at := path + "/" + name
+			if sub, ok := props[name].(map[string]any); ok {
+				validateAt(sub, v[name], at, errs)
+				continue
+			}
+			switch extra := s["additionalProperties"].(type) {
+			case bool:
+				if !extra {
+					fail("unexpected property %q", name)
+				}
+			case map[string]any:
+				validateAt(extra, v[name], at, errs)
+			}
+		}
+	case []any:
+		if n, ok := number(s["minItems"]); ok && float64(len(v)) < n {
+			fail("want at least %v items, got %d", n, len(v))
+		}
+		if n, ok := number(s["maxItems"]); ok && float64(len(v)) > n {
+			fail("want at most %v items, got %d", n, len(v))
+		}
+		if items, ok := s["items"].(map[string]any); ok {
+			for i, item := range v {
+				validateAt(items, item, fmt.Sprintf("%s/%d", path, i), errs)
+			}
+		}
+	case string:
+		length := float64(len([]rune(v)))
+		if n, ok := number(s["minLength"]); ok && length < n {
+			fail("want at least %v characters, got %v", n, length)
+		}
+		if n, ok := number(s["maxLength"]); ok && length > n {
+			fail("want at most %v characters, got %v", n, length)
+		}
+		if p, ok := s["pattern"].(string); ok {
+			if re, err := regexp.Compile(p); err == nil && !re.MatchString(v) {
+				fail("%q doesn't match the pattern %s", v, p)
+			}
+		}
+	case float64:
+		if n, ok := number(s["minimum"]); ok && v < n {
+			fail("want at least %v, got %v", n, v)
+		}
+		if n, ok := number(s["maximum"]); ok && v > n {
+			fail("want at most %v, got %v", n, v)
+		}
+	}
+
+	if all, ok := s["allOf"].([]any); ok {
+		for _, sub := range all {
+			if sub, ok := sub.(map[string]any); ok {
+				validateAt(sub, value, path, errs)
+			}
+		}
+	}
+	if anyOf, ok := s["anyOf"].([]any); ok && matching(anyOf, value) == 0 {
+		fail("doesn't match any of the anyOf schemas")
+	}
+	if oneOf, ok := s["oneOf"].([]any); ok {
+		if n := matching(oneOf, value); n != 1 {
+			fail("want exactly one of the oneOf schemas to match, %d do", n)
+		}
+	}
+}
+
+// matching counts the schemas of subs that value matches.
+func matching(subs []any, value any) int {
+	n := 0
+	for _, sub := range subs {
+		if sub, ok := sub.(map[string]any); ok {
+			var errs []string
+			validateAt(sub, value, "", &errs)
+			if len(errs) == 0 {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// matchesType reports whether value is of the type t names, a type name or
+// a list of them.
+func matchesType(t, value any) bool {
+	names, ok := t.([]any)
+	if !ok {
+		names = []any{t}
+	}
+	got := jsonType(value)
+	for _, name := range names {
+		switch {
+		case name == got:
+			return true
+		case name == "number" && got == "integer":
+			return true
+		}
+	}
+	return false
+}
+
+func typeNames(t any) string {
+	if names, ok := t.([]any); ok {
+		parts := make([]string, len(names))
+		for i, n := range names {
+			parts[i] = fmt.Sprint(n)
+		}
+		return strings.Join(parts, " or ")
+	}
+	return fmt.Sprint(t)
+}
+
+// jsonType is the JSON schema type of value, integer for whole numbers.
+func jsonType(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func number(v any) (float64, bool) {
+	f, ok := v.(float64)
+	return f, ok
+}
+
+func contains(values []any, value any) bool {
+	for _, v := range values {
+		if equalJSON(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func equalJSON(a, b any) bool {
+	return compact(a) == compact(b)
+}
+
+// compact renders v as JSON on one line. Maps are encoded with sorted keys,
+// so equal values render the same.
+func compact(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,297 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// selectable reports whether the message at i can be selected in the
+// transcript: prompts and replies still shown, not cclui's own notes.
+func (m Model) selectable(i int) bool {
+	if i < m.hiddenMessages() || i >= len(m.messages) {
+		return false
+	}
+	role := m.messages[i].role
+	return conversational(role)
+}
+
+// selectedMessage returns the index of the selected message, or -1 when
+// there is none.
+func (m Model) selectedMessage() int {
+	if !m.scrolling || !m.selectable(m.selected) {
+		return -1
+	}
+	return m.selected
+}
+
+// moveSelection selects the next selectable message in direction dir, 1
+// for down and -1 for up. Without a selection the latest message is
+// selected whichever the direction, as that is where the transcript ends.
+func (m Model) moveSelection(dir int) Model {
+	i := m.selectedMessage()
+	if i < 0 {
+		i, dir = len(m.messages), -1
+	}
+	for j := i + dir; j >= 0 && j < len(m.messages); j += dir {
+		if m.selectable(j) {
+			m.selected = j
+			m.activeCode = 0
+			break
+		}
+	}
+	m.refresh()
+	return m
+}
+
+// messageOffsets returns the viewport line each message starts at, and as
+// its last element the number of lines of the transcript.
+func (m Model) messageOffsets() []int {
+	offsets := make([]int, 0, len(m.messages)+1)
+	line := 0
+	hidden := m.hiddenMessages()
+	if hidden > 0 {
+		line = lineCount(m.archiveNote(hidden), m.wrapWidth())
+	}
+	for i, block := range m.renderBlocks() {
+		offsets = append(offsets, line)
+		if i >= hidden {
+			line += lineCount(block, m.wrapWidth())
+		}
+	}
+	return append(offsets, line)
+}
+
+// topMessage returns the index of the message at the top of the viewport.
+func (m Model) topMessage() int {
+	offsets := m.messageOffsets()
+	top := 0
+	for i := range m.messages {
+		if offsets[i] <= m.viewport.YOffset {
+			top = i
+		}
+	}
+	return top
+}
+
+// lineCount is the number of lines text takes once wrapped at width.
+func lineCount(text string, width int) int {
+	return strings.Count(wrap(text, width), "\n") + 1
+}
+
+// showSelected scrolls the viewport just enough for the selected message to
+// be in view, from its first line if it is taller than the viewport.
+func (m *Model) showSelected() {
+	i := m.selectedMessage()
+	if i < 0 {
+		return
+	}
+	offsets := m.messageOffsets()
+	top, bottom := offsets[i], offsets[i+1]
+	switch {
+	case top < m.viewport.YOffset || bottom-top > m.viewport.Height:
+		m.viewport.SetYOffset(top)
+	case bottom > m.viewport.YOffset+m.viewport.Height:
+		m.viewport.SetYOffset(bottom - m.viewport.Height)
+	}
+}
+
+// turnOf returns the turn the message at i belongs to, counting from 1.
+func (m Model) turnOf(i int) int {
+	n := 0
+	for _, msg := range m.messages[:i+1] {
+		if msg.role == roleUser {
+			n++
+		}
+	}
+	return n
+}
+
+// selectionKey handles the keys that act on the selected message while
+// the transcript has the focus. It reports false for the keys it leaves to
+// scrolling.
+func (m Model) selectionKey(key tea.KeyMsg) (tea.Model, tea.Cmd, bool) {
+	switch key.String() {
+	case "up", "k":
+		return m.moveSelection(-1), nil, true
+	case "down", "j":
+		return m.moveSelection(1), nil, true
+	}
+
+	i := m.selectedMessage()
+	if i < 0 {
+		return m, nil, false
+	}
+	switch key.String() {
+	case "y":
+		if first, blocks := m.replyCodeBlocks(i); m.activeCode >= first && m.activeCode < first+len(blocks) {
+			n, code := m.activeCode, blocks[m.activeCode-first].code
+			return m, m.recovering(writeClipboard(fmt.Sprintf("code block #%d", n), func() string { return code }), commandFailed), true
+		}
+		content := m.messages[i].content
+		return m, m.recovering(writeClipboard("the message", func() string { return content }), commandFailed), true
+	case "c", "C":
+		dir := 1
+		if key.String() == "C" {
+			dir = -1
+		}
+		model, cmd := m.stepCode(i, dir)
+		return model, cmd, true
+	case "p":
+		turn := m.turnOf(i)
+		if turn == 0 {
+			return m, nil, true
+		}
+		m, _ = m.setPinned([]string{fmt.Sprint(turn)}, !m.messages[i].pinned)
+		return m, nil, true
+	case "b":
+		model, cmd := m.branchAtSelection(i)
+		return model, cmd, true
+	case "r":
+		model, cmd := m.regenerate(i)
+		return model, cmd, true
+	case "d", "D":
+		return m.confirmDelete(i, key.String() == "D"), nil, true
+	case "e":
+		if m.messages[i].role == roleAssistant {
+			m.messages[i].expanded = !m.messages[i].expanded
+			m.refresh()
+		}
+		return m, nil, true
+	}
+	return m, nil, false
+}
+
+// branchAtSelection forks the conversation before the turn of the selected
+// message, as /branch does, and gives the focus back to the input to take a
+// different prompt.
+func (m Model) branchAtSelection(i int) (tea.Model, tea.Cmd) {
+	turn := m.turnOf(i)
+	m, err := m.branchCommand([]string{fmt.Sprint(max(turn-1, 0))})
+	if err != nil {
+		m.appendMessage(roleError, err.Error())
+		return m, nil
+	}
+	return m.toggleFocus(), nil
+}
+
+// regenerate asks for a new reply to the prompt of the selected message's
+// turn. The last turn is retried in place, as with /retry; an earlier one
+// in a new branch, so that the turns after it are kept in the current one.
+func (m Model) regenerate(i int) (tea.Model, tea.Cmd) {
+	if m.streaming {
+		m.appendMessage(roleError, "wait for the response to finish before regenerating")
+		return m, nil
+	}
+	turn := m.turnOf(i)
+	if turn == 0 {
+		return m, nil
+	}
+	m = m.toggleFocus()
+	if turn == turns(m.messages) {
+		return m.retry()
+	}
+
+	var prompt message
+	for j := i; j >= 0; j-- {
+		if m.messages[j].role == roleUser {
+			prompt = m.messages[j]
+			break
+		}
+	}
+	m.fork(turn - 1)
+	m.appendMessage(roleInfo, fmt.Sprintf("Regenerating turn %d on %s; the later turns stay on %s.", turn, m.branches[m.branch].name, m.branches[m.branches[m.branch].parent].name))
+	m.messages = append(m.messages, message{role: roleUser, content: prompt.content, at: time.Now(), pinned: prompt.pinned})
+	m.refresh()
+	return m, m.CallClaude()
+}
+
+// confirmDelete asks before deleting the selected message, or with turn set
+// its whole turn. A prompt always goes with its turn, as a reply left
+// without its prompt would answer the one before.
+func (m Model) confirmDelete(i int, turn bool) Model {
+	if m.streaming {
+		m.appendMessage(roleError, "wait for the response to finish before deleting")
+		return m
+	}
+	turn = turn || m.messages[i].role == roleUser
+	what := "this reply"
+	if turn {
+		what = fmt.Sprintf("turn %d", m.turnOf(i))
+	}
+	return m.ask("Delete "+what+" from the transcript and the context?", func(m Model) (tea.Model, tea.Cmd) {
+		from, to := i, i+1
+		if turn {
+			from, to = m.turnBounds(i)
+		}
+		m.messages = append(m.messages[:from:from], m.messages[to:]...)
+		m.archiveDeleted(from, to)
+		m.selected = -1
+		if m.selectable(from) {
+			m.selected = from
+		} else if m.selectable(from - 1) {
+			m.selected = from - 1
+		}
+		m.refresh()
+		return m.flash("Deleted " + what)
+	})
+}
+
+// turnBounds returns the range of messages of the turn the message at i
+// belongs to: from its prompt, or the start of the transcript, up to the
+// next prompt.
+func (m Model) turnBounds(i int) (from, to int) {
+	from = i
+	for from > 0 && m.messages[from].role != roleUser {
+		from--
+	}
+	to = i + 1
+	for to < len(m.messages) && m.messages[to].role != roleUser {
+		to++
+	}
+	return from, to
+}
+
+// replyCodeBlocks returns the code blocks of the reply at i and the number
+// of the first, as labelCodeBlocks numbers them. Prompts have none.
+func (m Model) replyCodeBlocks(i int) (first int, blocks []codeBlock) {
+	first = 1
+	for _, msg := range m.messages[:i] {
+		if msg.role == roleAssistant {
+			first += len(parseCodeBlocks(msg.content))
+		}
+	}
+	if m.messages[i].role != roleAssistant {
+		return first, nil
+	}
+	return first, parseCodeBlocks(m.messages[i].content)
+}
+
+// stepCode handles c and C, which step through the code blocks of the
+// selected reply at i, in direction dir, going round. y then copies the
+// block stepped to rather than the whole reply.
+func (m Model) stepCode(i, dir int) (Model, tea.Cmd) {
+	first, blocks := m.replyCodeBlocks(i)
+	if len(blocks) == 0 {
+		return m.flash("No code blocks in this message")
+	}
+	k := m.activeCode - first
+	switch {
+	case k < 0 || k >= len(blocks):
+		k = 0
+		if dir < 0 {
+			k = len(blocks) - 1
+		}
+	default:
+		k = (k + dir + len(blocks)) % len(blocks)
+	}
+	m.activeCode = first + k
+	m.refresh()
+	label := fmt.Sprintf("Code #%d, %d of %d", m.activeCode, k+1, len(blocks))
+	if lang := blocks[k].lang; lang != "" {
+		label += " (" + lang + ")"
+	}
+	return m.flash(label + ": y copies it")
+}
@@ -0,0 +1,102 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// shellMsg reports the outcome of a ! or !! command.
+type shellMsg struct {
+	command string
+	output  string
+	err     error
+	// attach is set for !!, whose output goes out with the next message.
+	attach bool
+}
+
+// isShell reports whether input is a shell line for runShell. Without
+// allow_shell none is: a message starting with ! goes to Claude as typed.
+func (m Model) isShell(input string) bool {
+	return m.config.AllowShell && strings.HasPrefix(strings.TrimSpace(input), "!")
+}
+
+// runShell handles !<command>, which runs command in the shell and shows
+// its output, and !!<command>, which also attaches the output to the next
+// message.
+func (m Model) runShell(input string) (tea.Model, tea.Cmd) {
+	line := strings.TrimSpace(input)
+	attach := strings.HasPrefix(line, "!!")
+	command := strings.TrimSpace(strings.TrimLeft(line, "!"))
+	if command == "" {
+		m.appendMessage(roleError, "usage: !<command>, or !!<command> to send its output with the next message")
+		return m, nil
+	}
+
+	m.appendMessage(roleInfo, "$ "+command)
+	return m, m.recovering(func() tea.Msg {
+		output, err := runShellCommand(command)
+		return shellMsg{command: command, output: output, err: err, attach: attach}
+	}, commandFailed)
+}
+
+// runShellCommand runs command with sh, or cmd on Windows, and returns what
+// it printed on stdout and stderr, capped like the output of /runcode.
+func runShellCommand(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), runTimeout)
+	defer cancel()
+
+	var out cappedOutput
+	cmd := shellCommand(ctx, command)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	cmd.WaitDelay = runWaitDelay
+	err := cmd.Run()
+	if errors.Is(err, exec.ErrWaitDelay) {
+		// The command exited; a child left running in the background,
+		// as with cmd &, doesn't make it fail.
+		err = nil
+	}
+	return out.String(), err
+}
+
+// shellCommand runs command with sh, or cmd on Windows.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// shellDone shows the output of a shell command, and for !! keeps it to be
+// sent with the next message.
+func (m Model) shellDone(msg shellMsg) Model {
+	output := strings.TrimRight(msg.output, "\n")
+	result := output
+	if msg.err != nil {
+		result += fmt.Sprintf("\n(%v)", msg.err)
+	}
+	m.appendMessage(roleInfo, result)
+
+	if msg.attach {
+		m.attached = append(m.attached, fmt.Sprintf("Output of `%s`:\n%s", msg.command, fenced("", output)))
+		m.appendMessage(roleInfo, "The output will be sent with the next message (Esc with the input empty drops it).")
+	}
+	return m
+}
+
+// withAttached prepends the output attached with !! to input, marked as
+// such, and clears it.
+func (m *Model) withAttached(input string) string {
+	if len(m.attached) == 0 {
+		return input
+	}
+	input = strings.Join(m.attached, "\n\n") + "\n\n" + input
+	m.attached = nil
+	return input
+}
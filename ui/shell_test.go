@@ -0,0 +1,68 @@
+package ui
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestBangLine(t *testing.T) {
+	tests := []struct {
+		name       string
+		allowShell bool
+		input      string
+		role       string
+		content    string
+	}{
+		{"is a message without allow_shell", false, "!important: read this", roleUser, "!important: read this"},
+		{"runs with allow_shell", true, "!!", roleError, "usage: !<command>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestModel(t)
+			m.config.AllowShell = tt.allowShell
+			m.textarea.SetValue(tt.input)
+			m = update(t, m, tea.KeyMsg{Type: tea.KeyEnter})
+			if len(m.messages) == 0 {
+				t.Fatal("the line was dropped")
+			}
+			got := m.messages[0]
+			if got.role != tt.role || !strings.HasPrefix(got.content, tt.content) {
+				t.Errorf("first message = %s %q, want %s %q", got.role, got.content, tt.role, tt.content)
+			}
+		})
+	}
+}
+
+func TestCappedOutput(t *testing.T) {
+	var out cappedOutput
+	chunk := strings.Repeat("y\n", 1000)
+	for written := 0; written < 4*runOutputCap; written += len(chunk) {
+		if n, err := out.Write([]byte(chunk)); n != len(chunk) || err != nil {
+			t.Fatalf("Write = %d, %v, want the whole chunk taken", n, err)
+		}
+	}
+	if out.buf.Len() != runOutputCap {
+		t.Errorf("kept %d bytes, want %d", out.buf.Len(), runOutputCap)
+	}
+	if !strings.HasSuffix(out.String(), "\n[output truncated]") {
+		t.Error("the output isn't marked as truncated")
+	}
+}
+
+func TestShellCommandLeavingAChild(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	start := time.Now()
+	output, err := runShellCommand("sleep 10 & echo started")
+	if err != nil || strings.TrimSpace(output) != "started" {
+		t.Errorf("output = %q, %v", output, err)
+	}
+	if took := time.Since(start); took > 5*time.Second {
+		t.Errorf("took %v, waiting on the child", took)
+	}
+}
@@ -0,0 +1,185 @@
+package ui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/bnema/cclui/api"
+	"github.com/bnema/cclui/config"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// statsFile is the part of a saved conversation /stats reads; the rest,
+// the text above all, is skipped while decoding.
+type statsFile struct {
+	Version  int    `json:"version"`
+	Model    string `json:"model"`
+	Branches []struct {
+		ForkAt   int `json:"fork_at"`
+		Messages []struct {
+			Role  string    `json:"role"`
+			Usage api.Usage `json:"usage"`
+			Model string    `json:"model"`
+		} `json:"messages"`
+	} `json:"branches"`
+}
+
+// fileStats is what /stats adds up for a saved conversation.
+type fileStats struct {
+	turns int
+	// replies and usage are by model.
+	replies map[string]int
+	usage   map[string]api.Usage
+}
+
+// readStats reads the stats of the saved conversation at path. The turns a
+// branch shares with its parent are only counted once, with the parent.
+func readStats(path string) (fileStats, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return fileStats{}, err
+	}
+	defer f.Close()
+	var c statsFile
+	if err := json.NewDecoder(f).Decode(&c); err != nil {
+		return fileStats{}, err
+	}
+	if c.Version > savedVersion {
+		return fileStats{}, fmt.Errorf("version %d", c.Version)
+	}
+
+	s := fileStats{replies: map[string]int{}, usage: map[string]api.Usage{}}
+	for _, b := range c.Branches {
+		seen := 0
+		for _, msg := range b.Messages {
+			if msg.Role == roleUser {
+				seen++
+			}
+			if seen <= b.ForkAt {
+				continue
+			}
+			switch msg.Role {
+			case roleUser:
+				s.turns++
+			case roleAssistant:
+				model := msg.Model
+				if model == "" {
+					model = c.Model
+				}
+				if model == "" {
+					model = "unknown model"
+				}
+				u := s.usage[model]
+				u.InputTokens += msg.Usage.InputTokens
+				u.OutputTokens += msg.Usage.OutputTokens
+				s.usage[model] = u
+				s.replies[model]++
+			}
+		}
+	}
+	return s, nil
+}
+
+// usageStats handles /stats, which adds up the turns, tokens and cost of
+// all the saved conversations, by model. The files are read in the
+// background, several at a time.
+func (m Model) usageStats() tea.Cmd {
+	price := m.price
+	return m.recovering(func() tea.Msg {
+		dir, err := config.ConversationsDir()
+		if err != nil {
+			return commandFailed(err)
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return commandFailed(err)
+		}
+		var paths []string
+		for _, e := range entries {
+			if !e.IsDir() && filepath.Ext(e.Name()) == ".json" {
+				paths = append(paths, filepath.Join(dir, e.Name()))
+			}
+		}
+		if len(paths) == 0 {
+			return commandOutputMsg("No saved conversations yet; /save writes one.")
+		}
+
+		var (
+			mu      sync.Mutex
+			wg      sync.WaitGroup
+			total   = fileStats{replies: map[string]int{}, usage: map[string]api.Usage{}}
+			read    int
+			skipped int
+		)
+		work := make(chan string)
+		for range min(runtime.NumCPU(), len(paths)) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for path := range work {
+					s, err := readStats(path)
+					mu.Lock()
+					if err != nil {
+						skipped++
+					} else {
+						read++
+						total.turns += s.turns
+						for model, u := range s.usage {
+							t := total.usage[model]
+							t.InputTokens += u.InputTokens
+							t.OutputTokens += u.OutputTokens
+							total.usage[model] = t
+							total.replies[model] += s.replies[model]
+						}
+					}
+					mu.Unlock()
+				}
+			}()
+		}
+		for _, path := range paths {
+			work <- path
+		}
+		close(work)
+		wg.Wait()
+		return commandOutputMsg(statsReport(total, read, skipped, price))
+	}, commandFailed)
+}
+
+// statsReport formats the stats of read saved conversations.
+func statsReport(total fileStats, read, skipped int, price func(string) (config.Price, bool)) string {
+	models := make([]string, 0, len(total.usage))
+	var all api.Usage
+	for model, u := range total.usage {
+		models = append(models, model)
+		all.InputTokens += u.InputTokens
+		all.OutputTokens += u.OutputTokens
+	}
+	sort.Strings(models)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Saved conversations: %d", read)
+	if skipped > 0 {
+		fmt.Fprintf(&b, ", and %d files that could not be read as one", skipped)
+	}
+	fmt.Fprintf(&b, "\nTurns: %d\nTokens: %s in / %s out", total.turns, shortCount(all.InputTokens), shortCount(all.OutputTokens))
+	var cost float64
+	for _, model := range models {
+		u := total.usage[model]
+		fmt.Fprintf(&b, "\n  %s: %d replies, %s in / %s out", model, total.replies[model], shortCount(u.InputTokens), shortCount(u.OutputTokens))
+		if p, ok := price(model); ok {
+			fmt.Fprintf(&b, ", $%.4f", costOf(p, u))
+			cost += costOf(p, u)
+		} else {
+			b.WriteString(", price unknown")
+		}
+	}
+	fmt.Fprintf(&b, "\nTotal: $%.4f (estimate, for the replies kept in the files)", cost)
+	return b.String()
+}
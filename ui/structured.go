@@ -0,0 +1,149 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bnema/cclui/api"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// structuredTool is the tool Claude is made to call in the tool variant of
+// /json, with the response as its input.
+const structuredTool = "respond_json"
+
+// structuredMode is the schema replies must match while /json is on.
+type structuredMode struct {
+	path   string
+	schema schema
+	// tool coerces the reply with a forced call of structuredTool rather
+	// than instructions in the system prompt.
+	tool bool
+}
+
+// setStructured handles /json [<schema-file> [tool]|off]. Without an
+// argument it shows the current setting.
+func (m Model) setStructured(args []string) (Model, error) {
+	switch {
+	case len(args) == 0:
+		if m.structured == nil {
+			m.appendMessage(roleInfo, "Responses are not checked against a schema.")
+		} else {
+			m.appendMessage(roleInfo, "Responses must match "+m.structured.path+".")
+		}
+		return m, nil
+	case len(args) == 1 && args[0] == "off":
+		m.structured = nil
+		m.appendMessage(roleInfo, "Responses are no longer checked against a schema.")
+		return m, nil
+	case len(args) > 2 || (len(args) == 2 && args[1] != "tool"):
+		return m, fmt.Errorf("usage: /json <schema-file> [tool], or /json off")
+	}
+
+	s, err := readSchema(args[0])
+	if err != nil {
+		return m, err
+	}
+	mode := &structuredMode{path: args[0], schema: s, tool: len(args) == 2}
+	if mode.tool {
+		if s["type"] != "object" {
+			return m, fmt.Errorf("the tool variant needs a schema of type object, as tool inputs are objects")
+		}
+		if m.params.Thinking != nil {
+			return m, fmt.Errorf("the tool variant can't be used with extended thinking, which doesn't allow forcing a tool")
+		}
+	}
+	m.structured = mode
+	m.appendMessage(roleInfo, fmt.Sprintf("Responses must match %s; one that doesn't is sent back for correction (json_retries: %d).", mode.path, m.config.JSONRetries))
+	return m, nil
+}
+
+// structuredInstructions are added to the system prompt by /json, unless
+// the tool variant coerces the reply instead.
+func (m Model) structuredInstructions() string {
+	if m.structured == nil || m.structured.tool {
+		return ""
+	}
+	return "Respond with a single JSON value matching this JSON schema, without any text or code fences around it:\n" + compact(map[string]any(m.structured.schema))
+}
+
+// structuredRequest adds the forced tool call of the tool variant of /json
+// to req.
+func (m Model) structuredRequest(req api.MessageRequest) api.MessageRequest {
+	if m.structured == nil || !m.structured.tool {
+		return req
+	}
+	req.Tools = append(append([]api.Tool(nil), req.Tools...), api.Tool{
+		Name:        structuredTool,
+		Description: "Return the response as JSON matching the input schema.",
+		InputSchema: json.RawMessage(compact(map[string]any(m.structured.schema))),
+	})
+	req.ToolChoice = &api.ToolChoice{Type: "tool", Name: structuredTool}
+	return req
+}
+
+// structuredReply takes the input of the forced tool call of /json as the
+// text of the reply, so it is shown, checked and kept in the context like
+// any other. It reports false for other tool calls.
+func (m Model) structuredReply(last *message, use api.ToolUse) bool {
+	if m.structured == nil || !m.structured.tool || use.Name != structuredTool {
+		return false
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, use.Input, "", "  "); err != nil {
+		out.Reset()
+		out.Write(use.Input)
+	}
+	last.content += out.String()
+	return true
+}
+
+// checkStructured validates the reply that just ended against the schema
+// of /json. A reply that doesn't match is sent back with the mismatches
+// while retries are left.
+func (m Model) checkStructured() (Model, tea.Cmd) {
+	reply := m.lastReply()
+	if m.structured == nil || reply == "" {
+		return m, nil
+	}
+
+	var errs []string
+	value, err := parseJSONReply(reply)
+	if err != nil {
+		errs = []string{"not valid JSON: " + err.Error()}
+	} else {
+		errs = m.structured.schema.validate(value)
+	}
+	name := filepath.Base(m.structured.path)
+	if len(errs) == 0 {
+		m.appendMessage(roleInfo, "✓ The response matches "+name+".")
+		return m, nil
+	}
+
+	list := "- " + strings.Join(errs, "\n- ")
+	if m.structuredRetries <= 0 {
+		m.appendMessage(roleError, fmt.Sprintf("The response doesn't match %s:\n%s", name, list))
+		return m, nil
+	}
+	m.structuredRetries--
+	m.appendMessage(roleError, fmt.Sprintf("The response doesn't match %s, asking again (%d retries left):\n%s", name, m.structuredRetries, list))
+	m.appendMessage(roleUser, "Your response does not match the JSON schema:\n"+list+"\nRespond again with only the corrected JSON.")
+	return m, m.CallClaude()
+}
+
+// parseJSONReply decodes a reply that should be JSON, tolerating a code
+// fence around it.
+func parseJSONReply(reply string) (any, error) {
+	text := strings.TrimSpace(reply)
+	if strings.HasPrefix(text, "```") {
+		if i := strings.IndexByte(text, '\n'); i >= 0 {
+			text = strings.TrimSuffix(strings.TrimSpace(text[i+1:]), "```")
+		}
+	}
+	var value any
+	err := json.Unmarshal([]byte(text), &value)
+	return value, err
+}
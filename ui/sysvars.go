@@ -0,0 +1,57 @@
+package ui
+
+import (
+	"os"
+	"os/user"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/bnema/cclui/config"
+	"github.com/bnema/cclui/prompts"
+)
+
+// builtinVars are the variables every system prompt can use, each read as
+// the prompt is sent. None changes more often than daily, so that the
+// prompt stays the same from one request to the next.
+var builtinVars = map[string]func() string{
+	"cwd":  func() string { dir, _ := os.Getwd(); return dir },
+	"date": func() string { return time.Now().Format("2006-01-02") },
+	"os":   func() string { return runtime.GOOS },
+	"arch": func() string { return runtime.GOARCH },
+	"user": func() string {
+		if u, err := user.Current(); err == nil {
+			return u.Username
+		}
+		return ""
+	},
+	"hostname": func() string { name, _ := os.Hostname(); return name },
+	"shell":    func() string { return os.Getenv("SHELL") },
+}
+
+// ExpandSystem fills in the {{variables}} of the system prompt system: the
+// built-in ones and those of system_vars in cfg, which win over them. Other
+// {{…}} are left as they are.
+func ExpandSystem(cfg *config.Config, system string) string {
+	if !strings.Contains(system, "{{") {
+		return system
+	}
+	values := make(map[string]string, len(builtinVars)+len(cfg.SystemVars))
+	for name, get := range builtinVars {
+		values[name] = get()
+	}
+	for name, value := range cfg.SystemVars {
+		values[name] = value
+	}
+	return prompts.Fill(system, values)
+}
+
+// showSystem is /system: the system prompt as it is sent, variables filled
+// in and the instructions of /lang, /format, /verbosity and /json added.
+func (m Model) showSystem() string {
+	system := m.systemPrompt()
+	if system == "" {
+		return "No system prompt."
+	}
+	return "System prompt as sent:\n\n" + system
+}
@@ -0,0 +1,181 @@
+package ui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bnema/cclui/config"
+)
+
+// normalizeTag is how a tag is kept: lowercase, without the leading # it
+// may be typed with. Tags are flat, so "work/review" is just a tag.
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(tag, "#"))
+}
+
+// formatTags shows tags the way they can be typed, as #tag.
+func formatTags(tags []string) string {
+	out := make([]string, len(tags))
+	for i, tag := range tags {
+		out[i] = "#" + tag
+	}
+	return strings.Join(out, " ")
+}
+
+// tag handles /tag, which adds tags to the conversation, or shows them
+// without arguments. They are saved with it.
+func (m Model) tag(args []string) (Model, error) {
+	for _, arg := range args {
+		tag := normalizeTag(arg)
+		if tag == "" {
+			return m, fmt.Errorf("usage: /tag <tag> …")
+		}
+		if !slices.Contains(m.tags, tag) {
+			m.tags = append(m.tags, tag)
+		}
+	}
+	sort.Strings(m.tags)
+	m.appendMessage(roleInfo, m.tagsNote())
+	return m, nil
+}
+
+// untag handles /untag, which removes tags from the conversation.
+func (m Model) untag(args []string) (Model, error) {
+	if len(args) == 0 {
+		return m, fmt.Errorf("usage: /untag <tag> …")
+	}
+	for _, arg := range args {
+		tag := normalizeTag(arg)
+		i := slices.Index(m.tags, tag)
+		if i < 0 {
+			return m, fmt.Errorf("the conversation isn't tagged %q", tag)
+		}
+		m.tags = slices.Delete(m.tags, i, i+1)
+	}
+	m.appendMessage(roleInfo, m.tagsNote())
+	return m, nil
+}
+
+func (m Model) tagsNote() string {
+	if len(m.tags) == 0 {
+		return "The conversation has no tags; /tag <tag> adds one."
+	}
+	return "Tags: " + formatTags(m.tags) + " (written by the next /save)"
+}
+
+// savedSummary is what /list shows of a saved conversation.
+type savedSummary struct {
+	name     string
+	modified time.Time
+	turns    int
+	tags     []string
+	title    string
+}
+
+// listConversations handles /list, which lists the saved conversations,
+// newest first. With tags, only those carrying all of them are listed.
+func listConversations(args []string) (string, error) {
+	dir, err := config.ConversationsDir()
+	if err != nil {
+		return "", err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	var want []string
+	for _, arg := range args {
+		want = append(want, normalizeTag(arg))
+	}
+
+	var list []savedSummary
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		s, err := readSummary(filepath.Join(dir, e.Name()))
+		if err != nil {
+			// A file that isn't a conversation isn't listed; /load says
+			// what is wrong with it.
+			continue
+		}
+		if hasTags(s.tags, want) {
+			list = append(list, s)
+		}
+	}
+	if len(list) == 0 {
+		if len(want) > 0 {
+			return "No saved conversation is tagged " + formatTags(want) + ".", nil
+		}
+		return "No saved conversations yet; /save writes one.", nil
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].modified.After(list[j].modified) })
+
+	width := 0
+	for _, s := range list {
+		width = max(width, len(s.name))
+	}
+	var b strings.Builder
+	b.WriteString("Saved conversations")
+	if len(want) > 0 {
+		b.WriteString(" tagged " + formatTags(want))
+	}
+	b.WriteString(":")
+	for _, s := range list {
+		fmt.Fprintf(&b, "\n  %-*s  %s  %3d turns", width, s.name, s.modified.Format("2006-01-02 15:04"), s.turns)
+		if len(s.tags) > 0 {
+			b.WriteString("  " + formatTags(s.tags))
+		}
+		if s.title != "" {
+			fmt.Fprintf(&b, "  %q", s.title)
+		}
+	}
+	return b.String(), nil
+}
+
+func readSummary(path string) (savedSummary, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return savedSummary{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return savedSummary{}, err
+	}
+	var c savedConversation
+	if err := json.Unmarshal(data, &c); err != nil {
+		return savedSummary{}, err
+	}
+	if c.Current < 0 || c.Current >= len(c.Branches) {
+		return savedSummary{}, fmt.Errorf("no usable branches")
+	}
+	s := savedSummary{
+		name:     strings.TrimSuffix(filepath.Base(path), ".json"),
+		modified: info.ModTime(),
+		tags:     c.Tags,
+		title:    c.Title,
+	}
+	for _, msg := range c.Branches[c.Current].Messages {
+		if msg.Role == roleUser {
+			s.turns++
+		}
+	}
+	return s, nil
+}
+
+// hasTags reports whether tags holds every tag of want.
+func hasTags(tags, want []string) bool {
+	for _, tag := range want {
+		if !slices.Contains(tags, tag) {
+			return false
+		}
+	}
+	return true
+}
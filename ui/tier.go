@@ -0,0 +1,52 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/bnema/cclui/api"
+)
+
+// setServiceTier handles /tier [auto|standard_only|default], which sets the
+// service_tier of the next requests; default leaves it to the API. Without
+// an argument it shows the tier asked for and the one the last reply got.
+func (m Model) setServiceTier(args []string) (Model, error) {
+	switch {
+	case len(args) == 0:
+		m.appendMessage(roleInfo, "Service tier: "+m.serviceTier())
+		return m, nil
+	case len(args) > 1:
+		return m, fmt.Errorf("usage: /tier [auto|standard_only|default]")
+	case args[0] == "default":
+		m.params.ServiceTier = ""
+	default:
+		if err := api.CheckServiceTier(args[0]); err != nil {
+			return m, err
+		}
+		m.params.ServiceTier = args[0]
+	}
+	m.appendMessage(roleInfo, "Service tier: "+m.serviceTier())
+	return m, nil
+}
+
+// serviceTier describes the service_tier requests are sent with and the
+// tier the last reply was served with, if the API said.
+func (m Model) serviceTier() string {
+	tier := m.params.ServiceTier
+	if tier == "" {
+		tier = "default"
+	}
+	if served := m.lastServiceTier(); served != "" {
+		tier += fmt.Sprintf(" (last response: %s)", served)
+	}
+	return tier
+}
+
+// lastServiceTier is the tier the last reply was served with.
+func (m Model) lastServiceTier() string {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].role == roleAssistant {
+			return m.messages[i].usage.ServiceTier
+		}
+	}
+	return ""
+}
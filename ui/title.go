@@ -0,0 +1,210 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/bnema/cclui/api"
+	"github.com/bnema/cclui/config"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// titleWords and titleRunes bound a title, and titleNameRunes the file
+// name made of it.
+const (
+	titleWords     = 8
+	titleRunes     = 60
+	titleNameRunes = 48
+)
+
+// titlePrompt asks Claude for the title of the excerpt that follows it.
+const titlePrompt = "Give the conversation below a title of at most six words, in its language. Reply with the title alone, without quotes or punctuation at the end.\n\n"
+
+// titledMsg carries the title Claude gave the conversation for /save.
+type titledMsg struct {
+	title string
+	err   error
+}
+
+// saveUntitled handles /save without a name. A conversation saved that way
+// once keeps its file; otherwise it is named after a title, chosen as
+// auto_title says, or by the time.
+func (m Model) saveUntitled() (tea.Model, tea.Cmd) {
+	if m.titledAs != "" {
+		return m.reportSave(m.saveConversation(m.titledAs)), nil
+	}
+	if m.title == "" && turns(m.messages) > 0 {
+		switch m.config.AutoTitle {
+		case "first-line":
+			m.title = firstLineTitle(m.messages)
+		case "claude":
+			return m, m.askTitle()
+		}
+	}
+	return m.saveTitled(), nil
+}
+
+// saveTitled saves the conversation under a file name made of its title,
+// numbered when another conversation has it, or by the time without one.
+func (m Model) saveTitled() Model {
+	name := titleName(m.title)
+	if name == "" {
+		return m.reportSave(m.saveConversation(""))
+	}
+	name, err := freeName(name)
+	if err != nil {
+		return m.reportSave("", err)
+	}
+	path, err := m.saveConversation(name)
+	if err == nil {
+		m.titledAs = name
+	}
+	return m.reportSave(path, err)
+}
+
+// reportSave says where /save wrote the conversation, or why it could not.
+func (m Model) reportSave(path string, err error) Model {
+	if err != nil {
+		m.appendMessage(roleError, err.Error())
+		return m
+	}
+	m.appendMessage(roleInfo, "Saved to "+path)
+	return m
+}
+
+// titled handles the title Claude gave the conversation, saving it. The
+// first line stands in when asking failed.
+func (m Model) titled(msg titledMsg) Model {
+	m.title = msg.title
+	if msg.err != nil || m.title == "" {
+		m.title = firstLineTitle(m.messages)
+		if msg.err != nil {
+			m.appendMessage(roleInfo, "No title from Claude ("+msg.err.Error()+"); titled by the first message instead.")
+		}
+	}
+	return m.saveTitled()
+}
+
+// askTitle asks Claude for a title, showing it the start of the
+// conversation. The request has no system prompt or tools of its own.
+func (m Model) askTitle() tea.Cmd {
+	client := m.client
+	req := api.MessageRequest{Model: m.params.Model, MaxTokens: 32}
+	req.Messages = []api.MessageToSend{api.ConstructUserMessage(titlePrompt + titleExcerpt(m.messages))}
+	return m.recovering(func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		resp, err := client.CreateMessage(ctx, req)
+		if err != nil {
+			return titledMsg{err: err}
+		}
+		return titledMsg{title: cleanTitle(resp.Text())}
+	}, func(err error) tea.Msg { return titledMsg{err: err} })
+}
+
+// titleExcerpt is the start of the conversation, at most a few thousand
+// characters of it, for askTitle.
+func titleExcerpt(messages []message) string {
+	const limit = 4000
+	var b strings.Builder
+	for _, msg := range messages {
+		if !conversational(msg.role) || blank(msg.content) {
+			continue
+		}
+		speaker := "User"
+		if msg.role == roleAssistant {
+			speaker = "Assistant"
+		}
+		fmt.Fprintf(&b, "%s: %s\n\n", speaker, strings.TrimSpace(msg.content))
+		if b.Len() >= limit {
+			break
+		}
+	}
+	return truncate(b.String(), limit)
+}
+
+// firstLineTitle is the first line of the first message, cut to a few
+// words.
+func firstLineTitle(messages []message) string {
+	for _, msg := range messages {
+		if msg.role != roleUser {
+			continue
+		}
+		for _, line := range strings.Split(msg.content, "\n") {
+			if title := cleanTitle(line); title != "" {
+				return title
+			}
+		}
+	}
+	return ""
+}
+
+// cleanTitle makes a title of the first line of text: Markdown marks,
+// quotes and closing punctuation dropped, and no more than titleWords
+// words or titleRunes characters.
+func cleanTitle(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(sanitize(text)), "\n")
+	line = strings.NewReplacer("*", "", "`", "").Replace(line)
+	line = strings.TrimLeft(line, "#>-_ \t")
+	line = strings.Trim(line, "\"'“”‘’_ \t")
+	words := strings.Fields(line)
+	if len(words) > titleWords {
+		words = words[:titleWords]
+	}
+	line = strings.TrimRight(strings.Join(words, " "), ".,;:!?")
+	return truncate(line, titleRunes)
+}
+
+// titleName makes a file name of title: its letters and digits, lowercased,
+// the rest turned into dashes. It is empty when nothing of title is left
+// or the result is not a valid name.
+func titleName(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	name := []rune(b.String())
+	if len(name) > titleNameRunes {
+		name = name[:titleNameRunes]
+	}
+	s := strings.TrimRight(string(name), "-")
+	if s == "" || config.ValidName(s) != nil {
+		return ""
+	}
+	return s
+}
+
+// freeName is name, or name-2, name-3 and so on when a saved conversation
+// has it already.
+func freeName(name string) (string, error) {
+	for n := 1; ; n++ {
+		candidate := name
+		if n > 1 {
+			candidate = fmt.Sprintf("%s-%d", name, n)
+		}
+		path, err := conversationPath(candidate)
+		if err != nil {
+			return "", err
+		}
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return candidate, nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bnema/cclui/api"
+)
+
+// toolsReport handles /tools, listing the declared tools, the tool choice
+// and whether parallel tool use is allowed.
+func (m Model) toolsReport() string {
+	if len(m.params.Tools) == 0 {
+		return "No tools declared. Point the tools setting at a JSON file of tool definitions to declare some."
+	}
+	var b strings.Builder
+	for _, t := range m.params.Tools {
+		b.WriteString(t.Name)
+		if t.Description != "" {
+			fmt.Fprintf(&b, ": %s", t.Description)
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "Tool choice: %s\n", m.params.ToolChoice)
+	fmt.Fprintf(&b, "Parallel tool use: %s", parallelState(m.params.ToolChoice))
+	return b.String()
+}
+
+// parallelState is "on" unless choice keeps Claude to one tool call per
+// reply.
+func parallelState(choice *api.ToolChoice) string {
+	if choice != nil && choice.DisableParallelToolUse {
+		return "off"
+	}
+	return "on"
+}
+
+// setToolChoice handles /toolchoice [auto|any|none|<tool>]. Without an
+// argument it shows the current choice.
+func (m Model) setToolChoice(args []string) (Model, error) {
+	if len(m.params.Tools) == 0 {
+		return m, fmt.Errorf("no tools are declared, see the tools setting")
+	}
+	switch len(args) {
+	case 0:
+		m.appendMessage(roleInfo, "Tool choice: "+m.params.ToolChoice.String())
+		return m, nil
+	case 1:
+	default:
+		return m, fmt.Errorf("usage: /toolchoice [auto|any|none|<tool>]")
+	}
+
+	choice, err := api.ParseToolChoice(args[0], m.params.Tools)
+	if err != nil {
+		return m, err
+	}
+	if m.params.ToolChoice != nil && m.params.ToolChoice.DisableParallelToolUse {
+		if choice.Type == "none" {
+			return m, fmt.Errorf("parallel tool use is off, which tool choice none does not take; /paralleltools on first")
+		}
+		choice.DisableParallelToolUse = true
+	}
+	m.params.ToolChoice = choice
+	m.appendMessage(roleInfo, "Tool choice set to "+choice.String()+".")
+	return m, nil
+}
+
+// setParallelTools handles /paralleltools [on|off]: off keeps Claude to at
+// most one tool call per reply. Without an argument it shows which it is.
+func (m Model) setParallelTools(args []string) (Model, error) {
+	if len(m.params.Tools) == 0 {
+		return m, fmt.Errorf("no tools are declared, see the tools setting")
+	}
+	if len(args) == 0 {
+		m.appendMessage(roleInfo, "Parallel tool use: "+parallelState(m.params.ToolChoice))
+		return m, nil
+	}
+	if len(args) > 1 || (args[0] != "on" && args[0] != "off") {
+		return m, fmt.Errorf("usage: /paralleltools [on|off]")
+	}
+
+	choice := api.ToolChoice{Type: "auto"}
+	if m.params.ToolChoice != nil {
+		choice = *m.params.ToolChoice
+	}
+	if args[0] == "off" && choice.Type == "none" {
+		return m, fmt.Errorf("tool choice none makes no tool calls; change it with /toolchoice first")
+	}
+	choice.DisableParallelToolUse = args[0] == "off"
+	m.params.ToolChoice = &choice
+	m.appendMessage(roleInfo, "Parallel tool use "+args[0]+".")
+	return m, nil
+}
@@ -1 +1,549 @@
 package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/bnema/cclui/api"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type (
+	// streamStartedMsg is sent once the API accepted a request and the
+	// response started streaming.
+	streamStartedMsg struct {
+		events <-chan api.StreamEvent
+		model  string
+		// request is the request the reply answers, as sent.
+		request *api.MessageRequest
+		// resumed is set for the continuation of the reply being
+		// streamed, see reconnect.
+		resumed bool
+		// seq is the requestSeq of the request.
+		seq int
+	}
+	streamChunkMsg struct {
+		event  api.StreamEvent
+		events <-chan api.StreamEvent
+	}
+	streamDoneMsg struct {
+		events <-chan api.StreamEvent
+	}
+)
+
+// CallClaude sends the conversation so far and starts streaming the reply.
+// The request can be stopped with stopStream until the stream ends.
+func (m *Model) CallClaude() tea.Cmd {
+	return m.request(m.contextMessages(), false)
+}
+
+// request sends messages and starts streaming the reply to them; resumed
+// marks the continuation of the reply being streamed.
+func (m *Model) request(messages []api.MessageToSend, resumed bool) tea.Cmd {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.requestSeq++
+	seq := m.requestSeq
+	if !resumed {
+		m.replyIndex = -1
+	}
+	m.streaming = true
+	m.idle = false
+	m.requestStart = time.Now()
+
+	client := m.client
+	req := m.structuredRequest(m.params)
+	req.System = m.systemPrompt()
+	req.Messages = messages
+	return m.recovering(func() tea.Msg {
+		events, err := client.Stream(ctx, req)
+		if err != nil {
+			return errMsg{err: err, seq: seq}
+		}
+		sent := req
+		sent.Stream = true
+		return streamStartedMsg{events: events, model: req.Model, request: &sent, resumed: resumed, seq: seq}
+	}, func(err error) tea.Msg { return errMsg{err: err, seq: seq} })
+}
+
+// waitForChunk reads the next event of a running stream.
+func waitForChunk(events <-chan api.StreamEvent) tea.Cmd {
+	return func() tea.Msg {
+		event, ok := <-events
+		if !ok {
+			return streamDoneMsg{events: events}
+		}
+		return streamChunkMsg{event: event, events: events}
+	}
+}
+
+// stopStream cancels the running request. What was received of the reply so
+// far is kept.
+func (m Model) stopStream() Model {
+	m.cancel()
+	m.cancel = nil
+	m.events = nil
+	if n := m.streamingReply(); n >= 0 && m.messages[n].content == "" {
+		m.messages = append(m.messages[:n], m.messages[n+1:]...)
+	}
+	m.streaming = false
+	m.replyIndex = -1
+	m.appendMessage(roleInfo, "Stopped.")
+	return m
+}
+
+// escape handles Esc, which never quits. In vim insert mode Esc only goes
+// back to normal mode; otherwise, depending on the state, it:
+//
+//   - stops the response while one is streaming,
+//   - shows the whole reply at once during a /replay, or once it has
+//     streamed under stream_reveal,
+//   - closes the /diff or /page view while it is shown,
+//   - hides the command completions while they are offered,
+//   - clears the input when there is text in it,
+//   - drops the output attached with !!,
+//   - dismisses the retry prompt of a failed turn,
+//   - does nothing otherwise.
+//
+// A pending confirmation takes Esc as "no" before any of these, see answer.
+func (m Model) escape() (tea.Model, tea.Cmd) {
+	switch {
+	case m.streaming:
+		return m.stopStream(), nil
+	case m.replay != nil:
+		return m.stopReplay(), nil
+	case m.overlay:
+		m.overlay = false
+		m.refresh()
+	case len(m.completions()) > 0:
+		m.dismissedCompletion = m.textarea.Value()
+	case m.textarea.Value() != "":
+		m.textarea.Reset()
+	case len(m.attached) > 0:
+		m.attached = nil
+		m.appendMessage(roleInfo, "Attached output dropped.")
+	case m.failedTurn() >= 0:
+		m.messages[m.failedTurn()].failed = false
+		m.refresh()
+	}
+	return m, nil
+}
+
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if key, ok := msg.(tea.KeyMsg); ok {
+		if m.pending != nil {
+			return m.answer(key)
+		}
+		if m.picker != nil {
+			return m.updatePicker(key)
+		}
+		if m.form != nil {
+			return m.updateParams(key)
+		}
+		// Handled before the textarea sees it, and wherever the focus is.
+		if key.Type == tea.KeyCtrlR {
+			return m.toggleRaw(), nil
+		}
+		if m.readOnly {
+			return m.viewerKey(key)
+		}
+		if m.scrolling {
+			return m.scrollKey(key)
+		}
+		if key.Type == tea.KeyTab {
+			if names := m.completions(); len(names) > 0 {
+				return m.complete(names)
+			}
+			return m.toggleFocus(), nil
+		}
+		if key.Type == tea.KeyCtrlO {
+			return m, m.fetchModels()
+		}
+		// Handled before the textarea sees it, which would otherwise
+		// transpose characters.
+		if key.Type == tea.KeyCtrlT {
+			m.thinkingCollapsed = !m.thinkingCollapsed
+			m.refresh()
+			return m, nil
+		}
+		if m.vim && m.vimInsert && key.Type == tea.KeyEsc {
+			m.vimInsert = false
+			return m, nil
+		}
+		if m.vim && !m.vimInsert {
+			if normal, ok := m.vimNormal(key); ok {
+				return normal, nil
+			}
+		}
+	}
+
+	var (
+		tiCmd tea.Cmd
+		vpCmd tea.Cmd
+		plCmd tea.Cmd
+	)
+
+	if m.picker != nil {
+		// The picker filters in the background and reports back with
+		// its own messages.
+		l, cmd := m.picker.Update(msg)
+		m.picker, plCmd = &l, cmd
+	}
+
+	m.textarea, tiCmd = m.textarea.Update(msg)
+	// Keys only scroll the transcript when it has the focus, see scrollKey,
+	// except for PgUp and PgDown which the input has no use for.
+	if key, ok := msg.(tea.KeyMsg); !ok || key.Type == tea.KeyPgUp || key.Type == tea.KeyPgDown {
+		m.viewport, vpCmd = m.viewport.Update(msg)
+	}
+
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		return m.resize(msg), tea.Batch(tiCmd, plCmd)
+
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyEsc:
+			return m.escape()
+		case tea.KeyCtrlC:
+			return m, tea.Quit
+		case tea.KeyEnter:
+			input := m.textarea.Value()
+			m.textarea.Reset()
+			if m.replay != nil {
+				m = m.stopReplay()
+			}
+
+			if isCommand(input) {
+				return m.runCommand(input)
+			}
+			if m.isShell(input) {
+				return m.runShell(input)
+			}
+			return m.send(input)
+		}
+
+	case streamStartedMsg:
+		if !m.streaming || msg.seq != m.requestSeq {
+			// Stopped while connecting: let the stream run out unseen.
+			return m, waitForChunk(msg.events)
+		}
+		m.events = msg.events
+		if msg.resumed {
+			return m, waitForChunk(msg.events)
+		}
+		m.messages = append(m.messages, message{role: roleAssistant, at: time.Now(), model: msg.model, request: msg.request})
+		m.replyIndex = len(m.messages) - 1
+		m, reveal := m.startReveal()
+		m.refresh()
+		return m, tea.Batch(waitForChunk(msg.events), reveal)
+
+	case streamChunkMsg:
+		if msg.events != m.events {
+			// Left over from a stopped stream; the stream ends on its own
+			// once the request is cancelled.
+			if msg.event.Err != nil {
+				return m, nil
+			}
+			return m, waitForChunk(msg.events)
+		}
+		if msg.event.Err != nil {
+			m.events = nil
+			if cmd := m.reconnect(msg.event.Err); cmd != nil {
+				return m, cmd
+			}
+			m.streaming = false
+			m.failTurn(msg.event.Err)
+			return m, m.idleAfter()
+		}
+		n := m.streamingReply()
+		if n < 0 {
+			return m, waitForChunk(msg.events)
+		}
+		if msg.event.Type == "warning" {
+			// The warning goes above the reply it is about.
+			warning := message{role: roleInfo, content: "Warning: " + msg.event.Text, at: time.Now()}
+			m.messages = append(m.messages[:n], append([]message{warning}, m.messages[n:]...)...)
+			m.replyIndex++
+			if r := m.replay; r != nil && r.index == n {
+				moved := *r
+				moved.index++
+				m.replay = &moved
+			}
+			m.refresh()
+			return m, waitForChunk(msg.events)
+		}
+		last := &m.messages[n]
+		if !last.started.IsZero() {
+			last.streamed = time.Since(last.started)
+		}
+		switch msg.event.Type {
+		case "message_start":
+			m.addSpent(last.model, msg.event.Usage)
+			last.usage = last.carried
+			last.usage.InputTokens += msg.event.Usage.InputTokens
+			last.usage.OutputTokens += msg.event.Usage.OutputTokens
+			last.requestID = msg.event.RequestID
+			if last.started.IsZero() {
+				last.started = time.Now()
+			}
+		case "message_delta":
+			// Output tokens are reported as a running total.
+			out := last.carried.OutputTokens + msg.event.Usage.OutputTokens
+			m.addSpent(last.model, api.Usage{OutputTokens: out - last.usage.OutputTokens})
+			last.usage.OutputTokens = out
+			last.stopReason = msg.event.StopReason
+			last.stopSequence = msg.event.StopSequence
+		case "content_block_start":
+			// Text blocks, such as those around a tool use, are kept apart
+			// by a blank line.
+			if msg.event.Block == "text" && last.content != "" && !last.resumed {
+				last.content += "\n\n"
+			}
+			last.resumed = false
+			// A tool_use block is added once its input is complete.
+			if msg.event.Block != "tool_use" {
+				last.blocks = append(last.blocks, api.ContentBlock{Type: msg.event.Block, Data: msg.event.Data})
+			}
+		case "content_block_stop":
+			if use := msg.event.ToolUse; use != nil && !m.structuredReply(last, *use) {
+				last.tools = append(last.tools, *use)
+				last.blocks = append(last.blocks, api.ContentBlock{Type: "tool_use", ID: use.ID, Name: use.Name, Input: use.Input})
+			}
+		default:
+			if n := len(last.blocks); n > 0 {
+				block := &last.blocks[n-1]
+				block.Text += msg.event.Text
+				block.Thinking += msg.event.Thinking
+				block.Signature += msg.event.Signature
+			}
+			last.thinking += msg.event.Thinking
+			last.content += msg.event.Text
+			m.webhookChunk(msg.event.Text)
+		}
+		m.refresh()
+		return m, waitForChunk(msg.events)
+
+	case streamDoneMsg:
+		if msg.events != m.events {
+			return m, nil
+		}
+		reply := m.streamingReply()
+		m.streaming = false
+		m.events = nil
+		m.replyIndex = -1
+		m.lastActivity = time.Now()
+		m.webhookReply(reply)
+		if reply >= 0 && blank(m.messages[reply].content) && len(m.messages[reply].tools) == 0 {
+			m.appendMessage(roleInfo, "The response has no text. Its prompt is left out of the context; send it again or another one.")
+		}
+		if m.previousReply != "" && m.lastReply() != m.previousReply {
+			m.appendMessage(roleInfo, "Use /diff to compare with the previous response.")
+		}
+		if m.lastStopReason() == "max_tokens" {
+			m.appendMessage(roleInfo, fmt.Sprintf("The response was cut off at max_tokens (%d); use /continue to have Claude carry on.", m.params.MaxTokens))
+		} else {
+			var cmd tea.Cmd
+			if m, cmd = m.checkStructured(); cmd != nil {
+				// The corrected reply ends the turn instead.
+				return m, cmd
+			}
+		}
+		idle := m.idleAfter()
+		m, save := m.autosaveAfterTurn()
+		return m, tea.Batch(save, idle, m.postProcess(reply))
+
+	case postProcessMsg:
+		return m.postProcessDone(msg), nil
+
+	case webhookFailedMsg:
+		m, cmd := m.flash("Webhook failed: " + msg.err.Error())
+		return m, tea.Batch(cmd, m.waitForWebhook())
+
+	case idleTickMsg:
+		return m.disconnect(msg), nil
+
+	case clockTickMsg:
+		if msg.id != m.clockID {
+			return m, nil
+		}
+		return m, m.clockTick()
+
+	case autosaveTickMsg:
+		var cmd tea.Cmd
+		m, cmd = m.autosave()
+		return m, tea.Batch(cmd, m.autosaveTick())
+
+	case clearFlashMsg:
+		if msg.id == m.flashID {
+			m.flashText = ""
+		}
+		return m, nil
+
+	case shellMsg:
+		return m.shellDone(msg), nil
+
+	case codeRunMsg:
+		result := fmt.Sprintf("Output of code #%d:\n%s", msg.n, msg.output)
+		if msg.err != nil {
+			result += fmt.Sprintf("\n(%v)", msg.err)
+		}
+		m.appendMessage(roleInfo, result)
+		return m, nil
+
+	case replayTickMsg:
+		return m.advanceReplay(msg)
+
+	case pagerDoneMsg:
+		if msg.err != nil {
+			m.appendMessage(roleError, "pager: "+msg.err.Error())
+		}
+		return m, nil
+
+	case modelsMsg:
+		return m.openPicker(msg), nil
+
+	case titledMsg:
+		return m.titled(msg), nil
+
+	case commandOutputMsg:
+		m.appendMessage(roleInfo, string(msg))
+		return m, nil
+
+	case questionMsg:
+		return m.send(string(msg))
+
+	case commandErrorMsg:
+		m.appendMessage(roleError, msg.err.Error())
+		return m, nil
+
+	case copiedMsg:
+		if msg.err != nil {
+			m.appendMessage(roleError, "copy failed: "+msg.err.Error())
+			return m, nil
+		}
+		return m.flash(fmt.Sprintf("Copied %s (%d characters)", msg.what, msg.chars))
+
+	// We handle errors just like any other message
+	case errMsg:
+		if errors.Is(msg.err, context.Canceled) || !m.streaming || msg.seq != m.requestSeq {
+			// The request was stopped before the response started.
+			return m, nil
+		}
+		m.err = msg.err
+		m.streaming = false
+		m.failTurn(msg.err)
+		return m, m.idleAfter()
+	}
+
+	return m, tea.Batch(tiCmd, vpCmd, plCmd)
+}
+
+// send shows input in the transcript and sends it to Claude. Input that is
+// only whitespace is dropped, as the API refuses empty messages.
+func (m Model) send(input string) (tea.Model, tea.Cmd) {
+	// One response streams at a time; the message waits in the input.
+	if m.streaming && strings.TrimSpace(input) != "" {
+		m.textarea.SetValue(input)
+		return m.flash("Not sent: wait for the response to finish, or press Esc to stop it")
+	}
+	// A second Enter right after a message went out, from key repeat or a
+	// quick correction, gets the input back to edit instead.
+	if guard := m.config.SendGuard; guard > 0 && time.Since(m.lastSend) < guard && strings.TrimSpace(input) != "" {
+		m.textarea.SetValue(input)
+		return m.flash(fmt.Sprintf("Not sent: a message went out less than %s ago", guard))
+	}
+	if m.config.NormalizeInput {
+		input = NormalizeInput(input)
+	}
+	if strings.TrimSpace(input) == "" && len(m.attached) == 0 {
+		return m, nil
+	}
+	if limit := m.config.ConfirmTokens; limit > 0 && !m.largeAllowed {
+		if n := m.contextTokens() + api.EstimateTokens(input); n > limit {
+			return m.confirmLarge(input, n), nil
+		}
+	}
+	return m.deliver(input)
+}
+
+// confirmLarge asks before sending input in a request of about n tokens,
+// over confirm_tokens. The input is kept to edit if the answer is no.
+func (m Model) confirmLarge(input string, n int) Model {
+	m.textarea.SetValue(input)
+	prompt := fmt.Sprintf("Send about %s input tokens, over confirm_tokens (%s)? a: yes for the session", shortCount(n), shortCount(m.config.ConfirmTokens))
+	yes := func(m Model) (tea.Model, tea.Cmd) {
+		m.textarea.Reset()
+		return m.deliver(input)
+	}
+	always := func(m Model) (tea.Model, tea.Cmd) {
+		m.largeAllowed = true
+		return yes(m)
+	}
+	return m.askAlways(prompt, yes, always)
+}
+
+// deliver sends input, with what was attached to it, as the next message.
+func (m Model) deliver(input string) (tea.Model, tea.Cmd) {
+	if i := m.failedTurn(); i >= 0 {
+		m.messages[i].failed = false
+	}
+	m.revealed = 0
+	m.lastSend = time.Now()
+	m.lastActivity = m.lastSend
+	m.appendMessage(roleUser, m.withAttached(input))
+	m.structuredRetries = m.config.JSONRetries
+	return m, m.CallClaude()
+}
+
+// NormalizeInput turns CRLF and CR line endings into LF and drops trailing
+// whitespace, blank lines included, which pasted text often brings along.
+func NormalizeInput(input string) string {
+	input = strings.ReplaceAll(input, "\r\n", "\n")
+	input = strings.ReplaceAll(input, "\r", "\n")
+	return strings.TrimRightFunc(input, unicode.IsSpace)
+}
+
+// streamingReply returns the index of the reply being streamed, or -1 when
+// there is none.
+func (m Model) streamingReply() int {
+	i := m.replyIndex
+	if !m.streaming || i < 0 || i >= len(m.messages) || m.messages[i].role != roleAssistant {
+		return -1
+	}
+	return i
+}
+
+// appendMessage adds a message at the end of the transcript, closing the
+// /diff or /page view so that it shows. A reply being streamed keeps its
+// place, see streamingReply.
+func (m *Model) appendMessage(role, content string) {
+	m.messages = append(m.messages, message{role: role, content: content, at: time.Now()})
+	m.overlay = false
+	m.refresh()
+}
+
+// refresh re-renders the transcript and scrolls to its end, or to the
+// selected message if there is one. A transcript that has the focus and is
+// scrolled up from its end stays where it is, so that a streaming reply
+// doesn't pull it down. While the /diff or /page view is shown, the
+// transcript is only rendered once it closes.
+func (m *Model) refresh() {
+	m.archive()
+	if m.overlay {
+		return
+	}
+	follow := !m.scrolling || m.viewport.AtBottom()
+	m.setContent(m.renderMessages())
+	if m.selectedMessage() >= 0 {
+		m.showSelected()
+		return
+	}
+	if follow {
+		m.viewport.GotoBottom()
+	}
+}
@@ -0,0 +1,349 @@
+package ui
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bnema/cclui/api"
+	"github.com/bnema/cclui/config"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// newTestModel returns a session on the demo provider, sized like a small
+// terminal.
+func newTestModel(t *testing.T) Model {
+	t.Helper()
+	m := newModel(api.NewDemo(), &config.Config{})
+	return update(t, m, tea.WindowSizeMsg{Width: 80, Height: 24})
+}
+
+// update feeds msg to m and returns the model it turns into.
+func update(t *testing.T, m Model, msg tea.Msg) Model {
+	t.Helper()
+	next, _ := m.Update(msg)
+	return next.(Model)
+}
+
+// startStream stands in for the request after prompt: the prompt is in the
+// transcript and the stream of its reply has started. Its events are fed
+// with chunk.
+func startStream(t *testing.T, m Model, prompt string) (Model, chan api.StreamEvent) {
+	t.Helper()
+	m.appendMessage(roleUser, prompt)
+	m.streaming = true
+	m.cancel = func() {}
+	events := make(chan api.StreamEvent)
+	return update(t, m, streamStartedMsg{events: events, model: "claude-test"}), events
+}
+
+// chunk feeds one event of the stream events to m.
+func chunk(t *testing.T, m Model, events chan api.StreamEvent, event api.StreamEvent) Model {
+	t.Helper()
+	return update(t, m, streamChunkMsg{event: event, events: events})
+}
+
+// text feeds the events of a text block holding parts to m.
+func text(t *testing.T, m Model, events chan api.StreamEvent, parts ...string) Model {
+	t.Helper()
+	m = chunk(t, m, events, api.StreamEvent{Type: "content_block_start", Block: "text"})
+	for _, part := range parts {
+		m = chunk(t, m, events, api.StreamEvent{Type: "content_block_delta", Block: "text", Text: part})
+	}
+	return chunk(t, m, events, api.StreamEvent{Type: "content_block_stop", Block: "text"})
+}
+
+func TestStreamKeepsWritingToItsReply(t *testing.T) {
+	m := newTestModel(t)
+	m, events := startStream(t, m, "first")
+	m = text(t, m, events, "Hello")
+	m = update(t, m, commandOutputMsg("a note in the middle of the reply"))
+	m = text(t, m, events, " world")
+	m = update(t, m, streamDoneMsg{events: events})
+
+	var reply, note message
+	for _, msg := range m.messages {
+		switch msg.role {
+		case roleAssistant:
+			reply = msg
+		case roleInfo:
+			if strings.HasPrefix(msg.content, "a note") {
+				note = msg
+			}
+		}
+	}
+	if reply.content != "Hello\n\n world" {
+		t.Errorf("reply = %q, want both blocks", reply.content)
+	}
+	if note.content != "a note in the middle of the reply" {
+		t.Errorf("note = %q, want it unchanged", note.content)
+	}
+	context := m.contextMessages()
+	if len(context) != 2 || context[0].Role != roleUser || context[1].Role != roleAssistant {
+		t.Fatalf("context = %+v, want the prompt and its reply", context)
+	}
+}
+
+func TestMalformedChunkIsSanitized(t *testing.T) {
+	m := newTestModel(t)
+	m, events := startStream(t, m, "hi")
+	raw := "ok \x1b[2J\x1b[31mred\x07 \xff\xfe bytes\r\nnext"
+	m = text(t, m, events, raw)
+	m = update(t, m, streamDoneMsg{events: events})
+
+	if reply := m.lastReply(); reply != raw {
+		t.Errorf("content = %q, want the raw text kept", reply)
+	}
+	shown := m.renderMessages()
+	for _, bad := range []string{"\x1b[2J", "\x07", "\xff", "\r"} {
+		if strings.Contains(shown, bad) {
+			t.Errorf("transcript shows %q", bad)
+		}
+	}
+	if !strings.Contains(shown, "[2J[31mred � bytes") {
+		t.Errorf("transcript lost the text around the control characters:\n%s", shown)
+	}
+}
+
+func TestEnterWhileStreamingKeepsTheMessage(t *testing.T) {
+	m := newTestModel(t)
+	m, events := startStream(t, m, "first")
+	m = text(t, m, events, "Hel")
+	m.textarea.SetValue("second")
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyEnter})
+
+	if got := m.textarea.Value(); got != "second" {
+		t.Errorf("input = %q, want the message kept", got)
+	}
+	if n := turns(m.messages); n != 1 {
+		t.Errorf("%d turns, want the second message not sent", n)
+	}
+	if !m.streaming || m.events != events {
+		t.Error("the stream of the first reply was dropped")
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, m Model) Model
+		check func(t *testing.T, m Model)
+	}{
+		{
+			name: "stops the stream",
+			setup: func(t *testing.T, m Model) Model {
+				m, events := startStream(t, m, "hi")
+				m.textarea.SetValue("draft")
+				return text(t, m, events, "partial")
+			},
+			check: func(t *testing.T, m Model) {
+				if m.streaming || m.events != nil {
+					t.Error("still streaming")
+				}
+				if m.lastReply() != "partial" {
+					t.Errorf("reply = %q, want what arrived kept", m.lastReply())
+				}
+				if m.textarea.Value() != "draft" {
+					t.Error("the input was cleared along with the stream")
+				}
+			},
+		},
+		{
+			name: "closes the overlay",
+			setup: func(t *testing.T, m Model) Model {
+				m.textarea.SetValue("draft")
+				m.showOverlay("help")
+				return m
+			},
+			check: func(t *testing.T, m Model) {
+				if m.overlay {
+					t.Error("the overlay is still shown")
+				}
+				if m.textarea.Value() != "draft" {
+					t.Error("the input was cleared along with the overlay")
+				}
+			},
+		},
+		{
+			name: "drops the selection, keeping the focus",
+			setup: func(t *testing.T, m Model) Model {
+				m.appendMessage(roleUser, "hi")
+				m.appendMessage(roleAssistant, "hello")
+				return m.toggleFocus().moveSelection(-1)
+			},
+			check: func(t *testing.T, m Model) {
+				if m.selectedMessage() >= 0 {
+					t.Error("a message is still selected")
+				}
+				if !m.scrolling {
+					t.Error("the transcript lost the focus")
+				}
+			},
+		},
+		{
+			name: "gives the focus back without a selection",
+			setup: func(t *testing.T, m Model) Model {
+				return m.toggleFocus()
+			},
+			check: func(t *testing.T, m Model) {
+				if m.scrolling {
+					t.Error("the transcript kept the focus")
+				}
+			},
+		},
+		{
+			name: "clears the input",
+			setup: func(t *testing.T, m Model) Model {
+				m.textarea.SetValue("draft")
+				return m
+			},
+			check: func(t *testing.T, m Model) {
+				if m.textarea.Value() != "" {
+					t.Errorf("input = %q, want it cleared", m.textarea.Value())
+				}
+			},
+		},
+		{
+			name:  "does nothing when idle",
+			setup: func(t *testing.T, m Model) Model { return m },
+			check: func(t *testing.T, m Model) {
+				if len(m.messages) != 0 || m.overlay || m.scrolling {
+					t.Error("Esc changed the idle session")
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := tt.setup(t, newTestModel(t))
+			next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+			m = next.(Model)
+			if cmd != nil {
+				if _, quit := cmd().(tea.QuitMsg); quit {
+					t.Fatal("Esc quit")
+				}
+			}
+			tt.check(t, m)
+		})
+	}
+}
+
+func TestMultiBlockReply(t *testing.T) {
+	m := newTestModel(t)
+	m.params.Tools = []api.Tool{{Name: "search"}}
+	m, events := startStream(t, m, "find owls")
+	m = text(t, m, events, "Let me ", "look.")
+	use := &api.ToolUse{ID: "toolu_1", Name: "search", Input: []byte(`{"query":"owls"}`)}
+	m = chunk(t, m, events, api.StreamEvent{Type: "content_block_start", Index: 1, Block: "tool_use"})
+	m = chunk(t, m, events, api.StreamEvent{Type: "content_block_stop", Index: 1, Block: "tool_use", ToolUse: use})
+	m = text(t, m, events, "Found it.")
+	m = update(t, m, streamDoneMsg{events: events})
+
+	reply := m.messages[len(m.messages)-1]
+	if reply.content != "Let me look.\n\nFound it." {
+		t.Errorf("content = %q", reply.content)
+	}
+	if len(reply.tools) != 1 || reply.tools[0].ID != "toolu_1" {
+		t.Errorf("tools = %+v", reply.tools)
+	}
+	var kinds []string
+	for _, b := range reply.blocks {
+		kinds = append(kinds, b.Type)
+	}
+	if strings.Join(kinds, ",") != "text,tool_use,text" || reply.blocks[0].Text != "Let me look." || reply.blocks[2].Text != "Found it." {
+		t.Errorf("blocks = %+v", reply.blocks)
+	}
+}
+
+func TestRetryKeyOnlyInTheTranscript(t *testing.T) {
+	m := newTestModel(t)
+	m.appendMessage(roleUser, "hi")
+	m.failTurn(errors.New("overloaded"))
+	for _, r := range "rewrite" {
+		m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	if got := m.textarea.Value(); got != "rewrite" {
+		t.Errorf("input = %q, want what was typed", got)
+	}
+	if m.failedTurn() < 0 {
+		t.Fatal("typing retried the failed turn")
+	}
+
+	m.textarea.Reset()
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyTab})
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'r'}})
+	if last := m.messages[len(m.messages)-1]; last.role != roleUser || last.content != "hi" {
+		t.Errorf("last message = %s %q, want the prompt sent again", last.role, last.content)
+	}
+	if m.scrolling {
+		t.Error("the transcript kept the focus after the retry")
+	}
+}
+
+func TestHelpKeyOnlyInTheTranscript(t *testing.T) {
+	m := newTestModel(t)
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'?'}})
+	if m.overlay {
+		t.Fatal("? in the input showed the help")
+	}
+	if got := m.textarea.Value(); got != "?" {
+		t.Errorf("input = %q, want the ? typed", got)
+	}
+
+	m.textarea.Reset()
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyTab})
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'?'}})
+	if !m.overlay {
+		t.Fatal("? in the transcript didn't show the help")
+	}
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyEsc})
+	if m.overlay || !m.scrolling {
+		t.Error("Esc didn't close the help, keeping the focus")
+	}
+}
+
+func TestStoppedRequestStaysStopped(t *testing.T) {
+	tests := []struct {
+		name string
+		late func(events chan api.StreamEvent) tea.Msg
+	}{
+		{"late start", func(events chan api.StreamEvent) tea.Msg {
+			return streamStartedMsg{events: events, model: "claude-test", seq: 1}
+		}},
+		{"late error", func(chan api.StreamEvent) tea.Msg {
+			return errMsg{err: errors.New("connection reset"), seq: 1}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestModel(t)
+			m.appendMessage(roleUser, "first")
+			m.CallClaude()
+			m = update(t, m, tea.KeyMsg{Type: tea.KeyEsc})
+			m.appendMessage(roleUser, "second")
+			m.CallClaude()
+			events := make(chan api.StreamEvent)
+			m = update(t, m, streamStartedMsg{events: events, model: "claude-test", seq: 2})
+			m = text(t, m, events, "Hello")
+
+			m = update(t, m, tt.late(make(chan api.StreamEvent)))
+			if !m.streaming || m.events != events {
+				t.Fatal("the stopped request took over the stream of the next one")
+			}
+			m = text(t, m, events, " world")
+			m = update(t, m, streamDoneMsg{events: events})
+			var replies []string
+			for _, msg := range m.messages {
+				switch msg.role {
+				case roleAssistant:
+					replies = append(replies, msg.content)
+				case roleError:
+					t.Errorf("error shown: %q", msg.content)
+				}
+			}
+			if len(replies) != 1 || replies[0] != "Hello\n\n world" {
+				t.Errorf("replies = %q, want the second one alone", replies)
+			}
+		})
+	}
+}
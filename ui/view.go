@@ -0,0 +1,305 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bnema/cclui/config"
+	"github.com/charmbracelet/lipgloss"
+)
+
+func (m Model) View() string {
+	separator := "\n\n"
+	if m.streaming {
+		separator = "\n" + m.progress.ViewAs(m.streamProgress()) + "\n"
+	}
+	transcript := m.viewport.View()
+	if m.picker != nil {
+		transcript = m.picker.View()
+	}
+	if m.form != nil {
+		transcript = m.viewParams()
+	}
+	input := m.textarea.View()
+	if m.pending != nil {
+		input = m.senderStyle.Render(m.pending.prompt + " " + m.pending.keys())
+	}
+	status := m.statusLine()
+	if m.readOnly {
+		input, status = m.viewerLine(), m.flashText
+	}
+	if names := m.completions(); len(names) > 0 && m.pending == nil {
+		status = m.completionLine(names)
+	}
+	return fmt.Sprintf(
+		"%s%s%s\n%s",
+		transcript,
+		separator,
+		input,
+		status,
+	) + "\n"
+}
+
+// statusLine is the line under the input.
+func (m Model) statusLine() string {
+	cost := ""
+	if c := m.sessionCost(); c > 0 {
+		cost = fmt.Sprintf("$%.4f", c)
+	}
+	focus := ""
+	if m.scrolling {
+		focus = "-- SCROLL -- (tab to type)"
+	}
+	tokens := ""
+	if t := m.contextTokens(); t > 0 {
+		tokens = fmt.Sprintf("~%s tok", shortCount(t))
+	}
+	idle := ""
+	if m.idle {
+		idle = "idle"
+	}
+	display := ""
+	switch {
+	case m.codeOnly:
+		display = "code only"
+	case m.rawView:
+		display = "raw text"
+	}
+	attached := ""
+	if n := len(m.attached); n > 0 {
+		attached = fmt.Sprintf("+%d attached", n)
+	}
+	persona := ""
+	if m.persona != "" {
+		persona = "persona " + m.persona
+	}
+	parts := make([]string, 0, 11)
+	for _, s := range []string{focus, m.vimMode(), persona, m.enforcement(), display, idle, attached, tokens, cost, m.clock(), m.flashText} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return m.statusStyle.Render(strings.Join(parts, "  "))
+}
+
+// shortCount formats n with a K suffix past a thousand.
+func shortCount(n int) string {
+	if n < 1000 {
+		return fmt.Sprint(n)
+	}
+	return fmt.Sprintf("%.1fK", float64(n)/1000)
+}
+
+func (m Model) renderMessages() string {
+	hidden := m.hiddenMessages()
+	blocks := m.renderBlocks()[hidden:]
+	if hidden > 0 {
+		blocks = append([]string{m.archiveNote(hidden)}, blocks...)
+	}
+	return strings.Join(blocks, "\n")
+}
+
+// renderBlocks renders each message of the transcript, in order. Those
+// hidden by max_transcript are left empty, only counting their code blocks
+// so that the others keep their numbers.
+func (m Model) renderBlocks() []string {
+	blocks := make([]string, 0, len(m.messages))
+	block := 1
+	selected := m.selectedMessage()
+	hidden := m.hiddenMessages()
+	for i, msg := range m.messages {
+		if i < hidden {
+			if msg.role == roleAssistant {
+				block += len(parseCodeBlocks(msg.content))
+			}
+			blocks = append(blocks, "")
+			continue
+		}
+		content := msg.content
+		if m.replay != nil && m.replay.index == i {
+			content = content[:min(m.replay.shown, len(content))]
+		}
+		text := sanitize(content)
+		if m.compactView {
+			text = compactText(text)
+		}
+		pin := ""
+		if msg.pinned {
+			pin = m.pinStyle.Render("[pinned] ")
+		} else if msg.pruned {
+			pin = m.codeLabelStyle.Render("[pruned] ")
+		}
+		switch msg.role {
+		case roleUser:
+			blocks = append(blocks, m.labelled("user", pin+text, i == selected))
+		case roleAssistant:
+			thinking, body := m.renderReply(msg, i, text, &block)
+			blocks = append(blocks, thinking+m.labelled("assistant", pin+body, i == selected))
+		case roleError:
+			if msg.failed {
+				text += m.pinStyle.Render("  ↻ Tab then r to retry, Esc to dismiss")
+			}
+			blocks = append(blocks, m.labelled("error", text, false))
+		case rolePanel:
+			blocks = append(blocks, m.panelStyle.Render(text))
+		case roleBanner:
+			blocks = append(blocks, m.banner(text))
+		case roleOpener:
+			blocks = append(blocks, m.labelled("assistant", text, false))
+		default:
+			if m.mergesWithPrevious(i) {
+				blocks = append(blocks, m.continued("system", text))
+				break
+			}
+			blocks = append(blocks, m.labelled("system", text, false))
+		}
+	}
+	return blocks
+}
+
+// replyFooter is the line of details under a reply. It names the model
+// that produced it when that isn't the session's, says why the reply
+// stopped, unless it simply ended, and whether its stream was picked up
+// after dropping, gives its tokens per second when shown, and with
+// show_request_id the ID of its request.
+func (m Model) replyFooter(msg message) string {
+	var parts []string
+	if msg.model != "" && msg.model != m.params.Model {
+		parts = append(parts, "by "+sanitize(msg.model))
+	}
+	if reason := stopNote(msg); reason != "" {
+		parts = append(parts, reason)
+	}
+	switch {
+	case msg.reconnects == 1:
+		parts = append(parts, "↻ continued after the stream dropped")
+	case msg.reconnects > 1:
+		parts = append(parts, fmt.Sprintf("↻ continued after the stream dropped %d times", msg.reconnects))
+	}
+	if rate := tokensPerSecond(msg); m.showRate && rate > 0 {
+		parts = append(parts, formatRate(rate))
+	}
+	if m.config.ShowRequestID && msg.requestID != "" {
+		parts = append(parts, "request-id "+sanitize(msg.requestID))
+	}
+	return strings.Join(parts, "  ·  ")
+}
+
+// stopNote says why a reply stopped, unless it simply ended.
+func stopNote(msg message) string {
+	switch msg.stopReason {
+	case "", "end_turn":
+		return ""
+	case "max_tokens":
+		return "⏹ cut off at max_tokens"
+	case "stop_sequence":
+		return fmt.Sprintf("⏹ stopped at the stop sequence %q", sanitize(msg.stopSequence))
+	case "tool_use":
+		// Unless the call was the reply itself, see structuredReply.
+		if len(msg.tools) == 0 {
+			return ""
+		}
+		return "⏹ stopped to call a tool"
+	}
+	return "⏹ stopped: " + sanitize(msg.stopReason)
+}
+
+// roleStyle is the configured look of a role's messages.
+type roleStyle struct {
+	label string
+	style lipgloss.Style
+	// colored is set when the role has a color of its own.
+	colored bool
+}
+
+// banner is the greeting at the top of the transcript: the app name, the
+// model in use and where to find help, followed by note if any.
+func (m Model) banner(note string) string {
+	title := m.senderStyle.Bold(true).Render("cclui") + " " + m.statusStyle.Render("· "+m.params.Model)
+	text := title + "\nType a message and press Enter. /help lists the commands, Ctrl+C to quit."
+	if note != "" {
+		text += "\n" + note
+	}
+	return m.panelStyle.Render(text)
+}
+
+func newRoleStyles(roles map[string]config.Role) map[string]roleStyle {
+	styles := make(map[string]roleStyle, len(roles))
+	for name, r := range roles {
+		s := roleStyle{label: r.Label, style: lipgloss.NewStyle(), colored: r.Color != ""}
+		if s.colored {
+			s.style = s.style.Foreground(lipgloss.Color(r.Color))
+		}
+		styles[name] = s
+	}
+	return styles
+}
+
+// labelled prefixes text with the label of role. The text is wrapped to
+// the room left next to the label, and following lines are indented by the
+// label's display width so that they line up under the first one, whatever
+// characters the label is made of. A role without a
+// label has its whole text colored instead. The selected message has its
+// label, or its text if it has none, in reverse video.
+func (m Model) labelled(role, text string, selected bool) string {
+	r := m.roles[role]
+	style := r.style.Reverse(selected)
+	if r.label == "" {
+		if r.colored || selected {
+			return style.Render(text)
+		}
+		return text
+	}
+
+	label := style.Render(r.label+":") + " "
+	width := lipgloss.Width(label)
+	text = wrap(text, m.wrapWidth()-width)
+	return label + strings.ReplaceAll(text, "\n", "\n"+strings.Repeat(" ", width))
+}
+
+// labelWidth is the width labelled takes up with the label of role.
+func (m Model) labelWidth(role string) int {
+	if r := m.roles[role]; r.label != "" {
+		return lipgloss.Width(r.label + ": ")
+	}
+	return 0
+}
+
+// arriving reports whether the reply at i is still coming in, being
+// streamed or replayed.
+func (m Model) arriving(i int) bool {
+	return (i >= 0 && i == m.streamingReply()) || (m.replay != nil && m.replay.index == i)
+}
+
+// collapsePreview is the number of lines a collapsed reply keeps.
+const collapsePreview = 10
+
+// collapse cuts the text of the reply at i to its first lines, with a note
+// saying how many are left out, when it is taller than collapse_lines once
+// wrapped and not expanded with e. The reply being streamed or replayed is
+// left whole.
+func (m Model) collapse(text string, i int) string {
+	limit := m.config.CollapseLines
+	msg := m.messages[i]
+	if limit <= 0 || msg.expanded || m.arriving(i) {
+		return text
+	}
+	lines := strings.Split(wrap(text, m.wrapWidth()-m.labelWidth(roleAssistant)), "\n")
+	if len(lines) <= limit {
+		return text
+	}
+	keep := min(collapsePreview, limit)
+	note := m.codeLabelStyle.Render(fmt.Sprintf("⋯ %d more lines (select the response and press e to expand)", len(lines)-keep))
+	return strings.Join(lines[:keep], "\n") + "\n" + note
+}
+
+// renderThinking draws the thinking that preceded a reply as a pane above
+// it, or as a single line when collapsed with Ctrl+T.
+func (m Model) renderThinking(thinking string) string {
+	text := strings.TrimSpace(sanitize(thinking))
+	if m.thinkingCollapsed {
+		n := strings.Count(text, "\n") + 1
+		return m.thinkingStyle.Render(fmt.Sprintf("▸ Thinking, %d lines (ctrl+t to expand)", n))
+	}
+	return m.thinkingStyle.Render("▾ Thinking (ctrl+t to collapse)\n" + text)
+}
@@ -0,0 +1,48 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/bnema/cclui/api"
+)
+
+func TestStopNote(t *testing.T) {
+	use := &api.ToolUse{ID: "toolu_1", Name: "search", Input: []byte(`{}`)}
+	tests := []struct {
+		name     string
+		reason   string
+		sequence string
+		tool     *api.ToolUse
+		want     string
+	}{
+		{name: "end_turn", reason: "end_turn", want: ""},
+		{name: "no reason", reason: "", want: ""},
+		{name: "max_tokens", reason: "max_tokens", want: "⏹ cut off at max_tokens"},
+		{name: "stop_sequence", reason: "stop_sequence", sequence: "END\x1b", want: `⏹ stopped at the stop sequence "END"`},
+		{name: "tool_use with a call", reason: "tool_use", tool: use, want: "⏹ stopped to call a tool"},
+		{name: "tool_use without a call", reason: "tool_use", want: ""},
+		{name: "unknown", reason: "refusal", want: "⏹ stopped: refusal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestModel(t)
+			m.params.Tools = []api.Tool{{Name: "search"}}
+			m, events := startStream(t, m, "hi")
+			m = text(t, m, events, "Hello")
+			if tt.tool != nil {
+				m = chunk(t, m, events, api.StreamEvent{Type: "content_block_start", Index: 1, Block: "tool_use"})
+				m = chunk(t, m, events, api.StreamEvent{Type: "content_block_stop", Index: 1, Block: "tool_use", ToolUse: tt.tool})
+			}
+			m = chunk(t, m, events, api.StreamEvent{Type: "message_delta", StopReason: tt.reason, StopSequence: tt.sequence})
+			m = update(t, m, streamDoneMsg{events: events})
+
+			reply := m.messages[1]
+			if reply.stopReason != tt.reason || reply.stopSequence != tt.sequence {
+				t.Errorf("stored %q %q, want %q %q", reply.stopReason, reply.stopSequence, tt.reason, tt.sequence)
+			}
+			if got := stopNote(reply); got != tt.want {
+				t.Errorf("stopNote = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
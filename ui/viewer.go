@@ -0,0 +1,134 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bnema/cclui/config"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// viewerHelp is the line under the transcript of the viewer.
+const viewerHelp = "read-only · ↑/↓ select · y copy · c/C code block · e expand · / search · n/N next/previous · ctrl+r raw · q quit"
+
+// NewViewer opens a saved conversation read-only, for -view: the transcript
+// keeps the focus, there is no input and nothing is ever sent, so it needs
+// no API key. spec is the path of a saved conversation, or the name it was
+// saved under.
+func NewViewer(cfg *config.Config, spec string) (Model, error) {
+	path := spec
+	if _, err := os.Stat(spec); err != nil {
+		if path, err = conversationPath(spec); err != nil {
+			return Model{}, err
+		}
+	}
+	m := newModel(nil, cfg)
+	m, err := m.loadFile(path)
+	if err != nil {
+		return Model{}, err
+	}
+	m.readOnly = true
+	m.scrolling = true
+	m.autosavedTurns = turns(m.messages)
+	m.textarea.Blur()
+	m.textarea.SetHeight(1)
+	m.textarea.Placeholder = "Search..."
+	m.refresh()
+	return m, nil
+}
+
+// viewerKey handles every key of the viewer. Only the keys of selectionKey
+// that leave the conversation as it is are kept: moving the selection,
+// copying with y, stepping through code blocks with c and C, and expanding
+// with e.
+func (m Model) viewerKey(key tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.searching {
+		return m.searchKey(key)
+	}
+	switch key.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	case "up", "k", "down", "j", "y", "e", "c", "C":
+		model, cmd, _ := m.selectionKey(key)
+		return model, cmd
+	case "/":
+		m.searching = true
+		m.textarea.Reset()
+		m.textarea.Focus()
+		return m, nil
+	case "n":
+		return m.findNext(1)
+	case "N":
+		return m.findNext(-1)
+	case "esc":
+		return m.clearSelection(), nil
+	case "ctrl+t":
+		m.thinkingCollapsed = !m.thinkingCollapsed
+		m.refresh()
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.viewport, cmd = m.viewport.Update(key)
+	return m, cmd
+}
+
+// searchKey handles a key while the search of the viewer is typed: Enter
+// looks for it from the selection down, Esc gives up.
+func (m Model) searchKey(key tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch key.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+	case tea.KeyEsc, tea.KeyEnter:
+		m.searching = false
+		m.textarea.Blur()
+		if key.Type == tea.KeyEsc {
+			return m, nil
+		}
+		if query := strings.TrimSpace(m.textarea.Value()); query != "" {
+			m.search = query
+		}
+		return m.findNext(1)
+	}
+	var cmd tea.Cmd
+	m.textarea, cmd = m.textarea.Update(key)
+	return m, cmd
+}
+
+// findNext selects the next message containing the last search in
+// direction dir, 1 for down and -1 for up, ignoring case. It goes round
+// the transcript once, starting past the selection, or from its top or
+// bottom when there is none.
+func (m Model) findNext(dir int) (tea.Model, tea.Cmd) {
+	if m.search == "" {
+		return m.flash("Nothing to search for yet; / starts a search")
+	}
+	query := strings.ToLower(m.search)
+	n := len(m.messages)
+	start := m.selectedMessage()
+	if start < 0 {
+		// Just before the first message or just after the last.
+		start = 0
+		if dir > 0 {
+			start = n - 1
+		}
+	}
+	for k := 1; k <= n; k++ {
+		j := ((start+dir*k)%n + n) % n
+		if m.selectable(j) && strings.Contains(strings.ToLower(m.messages[j].content), query) {
+			m.selected = j
+			m.refresh()
+			return m, nil
+		}
+	}
+	return m.flash(fmt.Sprintf("No message contains %q", m.search))
+}
+
+// viewerLine is shown in place of the input in the viewer: the search
+// being typed, or which keys do what.
+func (m Model) viewerLine() string {
+	if m.searching {
+		return m.textarea.View()
+	}
+	return m.statusStyle.Render(viewerHelp)
+}
@@ -0,0 +1,62 @@
+package ui
+
+import tea "github.com/charmbracelet/bubbletea"
+
+// vimMotions maps normal mode keys to the textarea keys doing the same.
+var vimMotions = map[string]tea.KeyMsg{
+	"h": {Type: tea.KeyLeft},
+	"l": {Type: tea.KeyRight},
+	"j": {Type: tea.KeyDown},
+	"k": {Type: tea.KeyUp},
+	"w": {Type: tea.KeyRunes, Runes: []rune{'f'}, Alt: true},
+	"b": {Type: tea.KeyRunes, Runes: []rune{'b'}, Alt: true},
+	"0": {Type: tea.KeyHome},
+	"^": {Type: tea.KeyHome},
+	"$": {Type: tea.KeyEnd},
+	"g": {Type: tea.KeyCtrlHome},
+	"G": {Type: tea.KeyCtrlEnd},
+	"x": {Type: tea.KeyDelete},
+	"X": {Type: tea.KeyBackspace},
+	"D": {Type: tea.KeyCtrlK},
+}
+
+// vimNormal handles a key in vim normal mode, where the keys move the cursor
+// instead of typing. i, a, I and A switch to insert mode like in vim; Esc
+// there comes back. handled is false for keys normal mode leaves alone,
+// such as Enter, Esc and control keys.
+func (m Model) vimNormal(key tea.KeyMsg) (model Model, handled bool) {
+	if key.Type != tea.KeyRunes || key.Alt {
+		return m, false
+	}
+
+	k := key.String()
+	switch k {
+	case "i":
+	case "a":
+		m.textarea, _ = m.textarea.Update(vimMotions["l"])
+	case "I":
+		m.textarea.CursorStart()
+	case "A":
+		m.textarea.CursorEnd()
+	default:
+		// Unmapped keys are swallowed rather than typed.
+		if motion, ok := vimMotions[k]; ok {
+			m.textarea, _ = m.textarea.Update(motion)
+		}
+		return m, true
+	}
+	m.vimInsert = true
+	return m, true
+}
+
+// vimMode is the mode shown in the status line, if vim keys are on.
+func (m Model) vimMode() string {
+	switch {
+	case !m.vim:
+		return ""
+	case m.vimInsert:
+		return "-- INSERT --"
+	default:
+		return "-- NORMAL --"
+	}
+}
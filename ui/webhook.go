@@ -0,0 +1,142 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bnema/cclui/api"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// webhookQueue is how many posts may wait for the webhook. Past it new ones
+// are dropped, so a slow webhook never holds the UI up.
+const webhookQueue = 256
+
+// webhookEvent is the JSON body POSTed to webhook_url: event is "chunk" for
+// a piece of a streaming reply, with only its text, or "response" for the
+// finished reply.
+type webhookEvent struct {
+	Event      string     `json:"event"`
+	Model      string     `json:"model"`
+	Text       string     `json:"text"`
+	StopReason string     `json:"stop_reason,omitempty"`
+	RequestID  string     `json:"request_id,omitempty"`
+	Usage      *api.Usage `json:"usage,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	DurationMS int64      `json:"duration_ms,omitempty"`
+}
+
+// webhook posts events to webhook_url in order, off the UI goroutine.
+// Failures don't stop it; they are reported on failed, one at a time.
+type webhook struct {
+	url    string
+	client *http.Client
+	queue  chan webhookEvent
+	failed chan error
+	done   chan struct{}
+}
+
+func newWebhook(url string, timeout time.Duration) *webhook {
+	w := &webhook{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+		queue:  make(chan webhookEvent, webhookQueue),
+		failed: make(chan error, 1),
+		done:   make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *webhook) run() {
+	defer close(w.done)
+	for e := range w.queue {
+		if err := w.post(e); err != nil {
+			select {
+			case w.failed <- err:
+			default:
+				// One failure is already waiting to be shown.
+			}
+		}
+	}
+}
+
+func (w *webhook) post(e webhookEvent) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return nil
+}
+
+// send queues e, or drops it if the queue is full.
+func (w *webhook) send(e webhookEvent) {
+	select {
+	case w.queue <- e:
+	default:
+	}
+}
+
+// close lets the queued posts go out, waiting at most timeout for them.
+func (w *webhook) close(timeout time.Duration) {
+	close(w.queue)
+	select {
+	case <-w.done:
+	case <-time.After(timeout):
+	}
+}
+
+// webhookFailedMsg reports a post to webhook_url that failed.
+type webhookFailedMsg struct{ err error }
+
+// waitForWebhook waits for the next failure of the webhook, if there is
+// one.
+func (m Model) waitForWebhook() tea.Cmd {
+	if m.webhook == nil {
+		return nil
+	}
+	failed := m.webhook.failed
+	return func() tea.Msg {
+		return webhookFailedMsg{err: <-failed}
+	}
+}
+
+// webhookChunk posts a piece of the reply being streamed, when
+// webhook_chunks is on.
+func (m Model) webhookChunk(text string) {
+	if m.webhook == nil || !m.config.WebhookChunks || text == "" {
+		return
+	}
+	model := m.messages[m.streamingReply()].model
+	m.webhook.send(webhookEvent{Event: "chunk", Model: model, Text: text, StartedAt: m.requestStart})
+}
+
+// webhookReply posts the reply at index, which just ended, if any.
+func (m Model) webhookReply(index int) {
+	if m.webhook == nil || index < 0 {
+		return
+	}
+	last := m.messages[index]
+	usage := last.usage
+	m.webhook.send(webhookEvent{
+		Event:      "response",
+		Model:      last.model,
+		Text:       last.content,
+		StopReason: last.stopReason,
+		RequestID:  last.requestID,
+		Usage:      &usage,
+		StartedAt:  m.requestStart,
+		DurationMS: time.Since(m.requestStart).Milliseconds(),
+	})
+}